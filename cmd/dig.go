@@ -1,37 +1,819 @@
 package cmd
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"io"
 	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/catpaladin/net-tools/pkg/network"
 	"github.com/charmbracelet/huh"
+	"github.com/fatih/color"
 
 	"github.com/spf13/cobra"
 )
 
 var (
-	domain string
+	domain      string
+	server      string
+	queryType   string
+	reverseAddr string
+	trace       bool
+	consistency bool
+	useTLS      bool
+	httpsURL    string
+	httpsMethod string
+	useTCP      bool
+	bufSize     uint16
+	ixfrSerial  uint32
+	dnssec      bool
+	namesFile   string
+	workers     int
+	moreDomains []string
+	cnameLimit  int
+	servers     []string
+	watch       bool
+	expect      []string
+	interval    time.Duration
+	deadline    time.Duration
+	zoneFile    string
+	verifyZone  bool
+	subnet      string
+	nsid        bool
+	cookie      bool
+	explain     bool
 
 	// digCmd represents the dig command
 	digCmd = &cobra.Command{
-		Use:   "dig",
+		Use:   "dig [@server] domain [type]",
 		Short: "Performs DNS lookups like dig",
-		Long:  "Performs DNS lookups like dig",
+		Long: `Performs DNS lookups like dig
+
+Queries are built and sent over the DNS wire protocol to @server. Without
+one, names are looked up through the system resolver like any program on
+this host, honouring /etc/hosts, the search list and nsswitch.conf; record
+types it cannot look up, and the modes and options below that need the wire
+protocol, go to the first nameserver in /etc/resolv.conf. Giving a record
+type, as an argument or with -t, queries only that type. With -x the PTR
+records of an IPv4 or IPv6 address are looked up. With --trace the name is
+resolved iteratively from the root servers, showing every referral. With
+--consistency every authoritative nameserver of the zone is queried directly
+and the servers whose answers differ are shown.
+
+With --tls the queries to @server use DNS-over-TLS (RFC 7858), and with
+--https they are sent to a DNS-over-HTTPS endpoint (RFC 8484).
+
+Queries advertise an EDNS(0) buffer of --bufsize bytes and can carry EDNS
+options: --subnet sends an EDNS Client Subnet to see the answer a geo-DNS
+service gives clients in that network, --nsid asks the server to identify
+itself and --cookie sends a client cookie. The options in the response's OPT
+record, such as the server's NSID, are decoded and shown. A truncated UDP
+answer is retried over TCP automatically, and --tcp always uses TCP. Each
+answer notes the server and transport it came from. CNAME chains are followed
+hop by hop and shown with each TTL, flagging loops and chains longer than
+--max-cname.
+
+Giving the type AXFR transfers the whole zone over TCP and prints it sorted
+in zone file format, ready for diffing. IXFR, or ixfr=SERIAL, asks only for
+the changes since SERIAL (also settable with --serial).
+
+With --dnssec answers are requested with the DO bit and every RRset is
+validated from the root trust anchor down through the DS and DNSKEY records
+of each zone, reporting secure, insecure or bogus with the reason.
+
+Several domains, names read with -f from a file (- for stdin) or names
+piped on stdin are looked up in batch mode: --workers lookups run at once,
+results are printed in input order and a summary counts the failures.
+
+With --watch and --expect the name is queried again every --interval against
+each @server given, showing a live status per resolver, until all of them
+answer with exactly the expected values. dig then exits 0, or 1 once
+--deadline passes, so that deploy pipelines can wait on DNS propagation.
+
+--zonefile parses an RFC 1035 master file and prints its records sorted.
+The origin comes from $ORIGIN, the domain argument or a file name such as
+db.example.com. With --verify every authoritative nameserver of the zone is
+queried for each name and type in the file, and records that are missing,
+extra or served with a different TTL are reported.
+
+--explain first shows how applications on this host would resolve the name:
+the nameservers, search list and options of /etc/resolv.conf, the hosts
+sources of /etc/nsswitch.conf, the names the search list produces and
+whether /etc/hosts answers before DNS is asked.`,
 		Run: func(cmd *cobra.Command, args []string) {
-			if len(args) < 1 {
+			parseDigArgs(args)
+			if zoneFile != "" {
+				zoneFileDig(cmd.Context())
+				return
+			}
+			if reverseAddr != "" {
+				name, err := network.ReverseName(reverseAddr)
+				if err != nil {
+					fmt.Printf("%s %v\n", errorMsg("[Error]"), err)
+					os.Exit(1)
+				}
+				domain = name
+				queryType = network.TypePTR.String()
+			}
+			names, err := batchNames()
+			if err != nil {
+				fmt.Printf("%s %v\n", errorMsg("[Error]"), err)
+				os.Exit(1)
+			}
+			if domain == "" && names == nil {
 				interactiveDig()
-			} else {
-				domain = args[0]
 			}
-			network.Dig(domain)
+
+			var types []network.Type
+			if queryType != "" {
+				qtype, err := network.ParseType(queryType)
+				if err != nil {
+					fmt.Printf("%s %v\n", errorMsg("[Error]"), err)
+					os.Exit(1)
+				}
+				types = append(types, qtype)
+			}
+
+			if names != nil && (trace || consistency || dnssec || watch || explain || isTransfer(types)) {
+				fmt.Printf("%s batch mode supports plain lookups only\n", errorMsg("[Error]"))
+				os.Exit(1)
+			}
+
+			if explain {
+				explanation, err := network.ExplainSystemLookup(domain)
+				if err != nil {
+					fmt.Printf("%s %v\n", errorMsg("[Error]"), err)
+					os.Exit(1)
+				}
+				printExplanation(explanation)
+				fmt.Println()
+			}
+
+			if trace {
+				qtype := network.TypeA
+				if len(types) > 0 {
+					qtype = types[0]
+				}
+				tracer := &network.Tracer{}
+				printTraceResult(tracer.Trace(cmd.Context(), domain, qtype))
+				return
+			}
+
+			if watch {
+				if len(expect) == 0 {
+					fmt.Printf("%s --watch needs the --expect values\n", errorMsg("[Error]"))
+					os.Exit(1)
+				}
+				watchDig(cmd.Context(), types)
+				return
+			}
+
+			client, err := newDigClient(server)
+			if err != nil {
+				fmt.Printf("%s %v\n", errorMsg("[Error]"), err)
+				os.Exit(1)
+			}
+			querier, system := digQuerier(cmd, client)
+			if names != nil {
+				first := true
+				summary := network.DigBatch(cmd.Context(), querier, names, workers, func(result *network.DigResult) {
+					if !first {
+						fmt.Println()
+					}
+					first = false
+					fmt.Printf(";; %s\n", result.Domain)
+					printDigResult(result, nil)
+				}, types...)
+				fmt.Printf("\n;; %d names: %s succeeded, %s failed\n", summary.Total,
+					color.GreenString("%d", summary.Succeeded), color.RedString("%d", summary.Failed))
+				if summary.Failed > 0 {
+					os.Exit(1)
+				}
+				return
+			}
+			if consistency {
+				var lookup network.HostLookup = network.NetHostLookup{}
+				if server != "" {
+					lookup = client
+				}
+				checker := &network.ConsistencyChecker{Lookup: lookup}
+				result, err := checker.Check(cmd.Context(), domain, types...)
+				if err != nil {
+					fmt.Printf("%s %v\n", errorMsg("[Error]"), err)
+					os.Exit(1)
+				}
+				printConsistencyResult(result)
+				return
+			}
+
+			if isTransfer(types) {
+				xfr, err := client.Transfer(cmd.Context(), domain, types[0], ixfrSerial)
+				if err != nil {
+					color.Red("%s for %s failed, status: %s\n", types[0], domain, network.ErrorStatus(err))
+					os.Exit(1)
+				}
+				printZoneTransfer(xfr)
+				return
+			}
+
+			if dnssec {
+				client.DNSSEC = true
+				if len(types) == 0 {
+					types = network.DefaultDigTypes
+				}
+				validator := &network.Validator{Querier: client}
+				for i, qtype := range types {
+					if i > 0 {
+						fmt.Println()
+					}
+					printDNSSECResult(validator.Validate(cmd.Context(), domain, qtype))
+				}
+				return
+			}
+
+			if reverseAddr != "" {
+				var lookup network.HostLookup = client
+				if system {
+					lookup = network.NetHostLookup{}
+				}
+				result, err := network.ReverseLookup(lookup, reverseAddr)
+				if err != nil {
+					fmt.Printf("%s %v\n", errorMsg("[Error]"), err)
+					os.Exit(1)
+				}
+				printDigResult(result, nil)
+				return
+			}
+
+			result := network.Dig(cmd.Context(), querier, domain, types...)
+			var chain *cnameChain
+			if result.Section(network.TypeCNAME) != nil && !system {
+				c, err := network.FollowCNAME(cmd.Context(), client, domain, cnameLimit)
+				chain = &cnameChain{c, err}
+			}
+			printDigResult(result, chain)
 		},
 	}
 )
 
 func init() {
 	rootCmd.AddCommand(digCmd)
+
+	digCmd.Flags().StringVarP(&queryType, "type", "t", "", "record type to query (A, AAAA, MX, SRV, CAA, ANY, ...)")
+	digCmd.Flags().StringVarP(&reverseAddr, "reverse", "x", "", "IP address to look up PTR records for")
+	digCmd.Flags().BoolVar(&trace, "trace", false, "resolve iteratively from the root servers")
+	digCmd.Flags().BoolVar(&consistency, "consistency", false, "compare the answers of every authoritative nameserver")
+	digCmd.Flags().BoolVar(&useTLS, "tls", false, "query @server over DNS-over-TLS")
+	digCmd.Flags().StringVar(&httpsURL, "https", "", "DNS-over-HTTPS endpoint URL to query")
+	digCmd.Flags().StringVar(&httpsMethod, "https-method", "POST", "HTTP method for DNS-over-HTTPS (GET|POST)")
+	digCmd.Flags().BoolVar(&useTCP, "tcp", false, "query over TCP instead of UDP")
+	digCmd.Flags().StringVarP(&namesFile, "file", "f", "", "read names to look up from a file, one per line (- for stdin)")
+	digCmd.Flags().IntVar(&workers, "workers", network.DefaultBatchWorkers, "number of concurrent lookups in batch mode")
+	digCmd.Flags().IntVar(&cnameLimit, "max-cname", network.DefaultCNAMELimit, "flag CNAME chains with more hops than this")
+	digCmd.Flags().BoolVar(&dnssec, "dnssec", false, "validate answers against the DNSSEC chain of trust")
+	digCmd.Flags().Uint32Var(&ixfrSerial, "serial", 0, "zone serial already held, for IXFR")
+	digCmd.Flags().Uint16Var(&bufSize, "bufsize", network.DefaultUDPSize, "EDNS(0) UDP buffer size to advertise, 0 disables EDNS")
+	digCmd.Flags().StringVar(&subnet, "subnet", "", "EDNS Client Subnet to send, such as 198.51.100.0/24")
+	digCmd.Flags().BoolVar(&nsid, "nsid", false, "ask the server for its name server identifier (NSID)")
+	digCmd.Flags().BoolVar(&cookie, "cookie", false, "send an EDNS client cookie")
+	digCmd.Flags().BoolVar(&explain, "explain", false, "show how the system resolver would look up the name before querying")
+	digCmd.Flags().BoolVar(&watch, "watch", false, "re-query until every @server answers with the --expect values")
+	digCmd.Flags().StringSliceVar(&expect, "expect", nil, "values the answer must consist of in --watch mode, may be repeated")
+	digCmd.Flags().DurationVar(&interval, "interval", network.DefaultWatchInterval, "time between queries in --watch mode")
+	digCmd.Flags().DurationVar(&deadline, "deadline", 10*time.Minute, "give up --watch after this long")
+	digCmd.Flags().StringVar(&zoneFile, "zonefile", "", "zone file to parse and print")
+	digCmd.Flags().BoolVar(&verifyZone, "verify", false, "compare --zonefile with its authoritative nameservers")
+}
+
+// parseDigArgs splits dig style arguments into the server, domain and type.
+// Like dig, the type may come before the domain, and ixfr=SERIAL requests
+// an incremental transfer from SERIAL.
+func parseDigArgs(args []string) {
+	for i, arg := range args {
+		if strings.HasPrefix(arg, "@") {
+			server = arg
+			servers = append(servers, arg)
+		} else if s, ok := strings.CutPrefix(strings.ToLower(arg), "ixfr="); ok && queryType == "" {
+			serial, err := strconv.ParseUint(s, 10, 32)
+			if err != nil {
+				fmt.Printf("%s invalid IXFR serial: %s\n", errorMsg("[Error]"), s)
+				os.Exit(1)
+			}
+			queryType, ixfrSerial = network.TypeIXFR.String(), uint32(serial)
+		} else if _, err := network.ParseType(arg); err == nil && queryType == "" && (domain != "" || i < len(args)-1) {
+			queryType = arg
+		} else if domain == "" {
+			domain = arg
+		} else {
+			moreDomains = append(moreDomains, arg)
+		}
+	}
+}
+
+// batchNames returns the names to look up in batch mode: the domain
+// arguments followed by the names read from --file, or from stdin when it
+// is piped and no domain was given. It returns nil for a single lookup.
+func batchNames() ([]string, error) {
+	var r io.Reader
+	switch {
+	case namesFile == "-":
+		r = os.Stdin
+	case namesFile != "":
+		f, err := os.Open(namesFile)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	case domain == "" && stdinPiped():
+		r = os.Stdin
+	}
+
+	var names []string
+	if domain != "" {
+		names = append(append(names, domain), moreDomains...)
+	}
+	if r != nil {
+		read, err := network.ReadNames(r)
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, read...)
+	}
+	if r == nil && len(names) < 2 {
+		return nil, nil
+	}
+	return names, nil
+}
+
+// isTransfer reports whether the requested type is a zone transfer.
+func isTransfer(types []network.Type) bool {
+	return len(types) > 0 && (types[0] == network.TypeAXFR || types[0] == network.TypeIXFR)
+}
+
+// stdinPiped reports whether stdin is a pipe or file rather than a terminal.
+func stdinPiped() bool {
+	info, err := os.Stdin.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice == 0
+}
+
+// stdoutTerminal reports whether stdout is a terminal that can be redrawn.
+func stdoutTerminal() bool {
+	info, err := os.Stdout.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// newDigClient returns a DNS client for server over the transport selected
+// by the flags.
+func newDigClient(server string) (*network.DNSClient, error) {
+	var client *network.DNSClient
+	switch {
+	case httpsURL != "":
+		method := strings.ToUpper(httpsMethod)
+		if method != http.MethodGet && method != http.MethodPost {
+			return nil, fmt.Errorf("invalid DNS-over-HTTPS method: %s", httpsMethod)
+		}
+		client = network.NewHTTPSClient(httpsURL, method)
+	case useTLS:
+		if server == "" {
+			return nil, errors.New("--tls requires a @server")
+		}
+		client = network.NewTLSClient(server)
+	default:
+		client = network.NewDNSClient(server)
+		if useTCP {
+			client.Transport = network.TCPTransport{}
+		}
+	}
+	client.UDPSize = bufSize
+	if subnet != "" {
+		ecs, err := network.ParseClientSubnet(subnet)
+		if err != nil {
+			return nil, err
+		}
+		client.Options = append(client.Options, ecs.Option())
+	}
+	if nsid {
+		client.Options = append(client.Options, network.NSIDOption())
+	}
+	if cookie {
+		client.Options = append(client.Options, network.CookieOption())
+	}
+	return client, nil
+}
+
+// digQuerier returns the querier for plain and batch lookups: the system
+// resolver, with client for the types it cannot look up, unless a @server or
+// an option that needs the wire protocol was given. It reports whether the
+// system resolver is used, whose answers cannot be followed hop by hop.
+func digQuerier(cmd *cobra.Command, client *network.DNSClient) (network.Querier, bool) {
+	if server != "" || httpsURL != "" || useTLS || useTCP || subnet != "" || nsid || cookie || cmd.Flags().Changed("bufsize") {
+		return client, false
+	}
+	return network.HostLookupQuerier{Lookup: network.NetHostLookup{}, Fallback: client}, true
+}
+
+// cnameChain is a followed CNAME chain and the error that cut it short.
+type cnameChain struct {
+	*network.CNAMEChain
+	err error
+}
+
+// printDigResult renders each section of a DigResult in dig's answer format,
+// showing the CNAME section as the full chain when one was followed.
+func printDigResult(result *network.DigResult, chain *cnameChain) {
+	for i, section := range result.Sections {
+		if i > 0 {
+			fmt.Println()
+		}
+		switch {
+		case section.Err != nil:
+			color.Red("%s lookup for %s failed, status: %s\n", section.Type, result.Domain, section.Status())
+		case len(section.Records) == 0:
+			color.Yellow("No %s records found for: %s (status: %s)\n", section.Type, result.Domain, section.Status())
+		default:
+			color.Green("%s records for %s (status: %s):\n", section.Type, result.Domain, section.Status())
+			for _, rr := range section.Records {
+				color.Cyan(rr.String())
+			}
+		}
+		if section.Transport != "" {
+			via := section.Transport
+			if section.TCPFallback {
+				via = "tcp (udp answer truncated)"
+			}
+			fmt.Printf(";; from %s via %s in %s\n", section.Server, via, section.RTT.Round(time.Microsecond))
+		}
+		if edns := section.EDNS; edns != nil && (len(edns.Options) > 0 || subnet != "" || nsid || cookie) {
+			printEDNS(edns)
+		}
+		if section.Type == network.TypeCNAME && chain != nil && len(chain.Hops) > 0 {
+			printCNAMEChain(chain)
+		}
+	}
+}
+
+// printEDNS prints the OPT pseudo-section of a response like dig.
+func printEDNS(edns *network.EDNS) {
+	flags := ""
+	if edns.DNSSECOK {
+		flags = " do"
+	}
+	fmt.Printf(";; EDNS: version %d, flags:%s; udp: %d\n", edns.Version, flags, edns.UDPSize)
+	for _, o := range edns.Options {
+		fmt.Printf(";; %s\n", dataMsg(o.String()))
+	}
+}
+
+// printCNAMEChain prints each hop of a CNAME chain with its TTL, then the
+// address records of the canonical name.
+func printCNAMEChain(chain *cnameChain) {
+	color.Green("CNAME chain for %s (%d hops):\n", chain.Name, len(chain.Hops))
+	for i, hop := range chain.Hops {
+		color.Cyan("%2d. %s -> %s (TTL %d)", i+1, hop.Name, hop.Target, hop.TTL)
+	}
+	switch {
+	case chain.Loop:
+		color.Red("CNAME loop: %s points back into the chain\n", chain.Canonical)
+	case chain.err != nil:
+		color.Red("chain broken at %s, status: %s\n", chain.Canonical, network.ErrorStatus(chain.err))
+	case len(chain.Records) == 0:
+		color.Yellow("No A or AAAA records at %s\n", chain.Canonical)
+	default:
+		for _, rr := range chain.Records {
+			color.Cyan(rr.String())
+		}
+	}
+	if chain.TooLong() {
+		color.Yellow("Warning: chain has %d hops, more than the limit of %d\n", len(chain.Hops), chain.Limit)
+	}
+}
+
+// printDNSSECResult shows a validated answer followed by its chain of trust.
+func printDNSSECResult(result *network.DNSSECResult) {
+	status := dnssecColor(result.Status)
+	status("%s records for %s (status: %s, dnssec: %s)\n", result.Type, result.Name, result.RCode, result.Status)
+	for _, rr := range result.Answer {
+		color.Cyan(rr.String())
+	}
+	status("%s: %s\n", result.Status, result.Reason)
+	fmt.Println("Chain of trust:")
+	for _, zone := range result.Chain {
+		dnssecColor(zone.Status)("  %-24s %-8s %d DS, %d DNSKEY: %s\n", zone.Zone, zone.Status, len(zone.DS), len(zone.Keys), zone.Reason)
+	}
+}
+
+func dnssecColor(status network.DNSSECStatus) func(format string, a ...interface{}) {
+	switch status {
+	case network.DNSSECSecure:
+		return color.Green
+	case network.DNSSECInsecure:
+		return color.Yellow
+	}
+	return color.Red
+}
+
+// printZoneTransfer prints a transferred zone sorted in zone file format, or
+// the changes of an incremental transfer, so that runs can be diffed.
+func printZoneTransfer(xfr *network.ZoneTransfer) {
+	fmt.Printf("; %s of %s from %s, serial %d\n", xfr.Type, xfr.Zone, xfr.Server, xfr.Serial)
+	if !xfr.Incremental {
+		fmt.Printf("$ORIGIN %s\n", xfr.Zone)
+		network.SortRecords(xfr.Records)
+		for _, rr := range xfr.Records {
+			fmt.Println(rr.String())
+		}
+	} else if len(xfr.Deltas) == 0 {
+		fmt.Printf("; serial %d is current\n", ixfrSerial)
+	}
+	for _, delta := range xfr.Deltas {
+		fmt.Printf("; serial %d -> %d\n", delta.FromSerial, delta.ToSerial)
+		network.SortRecords(delta.Deleted)
+		for _, rr := range delta.Deleted {
+			color.Red("-%s", rr.String())
+		}
+		network.SortRecords(delta.Added)
+		for _, rr := range delta.Added {
+			color.Green("+%s", rr.String())
+		}
+	}
+	// The timing goes to stderr so that two dumps of the same zone diff
+	// clean.
+	fmt.Fprintf(os.Stderr, "; %d messages in %s\n", xfr.Messages, xfr.Duration.Round(time.Millisecond))
+}
+
+// printExplanation shows the system resolver configuration that applies
+// to a lookup, the names it would query and where its answer comes from.
+func printExplanation(e *network.ResolverExplanation) {
+	color.Green("System resolver lookup of %s:\n", e.Name)
+	if len(e.Conf.Nameservers) == 0 {
+		fmt.Printf(";; nameservers: none, 127.0.0.1 is used\n")
+	}
+	for i, ns := range e.Conf.Nameservers {
+		note := ""
+		if i >= network.MaxNameservers {
+			note = color.YellowString(" (ignored, only the first %d are used)", network.MaxNameservers)
+		}
+		fmt.Printf(";; nameserver %s%s\n", dataMsg(ns), note)
+	}
+	fmt.Printf(";; search: %s\n", dataMsg(listOrNone(e.Conf.Search)))
+	fmt.Printf(";; ndots: %d, timeout: %s, attempts: %d", e.Conf.Ndots, e.Conf.Timeout, e.Conf.Attempts)
+	if len(e.Conf.Options) > 0 {
+		fmt.Printf(", options: %s", strings.Join(e.Conf.Options, " "))
+	}
+	fmt.Println()
+	var sources []string
+	for _, source := range e.Sources {
+		sources = append(sources, source.String())
+	}
+	fmt.Printf(";; hosts: %s\n", dataMsg(strings.Join(sources, " ")))
+	if len(e.HostsAddrs) > 0 {
+		fmt.Printf(";; /etc/hosts: %s\n", dataMsg(strings.Join(e.HostsAddrs, " ")))
+	} else {
+		fmt.Printf(";; /etc/hosts: no entry\n")
+	}
+	fmt.Printf(";; DNS candidates:\n")
+	for i, name := range e.Candidates {
+		fmt.Printf(";; %2d. %s\n", i+1, name)
+	}
+
+	switch e.Source {
+	case network.SourceFiles:
+		color.Yellow("Applications get %s from /etc/hosts, not DNS\n", strings.Join(e.HostsAddrs, ", "))
+	case network.SourceDNS:
+		color.Cyan("Applications query DNS for each candidate in turn until one exists\n")
+	default:
+		color.Red("No hosts source answers %s, so applications fail to resolve it\n", e.Name)
+	}
+	fmt.Printf(";; dig queries %s as given, without the search list or /etc/hosts\n", network.Fqdn(e.Name))
+}
+
+// printTraceResult renders each hop of an iterative resolution.
+func printTraceResult(result *network.TraceResult) {
+	for _, hop := range result.Hops {
+		server := hop.Server
+		if hop.ServerName != "" {
+			server = fmt.Sprintf("%s (%s)", hop.ServerName, hop.Server)
+		}
+		if hop.Err != nil {
+			color.Red("Zone %s: no response from %s: %v\n", hop.Zone, server, hop.Err)
+			continue
+		}
+		color.Green("Zone %s: answered by %s in %s, status: %s\n", hop.Zone, server, hop.RTT.Round(time.Microsecond), hop.RCode)
+		for _, rr := range hop.Answer {
+			color.Cyan(rr.String())
+		}
+		for _, rr := range hop.Referral {
+			color.Cyan(rr.String())
+		}
+		for _, rr := range hop.Glue {
+			color.Cyan(rr.String())
+		}
+		fmt.Println()
+	}
+	if result.Err != nil {
+		color.Red("Trace for %s %s failed, status: %s\n", result.Name, result.Type, network.ErrorStatus(result.Err))
+	} else if len(result.Answer) == 0 {
+		color.Yellow("No %s records found for: %s\n", result.Type, result.Name)
+	}
+}
+
+// printConsistencyResult lists each nameserver's SOA serial and, per type,
+// whether the servers agree or which ones returned something different.
+func printConsistencyResult(result *network.ConsistencyResult) {
+	color.Green("Nameservers for zone %s:\n", result.Zone)
+	for _, sa := range result.Servers {
+		if sa.Err != nil {
+			color.Red("%s: %v\n", sa.Nameserver, sa.Err)
+			continue
+		}
+		if sa.SerialErr != nil {
+			color.Red("%s (%s) serial unknown, SOA status: %s\n", sa.Nameserver, sa.Server, network.ErrorStatus(sa.SerialErr))
+			continue
+		}
+		color.Cyan("%s (%s) serial %d\n", sa.Nameserver, sa.Server, sa.Serial)
+	}
+
+	for _, tc := range result.Types {
+		fmt.Println()
+		if tc.Consistent() {
+			color.Green("%s records for %s: consistent across all nameservers\n", tc.Type, result.Domain)
+			if len(tc.Sets) > 0 {
+				printAnswerSet(tc.Sets[0])
+			}
+			continue
+		}
+		color.Red("%s records for %s: nameservers disagree\n", tc.Type, result.Domain)
+		for i, set := range tc.Sets {
+			if i == 0 {
+				color.Green("Returned by %s:\n", strings.Join(set.Servers, ", "))
+			} else {
+				color.Yellow("Differs on %s:\n", strings.Join(set.Servers, ", "))
+			}
+			printAnswerSet(set)
+		}
+	}
+}
+
+func printAnswerSet(set network.AnswerSet) {
+	if len(set.Records) == 0 {
+		color.Cyan("  (no records, status: %s)\n", set.Status)
+	}
+	for _, record := range set.Records {
+		color.Cyan("  %s\n", record)
+	}
+}
+
+// watchDig queries domain against each @server every --interval until all
+// of them answer with the --expect values, redrawing a status line per
+// resolver when stdout is a terminal. It exits 1 when --deadline passes
+// first.
+func watchDig(ctx context.Context, types []network.Type) {
+	targets := servers
+	if len(targets) == 0 || httpsURL != "" {
+		targets = []string{server}
+	}
+	var resolvers []network.WatchResolver
+	for _, s := range targets {
+		client, err := newDigClient(s)
+		if err != nil {
+			fmt.Printf("%s %v\n", errorMsg("[Error]"), err)
+			os.Exit(1)
+		}
+		resolvers = append(resolvers, network.WatchResolver{Server: client.Server, Querier: client})
+	}
+	w := &network.Watch{Name: domain, Expect: expect, Interval: interval}
+	if len(types) > 0 {
+		w.Type = types[0]
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, deadline)
+	defer cancel()
+	live := stdoutTerminal()
+	start := time.Now()
+	fmt.Printf("Watching %s for %s every %s, giving up after %s\n", dataMsg(domain), dataMsg(strings.Join(expect, ", ")), interval, deadline)
+	var last []*network.WatchStatus
+	ok := w.Run(ctx, resolvers, func(round int, statuses []*network.WatchStatus) {
+		clear := ""
+		if live {
+			clear = "\033[2K"
+			if round > 1 {
+				fmt.Printf("\033[%dA", len(statuses)+1)
+			}
+		} else {
+			fmt.Println()
+		}
+		fmt.Printf("%sround %d, %s elapsed\n", clear, round, time.Since(start).Round(time.Second))
+		for _, s := range statuses {
+			fmt.Print(clear)
+			printWatchStatus(s, start)
+		}
+		last = statuses
+	})
+
+	matched := 0
+	for _, s := range last {
+		if s.Match {
+			matched++
+		}
+	}
+	if !ok {
+		fmt.Printf("%s %d of %d resolvers answer as expected after %s\n", errorMsg("[Error]"), matched, len(resolvers), deadline)
+		os.Exit(1)
+	}
+	fmt.Printf("%s all %d resolvers answer as expected after %s\n", successMsg("[Success]"), len(resolvers), time.Since(start).Round(time.Second))
+}
+
+func printWatchStatus(s *network.WatchStatus, start time.Time) {
+	switch {
+	case s.Err != nil:
+		color.Red("  [error] %s: %s\n", s.Server, network.ErrorStatus(s.Err))
+	case s.Match:
+		color.Green("  [match] %s: %s (ttl %d, since %s)\n", s.Server, strings.Join(s.Values, ", "), s.TTL, s.MatchedAt.Sub(start).Round(time.Second))
+	case len(s.Values) == 0:
+		color.Yellow("  [wait]  %s: no records\n", s.Server)
+	default:
+		color.Yellow("  [wait]  %s: %s (ttl %d)\n", s.Server, strings.Join(s.Values, ", "), s.TTL)
+	}
+}
+
+// zoneFileDig parses --zonefile and prints it, or with --verify compares it
+// with what the zone's nameservers serve and exits 1 on any drift.
+func zoneFileDig(ctx context.Context) {
+	origin := domain
+	if origin == "" {
+		origin = strings.TrimSuffix(strings.TrimPrefix(filepath.Base(zoneFile), "db."), ".zone")
+	}
+	f, err := os.Open(zoneFile)
+	if err != nil {
+		fmt.Printf("%s %v\n", errorMsg("[Error]"), err)
+		os.Exit(1)
+	}
+	zone, err := network.ParseZoneFile(f, origin)
+	f.Close()
+	if err != nil {
+		fmt.Printf("%s %s: %v\n", errorMsg("[Error]"), zoneFile, err)
+		os.Exit(1)
+	}
+
+	if !verifyZone {
+		fmt.Printf("; %d records from %s\n$ORIGIN %s\n", len(zone.Records), zoneFile, zone.Origin)
+		network.SortRecords(zone.Records)
+		for _, rr := range zone.Records {
+			fmt.Println(rr.String())
+		}
+		return
+	}
+
+	var lookup network.HostLookup = network.NetHostLookup{}
+	if server != "" {
+		lookup = network.NewDNSClient(server)
+	}
+	checker := &network.ConsistencyChecker{Lookup: lookup}
+	result, err := checker.VerifyZone(ctx, zone)
+	if err != nil {
+		fmt.Printf("%s %v\n", errorMsg("[Error]"), err)
+		os.Exit(1)
+	}
+	printZoneVerifyResult(result)
+	if !result.InSync() {
+		os.Exit(1)
+	}
+}
+
+// printZoneVerifyResult lists the drift found on each nameserver.
+func printZoneVerifyResult(result *network.ZoneVerifyResult) {
+	fmt.Printf("Verifying %d RRsets of %s on %d nameservers\n", result.RRsets, dataMsg(result.Zone), len(result.Servers))
+	for _, s := range result.Servers {
+		if s.Err != nil {
+			color.Red("\n%s: %v\n", s.Nameserver, s.Err)
+			continue
+		}
+		fmt.Printf("\n%s (%s):\n", s.Nameserver, s.Server)
+		if len(s.Drift) == 0 && len(s.Errs) == 0 {
+			color.Green("  in sync\n")
+		}
+		for _, d := range s.Drift {
+			switch d.Kind {
+			case network.DriftMissing:
+				color.Red("  missing %s\n", d.Record.String())
+			case network.DriftExtra:
+				color.Yellow("  extra   %s\n", d.Record.String())
+			case network.DriftTTL:
+				color.Yellow("  ttl     %s (served with TTL %d)\n", d.Record.String(), d.LiveTTL)
+			}
+		}
+		for _, err := range s.Errs {
+			color.Red("  failed  %v\n", err)
+		}
+	}
 }
 
 func interactiveDig() {
@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/catpaladin/net-tools/pkg/network"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	benchQueries int
+	benchType    string
+	benchFile    string
+	benchTimeout time.Duration
+
+	// dnsbenchCmd represents the dnsbench command
+	dnsbenchCmd = &cobra.Command{
+		Use:   "dnsbench [@server ...] name [name ...]",
+		Short: "Compares the response times of DNS resolvers",
+		Long: `Compares the response times of DNS resolvers
+
+Each @server, or the first nameserver in /etc/resolv.conf when none is given,
+is sent the same sequence of queries, cycling through the names. Queries use
+the same path as dig. The report shows min/avg/p50/p95/p99 latency and the
+timeout rate per resolver, and compares the first query for each name, which
+a caching resolver may have to resolve, with the repeats it should answer
+from its cache.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			var servers, names []string
+			for _, arg := range args {
+				if strings.HasPrefix(arg, "@") {
+					servers = append(servers, arg)
+				} else {
+					names = append(names, arg)
+				}
+			}
+			if benchFile != "" {
+				f, err := os.Open(benchFile)
+				if err != nil {
+					fmt.Printf("%s %v\n", errorMsg("[Error]"), err)
+					os.Exit(1)
+				}
+				read, err := network.ReadNames(f)
+				f.Close()
+				if err != nil {
+					fmt.Printf("%s %v\n", errorMsg("[Error]"), err)
+					os.Exit(1)
+				}
+				names = append(names, read...)
+			}
+			if len(names) == 0 {
+				fmt.Printf("%s no names to query\n", errorMsg("[Error]"))
+				os.Exit(1)
+			}
+			if len(servers) == 0 {
+				servers = []string{""}
+			}
+			qtype, err := network.ParseType(benchType)
+			if err != nil {
+				fmt.Printf("%s %v\n", errorMsg("[Error]"), err)
+				os.Exit(1)
+			}
+
+			var resolvers []network.BenchResolver
+			for _, s := range servers {
+				client := network.NewDNSClient(s)
+				client.Timeout = benchTimeout
+				resolvers = append(resolvers, network.BenchResolver{Server: client.Server, Querier: client})
+			}
+			bench := &network.Bench{Names: names, Type: qtype, Queries: benchQueries}
+			fmt.Printf("Sending %d %s queries over %d names to each of %d resolvers\n\n", bench.Queries, dataMsg(qtype), len(names), len(resolvers))
+			printBenchResults(bench.Run(cmd.Context(), resolvers))
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(dnsbenchCmd)
+	dnsbenchCmd.Flags().IntVarP(&benchQueries, "queries", "n", network.DefaultBenchQueries, "number of queries per resolver")
+	dnsbenchCmd.Flags().StringVarP(&benchType, "type", "t", "A", "record type to query")
+	dnsbenchCmd.Flags().StringVarP(&benchFile, "file", "f", "", "read names to query from a file, one per line")
+	dnsbenchCmd.Flags().DurationVar(&benchTimeout, "timeout", 2*time.Second, "time to wait for each answer")
+}
+
+// printBenchResults prints a latency table and a first vs repeat query
+// comparison, one row per resolver.
+func printBenchResults(results []*network.BenchResult) {
+	ms := func(d time.Duration) string {
+		return fmt.Sprintf("%.1f", float64(d)/float64(time.Millisecond))
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "resolver\tsent\tok\ttimeout\terrors\tmin ms\tavg ms\tp50 ms\tp95 ms\tp99 ms\t")
+	for _, r := range results {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%.1f%%\t%d\t%s\t%s\t%s\t%s\t%s\t\n", r.Server, r.Sent, r.All.Count, 100*r.TimeoutRate(), r.Errors,
+			ms(r.All.Min), ms(r.All.Avg), ms(r.All.P50), ms(r.All.P95), ms(r.All.P99))
+	}
+	w.Flush()
+
+	fmt.Println()
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "resolver\tfirst avg ms\tfirst p50 ms\trepeat avg ms\trepeat p50 ms\t")
+	for _, r := range results {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t\n", r.Server, ms(r.First.Avg), ms(r.First.P50), ms(r.Repeat.Avg), ms(r.Repeat.P50))
+	}
+	w.Flush()
+}
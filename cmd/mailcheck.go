@@ -0,0 +1,229 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/catpaladin/net-tools/pkg/network"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	dkimSelectors []string
+	probeMX       bool
+	smtpTimeout   time.Duration
+	heloName      string
+
+	// mailcheckCmd represents the mailcheck command
+	mailcheckCmd = &cobra.Command{
+		Use:   "mailcheck [@server] domain",
+		Short: "Checks the SPF, DMARC and DKIM records of a domain",
+		Long: `Checks the SPF, DMARC and DKIM records of a domain
+
+The SPF record is fetched and every include and redirect is expanded
+recursively, counting the DNS lookups against the limit of 10 that receivers
+enforce. The DMARC record at _dmarc.domain and the DKIM key of each
+--dkim-selector are parsed into their fields.
+
+Syntax errors and risky settings such as +all, p=none or short DKIM keys are
+reported as errors, warnings or notes. The command exits non-zero when any
+error is found. Use @server to query a specific DNS server.
+
+With --mx the mail exchangers are sorted by preference and resolved, and the
+first address of each is probed on ports 25, 465 and 587: the SMTP banner is
+read, EHLO is sent with this host's name, or --helo, and the STARTTLS support and certificate are reported.`,
+		Args: cobra.RangeArgs(1, 2),
+		Run: func(cmd *cobra.Command, args []string) {
+			var domain, server string
+			for _, arg := range args {
+				if strings.HasPrefix(arg, "@") {
+					server = arg
+				} else {
+					domain = arg
+				}
+			}
+			if domain == "" {
+				fmt.Printf("%s no domain given\n", errorMsg("[Error]"))
+				os.Exit(1)
+			}
+
+			var lookup network.HostLookup = network.NetHostLookup{}
+			if server != "" {
+				lookup = network.NewDNSClient(server)
+			}
+			checker := &network.MailChecker{Lookup: lookup, Timeout: smtpTimeout, HeloName: heloName}
+			report := checker.Check(domain, dkimSelectors...)
+			if probeMX {
+				ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+				report.MX = checker.ProbeMX(ctx, domain)
+				stop()
+			}
+			printMailReport(report)
+			for _, f := range report.Findings() {
+				if f.Severity == network.SeverityError {
+					os.Exit(1)
+				}
+			}
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(mailcheckCmd)
+	mailcheckCmd.Flags().StringSliceVarP(&dkimSelectors, "dkim-selector", "s", nil, "DKIM selector to check, may be repeated")
+	mailcheckCmd.Flags().BoolVar(&probeMX, "mx", false, "connect to each mail exchanger and check SMTP and STARTTLS")
+	mailcheckCmd.Flags().DurationVar(&smtpTimeout, "timeout", network.DefaultSMTPTimeout, "time to wait for each SMTP server")
+	mailcheckCmd.Flags().StringVar(&heloName, "helo", "", "name to send with EHLO (default this host's name)")
+}
+
+// printMailReport prints the SPF include tree, the DMARC and DKIM fields
+// and every finding, colored by severity.
+func printMailReport(report *network.MailReport) {
+	fmt.Printf("SPF for %s:\n", report.Domain)
+	if report.SPF.Record != nil {
+		printSPFTree(report.SPF, "  ")
+		lookups := color.GreenString("%d", report.SPF.Lookups)
+		if report.SPF.Lookups > network.SPFLookupLimit {
+			lookups = color.RedString("%d", report.SPF.Lookups)
+		}
+		fmt.Printf("  DNS lookups: %s of %d\n", lookups, network.SPFLookupLimit)
+	}
+	printFindings(report.SPF.AllFindings())
+
+	fmt.Printf("\nDMARC at %s:\n", report.DMARC.Domain)
+	if r := report.DMARC.Record; r != nil {
+		fmt.Printf("  %s\n", dataMsg(r.Raw))
+		subdomain := r.SubdomainPolicy
+		if subdomain == "" {
+			subdomain = r.Policy + " (inherited)"
+		}
+		fmt.Printf("  policy: %s, subdomains: %s, pct: %d\n", r.Policy, subdomain, r.Percent)
+		fmt.Printf("  alignment: dkim %s, spf %s\n", alignment(r.DKIMAlignment), alignment(r.SPFAlignment))
+		fmt.Printf("  aggregate reports: %s\n", listOrNone(r.AggregateURIs))
+		fmt.Printf("  failure reports: %s\n", listOrNone(r.FailureURIs))
+	}
+	printFindings(report.DMARC.Findings)
+
+	for _, d := range report.DKIM {
+		fmt.Printf("\nDKIM selector %s at %s:\n", d.Selector, d.Domain)
+		if r := d.Record; r != nil {
+			fmt.Printf("  key type: %s", r.KeyType)
+			if r.KeyBits > 0 {
+				fmt.Printf(", %d bits", r.KeyBits)
+			}
+			fmt.Println()
+			if len(r.Hashes) > 0 {
+				fmt.Printf("  hashes: %s\n", strings.Join(r.Hashes, ", "))
+			}
+			if len(r.Flags) > 0 {
+				fmt.Printf("  flags: %s\n", strings.Join(r.Flags, ", "))
+			}
+		}
+		printFindings(d.Findings)
+	}
+
+	if report.MX != nil {
+		printMXResult(report.MX)
+	}
+}
+
+// printMXResult prints each mail exchanger in preference order with the
+// result of probing its SMTP ports.
+func printMXResult(result *network.MXResult) {
+	fmt.Printf("\nMX for %s:\n", result.Domain)
+	for _, host := range result.Hosts {
+		label := fmt.Sprintf("%d %s", host.Pref, host.Host)
+		if host.Implicit {
+			label = host.Host + " (implicit MX)"
+		}
+		if host.Err != nil {
+			color.Red("  %s: %s\n", label, network.ErrorStatus(host.Err))
+			continue
+		}
+		fmt.Printf("  %s (%s)\n", label, dataMsg(strings.Join(host.Addrs, ", ")))
+		for _, p := range host.Probes {
+			if p.Err != nil {
+				color.Red("    %-4d %v\n", p.Port, p.Err)
+				continue
+			}
+			starttls := color.GreenString("yes")
+			switch {
+			case p.ImplicitTLS:
+				starttls = "n/a (implicit TLS)"
+			case !p.STARTTLS:
+				starttls = color.YellowString("no")
+			}
+			fmt.Printf("    %-4d %s (%s)\n", p.Port, successMsg(p.Banner), p.RTT.Round(time.Millisecond))
+			fmt.Printf("         STARTTLS: %s\n", starttls)
+			if p.TLS != nil {
+				printTLSInfo(p.TLS)
+			}
+		}
+	}
+	printFindings(result.Findings)
+}
+
+func printTLSInfo(info *network.TLSInfo) {
+	fmt.Printf("         %s, %s\n", info.Version, info.CipherSuite)
+	fmt.Printf("         subject: %s\n", info.Subject)
+	if len(info.DNSNames) > 0 {
+		fmt.Printf("         names: %s\n", strings.Join(info.DNSNames, ", "))
+	}
+	fmt.Printf("         issuer: %s\n", info.Issuer)
+	fmt.Printf("         valid: %s to %s\n", info.NotBefore.Format(time.DateOnly), info.NotAfter.Format(time.DateOnly))
+	if info.VerifyErr != nil {
+		color.Yellow("         untrusted: %v\n", info.VerifyErr)
+	} else {
+		color.Green("         trusted\n")
+	}
+}
+
+// printSPFTree prints an SPF record and, indented below it, the records it
+// includes.
+func printSPFTree(result *network.SPFResult, indent string) {
+	label := result.Domain
+	if result.Via != "" {
+		label = result.Via + ":" + result.Domain
+	}
+	if result.Record == nil {
+		color.Red("%s%s (no record)\n", indent, label)
+		return
+	}
+	fmt.Printf("%s%s (%d lookups)\n", indent, label, result.Lookups)
+	fmt.Printf("%s  %s\n", indent, dataMsg(result.Record.Raw))
+	for _, inc := range result.Includes {
+		printSPFTree(inc, indent+"  ")
+	}
+}
+
+func printFindings(findings []network.MailFinding) {
+	for _, f := range findings {
+		switch f.Severity {
+		case network.SeverityError:
+			color.Red("  [error] %s\n", f.Message)
+		case network.SeverityWarning:
+			color.Yellow("  [warning] %s\n", f.Message)
+		default:
+			color.Cyan("  [info] %s\n", f.Message)
+		}
+	}
+}
+
+func alignment(mode string) string {
+	if mode == "s" {
+		return "strict"
+	}
+	return "relaxed"
+}
+
+func listOrNone(list []string) string {
+	if len(list) == 0 {
+		return "none"
+	}
+	return strings.Join(list, ", ")
+}
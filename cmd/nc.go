@@ -1,28 +1,87 @@
 package cmd
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log"
 	"net"
+	"os"
+	"os/signal"
 	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/catpaladin/net-tools/pkg/network"
 	"github.com/charmbracelet/huh"
+	"github.com/fatih/color"
 
 	"github.com/spf13/cobra"
 )
 
 var (
-	host string
-	port string
+	host        string
+	port        string
+	scanWorkers int
+	ncTimeout   time.Duration
+	udp         bool
+	udpPayload  string
+	interactive bool
+	listen      bool
+	listenEcho  bool
+	listenDrop  bool
+	serveFile   string
 
 	// ncCmd represents the nc command
 	ncCmd = &cobra.Command{
 		Use:   "nc",
 		Short: "Netcat subcommand to test host and port to see if open",
-		Long:  "Netcat subcommand to test host and port to see if open",
-		Run: func(cmd *cobra.Command, args []string) {
+		Long: `Netcat subcommand to test host and port to see if open
+
+The port can be a list of ports and ranges, such as 20-25,80,443,8000-8100,
+to scan them all with --workers connections in flight at once. Each port is
+reported open, closed when the connection is refused, or filtered when
+nothing answers before the timeout, as when a firewall drops the packets.
+
+Each connection attempt gives up after --timeout, and Ctrl-C cancels the
+attempts in flight.
+
+With -u the port is probed over UDP: a datagram is sent and the port is
+reported open when a reply comes back, closed when the host answers with an
+ICMP port unreachable, or open|filtered when nothing comes back before the
+timeout. The payload is one the service on a well-known port answers, such
+as a DNS, NTP or SNMP request, or --payload, which understands Go string
+escapes such as \n and \x00. Services that never answer, such as syslog on
+514 and StatsD on 8125, get an empty datagram so the probe does not log a
+line or bump a counter; they show up as open|filtered at best.
+
+With --interactive the connection stays open like netcat: stdin is sent to
+the port and whatever comes back is written to stdout until the peer closes
+the connection. At the end of stdin the sending side is shut down, so the
+peer sees EOF and can still reply. Status messages go to stderr, keeping
+stdout for the data.
+
+With -l nc listens instead, on TCP or with -u on UDP: nc -l 9000 binds every
+address, nc -l 127.0.0.1 9000 only one. Each connection, or each datagram
+over UDP, is logged on stderr with its peer address and byte counts. The
+data received is written to stdout, or with --echo sent back, with
+--discard thrown away, or with --serve FILE answered with the file. Run
+against the client side on another host, this tests a firewall path end to
+end.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			switch {
+			case interactive && udp:
+				return errors.New("--interactive works over TCP only and cannot be combined with -u")
+			case interactive && listen:
+				return errors.New("--interactive and -l cannot be combined")
+			}
+			if listen {
+				ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+				defer stop()
+				listenNetcat(ctx, args)
+				return nil
+			}
 			if len(args) < 2 {
 				interactiveNetcat()
 			} else {
@@ -30,19 +89,224 @@ var (
 				port = args[1]
 			}
 
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+			defer stop()
+			if udp {
+				probeUDP(ctx)
+				return nil
+			}
+			if interactive {
+				pipeNetcat(ctx)
+				return nil
+			}
+			if strings.ContainsAny(port, ",-") {
+				scanPorts(ctx)
+				return nil
+			}
+
 			fmt.Printf("Testing %s:%s\n", dataMsg(host), dataMsg(port))
-			err := network.Netcat(host, port)
-			if err != nil {
+			err := network.Netcat(ctx, host, port, ncTimeout)
+			switch {
+			case ctx.Err() != nil:
+				fmt.Printf("%s Interrupted\n", errorMsg("[Error]"))
+				os.Exit(130)
+			case err != nil:
 				fmt.Printf("%s Error connecting to %s:%s - %v\n", errorMsg("[Error]"), host, port, err)
-			} else {
+				os.Exit(1)
+			default:
 				fmt.Printf("%s Connection to %s:%s successful\n", successMsg("[Success]"), host, port)
 			}
+			return nil
 		},
 	}
 )
 
 func init() {
 	rootCmd.AddCommand(ncCmd)
+	ncCmd.Flags().DurationVarP(&ncTimeout, "timeout", "t", network.DefaultDialTimeout, "time to wait for each connection")
+	ncCmd.Flags().BoolVarP(&udp, "udp", "u", false, "probe a UDP port")
+	ncCmd.Flags().StringVar(&udpPayload, "payload", "", "datagram to send with -u instead of the port's default")
+	ncCmd.Flags().BoolVar(&interactive, "interactive", false, "pipe stdin to the connection and the connection to stdout")
+	ncCmd.Flags().BoolVarP(&listen, "listen", "l", false, "listen for connections on [host] port")
+	ncCmd.Flags().BoolVar(&listenEcho, "echo", false, "with -l, send the data received back")
+	ncCmd.Flags().BoolVar(&listenDrop, "discard", false, "with -l, throw the data received away")
+	ncCmd.Flags().StringVar(&serveFile, "serve", "", "with -l, send `FILE` to each peer")
+	ncCmd.MarkFlagsMutuallyExclusive("echo", "discard", "serve")
+	ncCmd.Flags().IntVarP(&scanWorkers, "workers", "w", network.DefaultScanWorkers, "number of ports to probe at once when scanning")
+}
+
+// scanPorts probes the list of ports and prints a table of their states,
+// exiting 1 when none is open.
+func scanPorts(ctx context.Context) {
+	ports, err := network.ParsePorts(port)
+	if err != nil {
+		fmt.Printf("%s %v\n", errorMsg("[Error]"), err)
+		os.Exit(1)
+	}
+	fmt.Printf("Scanning %s ports of %s\n", dataMsg(len(ports)), dataMsg(host))
+	scanner := &network.PortScanner{Workers: scanWorkers, Timeout: ncTimeout}
+	results := scanner.Scan(ctx, host, ports)
+	if ctx.Err() != nil {
+		fmt.Printf("%s Scan interrupted\n", errorMsg("[Error]"))
+		os.Exit(130)
+	}
+
+	counts := make(map[network.PortState]int)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PORT\tSTATE\tTIME\tREASON")
+	for _, r := range results {
+		counts[r.State]++
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", r.Port, portStateMsg(r.State), r.RTT.Round(time.Millisecond), portReason(r))
+	}
+	w.Flush()
+	fmt.Printf("\n%d ports: %s open, %s closed, %s filtered\n", len(results),
+		color.GreenString("%d", counts[network.PortOpen]), color.RedString("%d", counts[network.PortClosed]),
+		color.YellowString("%d", counts[network.PortFiltered]))
+	if counts[network.PortOpen] == 0 {
+		os.Exit(1)
+	}
+}
+
+// listenNetcat serves connections on the [host] port in args until
+// interrupted, logging each one on stderr.
+func listenNetcat(ctx context.Context, args []string) {
+	var address string
+	switch len(args) {
+	case 1:
+		address = net.JoinHostPort("", args[0])
+	case 2:
+		address = net.JoinHostPort(args[0], args[1])
+	default:
+		fmt.Fprintf(os.Stderr, "%s -l takes a port, or a host and port\n", errorMsg("[Error]"))
+		os.Exit(1)
+	}
+	server := &network.NetcatServer{Network: "tcp", Address: address, Output: os.Stdout}
+	if udp {
+		server.Network = "udp"
+	}
+	switch {
+	case listenEcho:
+		server.Mode = network.ListenEcho
+	case listenDrop:
+		server.Mode = network.ListenDiscard
+	case serveFile != "":
+		server.Mode, server.File = network.ListenServeFile, serveFile
+	}
+	server.Log = func(entry network.ConnLog) {
+		if !entry.Closed {
+			fmt.Fprintf(os.Stderr, "%s Connection from %s\n", successMsg("[Accept]"), dataMsg(entry.Peer))
+			return
+		}
+		label, summary := successMsg("[Closed]"), fmt.Sprintf("%d bytes received, %d sent", entry.Received, entry.Sent)
+		if entry.Network == "udp" {
+			label = successMsg("[Datagram]")
+		} else {
+			summary += " in " + entry.Duration.Round(time.Millisecond).String()
+		}
+		if entry.Err != nil {
+			label, summary = errorMsg("[Error]"), fmt.Sprintf("%s - %v", summary, entry.Err)
+		}
+		fmt.Fprintf(os.Stderr, "%s %s %s: %s\n", label, entry.Network, dataMsg(entry.Peer), summary)
+	}
+
+	fmt.Fprintf(os.Stderr, "Listening on %s %s (%s)\n", server.Network, dataMsg(address), server.Mode)
+	if err := server.ListenAndServe(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "%s %v\n", errorMsg("[Error]"), err)
+		os.Exit(1)
+	}
+}
+
+// pipeNetcat connects and pipes stdin and stdout through the connection,
+// reporting on stderr.
+func pipeNetcat(ctx context.Context) {
+	if strings.ContainsAny(port, ",-") {
+		fmt.Fprintf(os.Stderr, "%s --interactive takes a single port, got %s\n", errorMsg("[Error]"), port)
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "Connecting to %s:%s\n", dataMsg(host), dataMsg(port))
+	stats, err := network.NetcatInteractive(ctx, host, port, ncTimeout, os.Stdin, os.Stdout)
+	switch {
+	case ctx.Err() != nil:
+		fmt.Fprintf(os.Stderr, "%s Interrupted after %d bytes sent, %d received\n", errorMsg("[Error]"), stats.Sent, stats.Received)
+		os.Exit(130)
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "%s Error with %s:%s - %v\n", errorMsg("[Error]"), host, port, err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "%s Connection closed: %d bytes sent, %d received\n", successMsg("[Success]"), stats.Sent, stats.Received)
+}
+
+// probeUDP sends a datagram to the UDP port and reports its state from the
+// reply, exiting 1 when the port is closed.
+func probeUDP(ctx context.Context) {
+	portNum, err := strconv.Atoi(port)
+	if err != nil {
+		fmt.Printf("%s UDP probing takes a single port, got %s\n", errorMsg("[Error]"), port)
+		os.Exit(1)
+	}
+	payload := network.DefaultUDPPayload(portNum)
+	if udpPayload != "" {
+		s, err := strconv.Unquote(`"` + strings.ReplaceAll(udpPayload, `"`, `\"`) + `"`)
+		if err != nil {
+			fmt.Printf("%s invalid payload: %v\n", errorMsg("[Error]"), err)
+			os.Exit(1)
+		}
+		payload = []byte(s)
+	}
+
+	fmt.Printf("Probing udp %s:%s with a %d byte payload\n", dataMsg(host), dataMsg(port), len(payload))
+	result, err := network.NetcatUDP(ctx, host, port, payload, ncTimeout)
+	switch {
+	case ctx.Err() != nil:
+		fmt.Printf("%s Interrupted\n", errorMsg("[Error]"))
+		os.Exit(130)
+	case err != nil:
+		fmt.Printf("%s Error probing %s:%s - %v\n", errorMsg("[Error]"), host, port, err)
+		os.Exit(1)
+	}
+	switch result.State {
+	case network.PortOpen:
+		reply := result.Reply
+		if len(reply) > 64 {
+			reply = reply[:64]
+		}
+		fmt.Printf("%s udp %s:%s is open: %d byte reply in %s\n", successMsg("[Success]"), host, port,
+			len(result.Reply), result.RTT.Round(time.Millisecond))
+		fmt.Printf("%s\n", dataMsg(strconv.Quote(string(reply))))
+	case network.PortClosed:
+		fmt.Printf("%s udp %s:%s is closed (ICMP port unreachable)\n", errorMsg("[Error]"), host, port)
+		os.Exit(1)
+	default:
+		color.Yellow("[Warning] udp %s:%s is open|filtered: no reply within %s\n", host, port, ncTimeout)
+	}
+}
+
+func portStateMsg(state network.PortState) string {
+	switch state {
+	case network.PortOpen:
+		return color.GreenString(state.String())
+	case network.PortClosed:
+		return color.RedString(state.String())
+	}
+	return color.YellowString(state.String())
+}
+
+// portReason explains a closed or filtered port from its dial error.
+func portReason(r network.PortResult) string {
+	var netErr net.Error
+	switch {
+	case r.Err == nil:
+		return "connected"
+	case r.State == network.PortClosed:
+		return "connection refused"
+	case errors.As(r.Err, &netErr) && netErr.Timeout():
+		return "no response"
+	}
+	var opErr *net.OpError
+	if errors.As(r.Err, &opErr) {
+		return opErr.Err.Error()
+	}
+	return r.Err.Error()
 }
 
 func interactiveNetcat() {
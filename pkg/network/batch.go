@@ -0,0 +1,94 @@
+package network
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"strings"
+	"sync"
+)
+
+// DefaultBatchWorkers is the number of names DigBatch looks up at once.
+const DefaultBatchWorkers = 10
+
+// BatchSummary counts the outcome of a batch of lookups. A name fails
+// when any of its queries failed.
+type BatchSummary struct {
+	Total     int
+	Succeeded int
+	Failed    int
+}
+
+// Failed reports whether any query for the domain failed.
+func (r *DigResult) Failed() bool {
+	for _, s := range r.Sections {
+		if s.Err != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// ReadNames reads one domain name per line, skipping blank lines and
+// comments starting with '#' or ';'.
+func ReadNames(r io.Reader) ([]string, error) {
+	var names []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexAny(line, "#;"); i >= 0 {
+			line = line[:i]
+		}
+		if fields := strings.Fields(line); len(fields) > 0 {
+			names = append(names, fields[0])
+		}
+	}
+	return names, scanner.Err()
+}
+
+// DigBatch digs each name on a fixed pool of workers goroutines, so that at
+// most workers lookups are in flight, and calls emit with the results in the
+// order of names, as soon as each result and all those before it are ready.
+func DigBatch(ctx context.Context, q Querier, names []string, workers int, emit func(*DigResult), types ...Type) BatchSummary {
+	if workers <= 0 {
+		workers = DefaultBatchWorkers
+	}
+	results := make([]*DigResult, len(names))
+	indexes := make(chan int)
+	finished := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(workers, len(names)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results[i] = Dig(ctx, q, names[i], types...)
+				finished <- i
+			}
+		}()
+	}
+	go func() {
+		for i := range names {
+			indexes <- i
+		}
+		close(indexes)
+		wg.Wait()
+		close(finished)
+	}()
+
+	summary := BatchSummary{Total: len(names)}
+	ready := make([]bool, len(names))
+	next := 0
+	for i := range finished {
+		ready[i] = true
+		for ; next < len(names) && ready[next]; next++ {
+			if results[next].Failed() {
+				summary.Failed++
+			} else {
+				summary.Succeeded++
+			}
+			emit(results[next])
+		}
+	}
+	return summary
+}
@@ -0,0 +1,65 @@
+package network
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadNames(t *testing.T) {
+	input := "example.com\n\n# migrated hosts\nwww.example.com  ; old web\n  api.example.com\n"
+	names, err := ReadNames(strings.NewReader(input))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"example.com", "www.example.com", "api.example.com"}, names)
+}
+
+func TestDigBatch(t *testing.T) {
+	var inFlight, maxInFlight, peakGoroutines atomic.Int32
+	goroutines := runtime.NumGoroutine()
+	mockQuerier := MockQuerier{
+		QueryFunc: func(ctx context.Context, name string, qtype Type) (*Response, error) {
+			if g := int32(runtime.NumGoroutine()); g > peakGoroutines.Load() {
+				peakGoroutines.Store(g)
+			}
+			n := inFlight.Add(1)
+			defer inFlight.Add(-1)
+			for {
+				m := maxInFlight.Load()
+				if n <= m || maxInFlight.CompareAndSwap(m, n) {
+					break
+				}
+			}
+			// Later names answer first so that ordering is exercised.
+			var i int
+			fmt.Sscanf(name, "host%d.example.com", &i)
+			time.Sleep(time.Duration(20-i%20) * time.Millisecond)
+			if strings.HasPrefix(name, "host1") {
+				return nil, &DNSError{Name: name, Type: qtype, RCode: RCodeNameError, IsNotFound: true}
+			}
+			return &Response{Message: &Message{}}, nil
+		},
+	}
+
+	var names []string
+	for i := 0; i < 100; i++ {
+		names = append(names, fmt.Sprintf("host%d.example.com", i))
+	}
+	var got []string
+	summary := DigBatch(context.Background(), mockQuerier, names, 4, func(r *DigResult) {
+		got = append(got, r.Domain)
+	}, TypeA)
+
+	assert.Equal(t, names, got)
+	assert.LessOrEqual(t, maxInFlight.Load(), int32(4))
+	// Four workers, each with one query goroutine, and the feeder rather
+	// than a goroutine per name.
+	assert.LessOrEqual(t, int(peakGoroutines.Load()), goroutines+9)
+	// host1 and host10 to host19 fail.
+	assert.Equal(t, BatchSummary{Total: 100, Succeeded: 89, Failed: 11}, summary)
+}
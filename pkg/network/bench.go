@@ -0,0 +1,162 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultBenchQueries is the number of queries sent to each resolver.
+const DefaultBenchQueries = 50
+
+// Bench sends the same sequence of queries to several resolvers and
+// compares how quickly they answer.
+type Bench struct {
+	// Names are queried in turn until Queries queries have been sent.
+	Names []string
+	// Type is the record type queried, A when zero.
+	Type Type
+	// Queries is the number of queries per resolver, DefaultBenchQueries
+	// when zero.
+	Queries int
+	// Measure sends one query to r and returns how long the answer took;
+	// nil times r.Querier.Query with the wall clock.
+	Measure func(ctx context.Context, r BenchResolver, name string, qtype Type) (time.Duration, error)
+}
+
+// BenchResolver is a resolver to benchmark and the label to report it by.
+type BenchResolver struct {
+	Server  string
+	Querier Querier
+}
+
+// LatencyStats summarises a set of query latencies.
+type LatencyStats struct {
+	Count int
+	Min   time.Duration
+	Avg   time.Duration
+	P50   time.Duration
+	P95   time.Duration
+	P99   time.Duration
+	Max   time.Duration
+}
+
+// BenchResult holds one resolver's measurements. Latencies are of answered
+// queries only; First covers the first query of each name, which a caching
+// resolver may have to resolve, and Repeat the queries after it, which it
+// should answer from its cache.
+type BenchResult struct {
+	Server   string
+	Sent     int
+	Timeouts int
+	// Errors counts failures other than timeouts. Negative answers such as
+	// NXDOMAIN are answers, not errors.
+	Errors int
+	All    LatencyStats
+	First  LatencyStats
+	Repeat LatencyStats
+}
+
+// TimeoutRate returns the fraction of queries that timed out.
+func (r *BenchResult) TimeoutRate() float64 {
+	if r.Sent == 0 {
+		return 0
+	}
+	return float64(r.Timeouts) / float64(r.Sent)
+}
+
+// Run benchmarks the resolvers concurrently, sending each one's queries in
+// sequence so that they do not queue behind one another. Results are in
+// the order of resolvers.
+func (b *Bench) Run(ctx context.Context, resolvers []BenchResolver) []*BenchResult {
+	results := make([]*BenchResult, len(resolvers))
+	var wg sync.WaitGroup
+	for i, r := range resolvers {
+		wg.Add(1)
+		go func(i int, r BenchResolver) {
+			defer wg.Done()
+			results[i] = b.run(ctx, r)
+		}(i, r)
+	}
+	wg.Wait()
+	return results
+}
+
+func (b *Bench) run(ctx context.Context, r BenchResolver) *BenchResult {
+	qtype := b.Type
+	if qtype == 0 {
+		qtype = TypeA
+	}
+	queries := b.Queries
+	if queries <= 0 {
+		queries = DefaultBenchQueries
+	}
+
+	measure := b.Measure
+	if measure == nil {
+		measure = measureQuery
+	}
+
+	result := &BenchResult{Server: r.Server}
+	var all, first, repeat []time.Duration
+	seen := make(map[string]bool)
+	for i := 0; i < queries && len(b.Names) > 0 && ctx.Err() == nil; i++ {
+		name := b.Names[i%len(b.Names)]
+		result.Sent++
+		elapsed, err := measure(ctx, r, name, qtype)
+
+		var dnsErr *DNSError
+		switch {
+		case errors.As(err, &dnsErr) && dnsErr.IsTimeout:
+			result.Timeouts++
+			continue
+		case err != nil:
+			result.Errors++
+			continue
+		}
+		all = append(all, elapsed)
+		if seen[name] {
+			repeat = append(repeat, elapsed)
+		} else {
+			first = append(first, elapsed)
+		}
+		seen[name] = true
+	}
+	result.All, result.First, result.Repeat = latencyStats(all), latencyStats(first), latencyStats(repeat)
+	return result
+}
+
+// measureQuery times one query with the wall clock.
+func measureQuery(ctx context.Context, r BenchResolver, name string, qtype Type) (time.Duration, error) {
+	start := time.Now()
+	_, err := r.Querier.Query(ctx, name, qtype)
+	return time.Since(start), err
+}
+
+// latencyStats computes the summary of ds using nearest-rank percentiles.
+func latencyStats(ds []time.Duration) LatencyStats {
+	if len(ds) == 0 {
+		return LatencyStats{}
+	}
+	sorted := append([]time.Duration(nil), ds...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	var total time.Duration
+	for _, d := range sorted {
+		total += d
+	}
+	rank := func(p int) time.Duration {
+		i := (p*len(sorted) + 99) / 100
+		return sorted[max(i-1, 0)]
+	}
+	return LatencyStats{
+		Count: len(sorted),
+		Min:   sorted[0],
+		Avg:   total / time.Duration(len(sorted)),
+		P50:   rank(50),
+		P95:   rank(95),
+		P99:   rank(99),
+		Max:   sorted[len(sorted)-1],
+	}
+}
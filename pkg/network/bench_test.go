@@ -0,0 +1,108 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// cachingResolver reports miss for the first query of a name and hit for
+// later ones, like a resolver with a cache, without waiting for either.
+func cachingResolver(miss, hit time.Duration) func(name string) time.Duration {
+	var mu sync.Mutex
+	cached := make(map[string]bool)
+	return func(name string) time.Duration {
+		mu.Lock()
+		defer mu.Unlock()
+		delay := hit
+		if !cached[name] {
+			delay = miss
+		}
+		cached[name] = true
+		return delay
+	}
+}
+
+func TestBench(t *testing.T) {
+	latency := map[string]func(string) time.Duration{
+		"fast": cachingResolver(40*time.Millisecond, time.Millisecond),
+		"slow": cachingResolver(20*time.Millisecond, 20*time.Millisecond),
+	}
+	bench := &Bench{
+		Names:   []string{"example.com", "www.example.com", "missing.example.com"},
+		Queries: 12,
+		Measure: func(ctx context.Context, r BenchResolver, name string, qtype Type) (time.Duration, error) {
+			assert.Equal(t, TypeA, qtype)
+			switch r.Server {
+			case "dead":
+				return 20 * time.Millisecond, &DNSError{Name: name, Type: qtype, RCode: RCodeServerFailure, IsTimeout: true}
+			case "broken":
+				return time.Millisecond, errors.New("connection refused")
+			}
+			return latency[r.Server](name), nil
+		},
+	}
+	results := bench.Run(context.Background(), []BenchResolver{{Server: "fast"}, {Server: "slow"}, {Server: "dead"}, {Server: "broken"}})
+	assert.Len(t, results, 4)
+
+	r := results[0]
+	assert.Equal(t, "fast", r.Server)
+	assert.Equal(t, 12, r.Sent)
+	assert.Equal(t, 12, r.All.Count)
+	assert.Equal(t, 3, r.First.Count)
+	assert.Equal(t, 9, r.Repeat.Count)
+	assert.Equal(t, 40*time.Millisecond, r.First.Min)
+	assert.Equal(t, time.Millisecond, r.Repeat.P50)
+	assert.Equal(t, 40*time.Millisecond, r.All.Max)
+	assert.Equal(t, 10750*time.Microsecond, r.All.Avg)
+
+	r = results[1]
+	assert.Equal(t, 20*time.Millisecond, r.All.Min)
+	assert.Equal(t, 20*time.Millisecond, r.All.P50)
+
+	r = results[2]
+	assert.Equal(t, 12, r.Timeouts)
+	assert.Equal(t, 1.0, r.TimeoutRate())
+	assert.Zero(t, r.All.Count)
+
+	r = results[3]
+	assert.Equal(t, 12, r.Errors)
+	assert.Zero(t, r.Timeouts)
+}
+
+func TestBenchQueries(t *testing.T) {
+	// The default measurement sends real queries; only the counts are
+	// checked, never the timings.
+	live := NewDNSClient(startTestDNSServer(t, testRecords.handle))
+	dead := NewDNSClient(startTestDNSServer(t, func(req *Message) *Message { return nil }))
+	dead.Timeout = 20 * time.Millisecond
+
+	bench := &Bench{Names: []string{"example.com", "missing.example.com"}, Queries: 4}
+	results := bench.Run(context.Background(), []BenchResolver{{Server: "live", Querier: live}, {Server: "dead", Querier: dead}})
+	assert.Equal(t, 4, results[0].All.Count)
+	assert.Equal(t, 2, results[0].Repeat.Count)
+	assert.Equal(t, 4, results[1].Timeouts)
+}
+
+func TestLatencyStats(t *testing.T) {
+	var ds []time.Duration
+	for i := 100; i >= 1; i-- {
+		ds = append(ds, time.Duration(i)*time.Millisecond)
+	}
+	stats := latencyStats(ds)
+	assert.Equal(t, LatencyStats{
+		Count: 100,
+		Min:   time.Millisecond,
+		Avg:   50500 * time.Microsecond,
+		P50:   50 * time.Millisecond,
+		P95:   95 * time.Millisecond,
+		P99:   99 * time.Millisecond,
+		Max:   100 * time.Millisecond,
+	}, stats)
+	assert.Equal(t, LatencyStats{}, latencyStats(nil))
+	assert.Equal(t, 7*time.Millisecond, latencyStats([]time.Duration{7 * time.Millisecond}).P99)
+}
@@ -0,0 +1,112 @@
+package network
+
+import (
+	"context"
+	"strings"
+)
+
+// DefaultCNAMELimit is the chain length past which FollowCNAME flags a
+// chain as too long. Resolvers commonly give up at around this depth.
+const DefaultCNAMELimit = 8
+
+// maxCNAMEHops bounds the walk however high the limit is set.
+const maxCNAMEHops = 64
+
+// CNAMEHop is one alias in a CNAME chain.
+type CNAMEHop struct {
+	Name   string
+	Target string
+	TTL    uint32
+}
+
+// CNAMEChain is the result of following the aliases of a name to the
+// canonical name and its address records.
+type CNAMEChain struct {
+	Name string
+	Hops []CNAMEHop
+	// Canonical is the name the chain ends at.
+	Canonical string
+	// Records are the A and AAAA records of the canonical name.
+	Records []RR
+	// Loop is set when a hop points back to an earlier name.
+	Loop  bool
+	Limit int
+}
+
+// TooLong reports whether the chain has more hops than its limit.
+func (c *CNAMEChain) TooLong() bool {
+	return c.Limit > 0 && len(c.Hops) > c.Limit
+}
+
+// FollowCNAME walks the CNAME chain of name hop by hop, using the aliases a
+// resolver returns with the A answer and querying again where the answer
+// stops short, then looks up the A and AAAA records at the end of the
+// chain. The chain found so far is returned with any error.
+func FollowCNAME(ctx context.Context, q Querier, name string, limit int) (*CNAMEChain, error) {
+	chain := &CNAMEChain{Name: Fqdn(name), Canonical: Fqdn(name), Limit: limit}
+	seen := map[string]bool{strings.ToLower(chain.Name): true}
+
+	for {
+		resp, err := q.Query(ctx, chain.Canonical, TypeA)
+		if err != nil {
+			return chain, err
+		}
+		if err := rcodeError(resp, chain.Canonical, TypeA); err != nil {
+			return chain, err
+		}
+		advanced := false
+		for {
+			hop, ok := cnameHop(resp.Answer, chain.Canonical)
+			if !ok {
+				break
+			}
+			chain.Hops = append(chain.Hops, hop)
+			chain.Canonical = hop.Target
+			if seen[strings.ToLower(hop.Target)] {
+				chain.Loop = true
+				return chain, nil
+			}
+			if len(chain.Hops) >= maxCNAMEHops {
+				return chain, nil
+			}
+			seen[strings.ToLower(hop.Target)] = true
+			advanced = true
+		}
+		records := ownedRecords(resp.Answer, chain.Canonical, TypeA)
+		if len(records) > 0 || !advanced {
+			chain.Records = records
+			break
+		}
+	}
+
+	resp, err := q.Query(ctx, chain.Canonical, TypeAAAA)
+	if err != nil {
+		return chain, err
+	}
+	if err := rcodeError(resp, chain.Canonical, TypeAAAA); err != nil {
+		return chain, err
+	}
+	chain.Records = append(chain.Records, ownedRecords(resp.Answer, chain.Canonical, TypeAAAA)...)
+	return chain, nil
+}
+
+// cnameHop finds the CNAME record owned by name in rrs.
+func cnameHop(rrs []RR, name string) (CNAMEHop, bool) {
+	for _, rr := range rrs {
+		if cname, ok := rr.Data.(*CNAME); ok && strings.EqualFold(rr.Name, name) {
+			return CNAMEHop{Name: rr.Name, Target: cname.Target, TTL: rr.TTL}, true
+		}
+	}
+	return CNAMEHop{}, false
+}
+
+// ownedRecords returns the records of type qtype owned by name.
+func ownedRecords(rrs []RR, name string, qtype Type) []RR {
+	var out []RR
+	for _, rr := range rrs {
+		if rr.Type == qtype && strings.EqualFold(rr.Name, name) {
+			out = append(out, rr)
+		}
+	}
+	return out
+}
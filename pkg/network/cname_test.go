@@ -0,0 +1,81 @@
+package network
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func cnameRR(name, target string, ttl uint32) RR {
+	return RR{Name: name, Type: TypeCNAME, Class: ClassINET, TTL: ttl, Data: &CNAME{Target: target}}
+}
+
+func TestFollowCNAME(t *testing.T) {
+	zone := testZone{
+		cnameRR("www.example.com.", "edge.cdn.example.net.", 300),
+		cnameRR("edge.cdn.example.net.", "us-east.cdn.example.net.", 60),
+		cnameRR("us-east.cdn.example.net.", "origin.example.org.", 30),
+		{Name: "origin.example.org.", Type: TypeA, Class: ClassINET, TTL: 20, Data: &A{IP: net.ParseIP("192.0.2.7").To4()}},
+		{Name: "origin.example.org.", Type: TypeAAAA, Class: ClassINET, TTL: 20, Data: &AAAA{IP: net.ParseIP("2001:db8::7")}},
+		cnameRR("loop-a.example.com.", "loop-b.example.com.", 60),
+		cnameRR("loop-b.example.com.", "loop-a.example.com.", 60),
+		cnameRR("dangling.example.com.", "gone.example.com.", 60),
+	}
+	client := NewDNSClient(startTestDNSServer(t, zone.handle))
+
+	chain, err := FollowCNAME(context.Background(), client, "www.example.com", DefaultCNAMELimit)
+	assert.NoError(t, err)
+	assert.Equal(t, []CNAMEHop{
+		{Name: "www.example.com.", Target: "edge.cdn.example.net.", TTL: 300},
+		{Name: "edge.cdn.example.net.", Target: "us-east.cdn.example.net.", TTL: 60},
+		{Name: "us-east.cdn.example.net.", Target: "origin.example.org.", TTL: 30},
+	}, chain.Hops)
+	assert.Equal(t, "origin.example.org.", chain.Canonical)
+	assert.Len(t, chain.Records, 2)
+	assert.False(t, chain.Loop)
+	assert.False(t, chain.TooLong())
+
+	chain, err = FollowCNAME(context.Background(), client, "www.example.com", 2)
+	assert.NoError(t, err)
+	assert.True(t, chain.TooLong())
+
+	chain, err = FollowCNAME(context.Background(), client, "loop-a.example.com", DefaultCNAMELimit)
+	assert.NoError(t, err)
+	assert.True(t, chain.Loop)
+	assert.Len(t, chain.Hops, 2)
+
+	chain, err = FollowCNAME(context.Background(), client, "dangling.example.com", DefaultCNAMELimit)
+	assert.Equal(t, "NXDOMAIN", ErrorStatus(err))
+	assert.Len(t, chain.Hops, 1)
+}
+
+func TestFollowCNAMEHopByHop(t *testing.T) {
+	// An authoritative style server that returns one alias per answer.
+	var queries []string
+	mockQuerier := MockQuerier{
+		QueryFunc: func(ctx context.Context, name string, qtype Type) (*Response, error) {
+			queries = append(queries, fmt.Sprintf("%s %s", name, qtype))
+			msg := &Message{}
+			switch name {
+			case "a.example.com.":
+				msg.Answer = []RR{cnameRR(name, "b.example.com.", 10)}
+			case "b.example.com.":
+				msg.Answer = []RR{cnameRR(name, "c.example.com.", 20)}
+			case "c.example.com.":
+				if qtype == TypeA {
+					msg.Answer = []RR{{Name: name, Type: TypeA, Class: ClassINET, TTL: 5, Data: &A{IP: net.IPv4(192, 0, 2, 1)}}}
+				}
+			}
+			return &Response{Message: msg}, nil
+		},
+	}
+
+	chain, err := FollowCNAME(context.Background(), mockQuerier, "a.example.com", DefaultCNAMELimit)
+	assert.NoError(t, err)
+	assert.Len(t, chain.Hops, 2)
+	assert.Len(t, chain.Records, 1)
+	assert.Equal(t, []string{"a.example.com. A", "b.example.com. A", "c.example.com. A", "c.example.com. AAAA"}, queries)
+}
@@ -0,0 +1,208 @@
+package network
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ConsistencyChecker queries every authoritative nameserver of a zone
+// directly and compares their answers.
+type ConsistencyChecker struct {
+	// Lookup finds the zone's NS set and the nameserver addresses.
+	Lookup HostLookup
+	// Port is the port the nameservers are queried on, 53 when empty.
+	Port    string
+	Timeout time.Duration
+}
+
+// ServerAnswers holds what one authoritative nameserver returned.
+type ServerAnswers struct {
+	Nameserver string
+	Server     string
+	Serial     uint32
+	Records    map[Type][]RR
+	Errs       map[Type]error
+	// SerialErr is why the zone's SOA serial could not be read, in which
+	// case Serial is zero.
+	SerialErr error
+	// Err is set when the nameserver's address could not be found.
+	Err error
+}
+
+// AnswerSet is one distinct answer and the nameservers that returned it.
+type AnswerSet struct {
+	Records []string
+	Status  string
+	Servers []string
+}
+
+// TypeConsistency compares the answers for one record type. Sets holds one
+// entry per distinct answer, the most common first.
+type TypeConsistency struct {
+	Type Type
+	Sets []AnswerSet
+}
+
+// Consistent reports whether every nameserver returned the same answer.
+func (c TypeConsistency) Consistent() bool {
+	return len(c.Sets) <= 1
+}
+
+// ConsistencyResult is the outcome of comparing a zone's nameservers.
+type ConsistencyResult struct {
+	Domain  string
+	Zone    string
+	Servers []ServerAnswers
+	Types   []TypeConsistency
+}
+
+// Check finds the authoritative nameservers for domain's zone, queries each
+// for the given types plus SOA and groups the servers by their answers.
+func (c *ConsistencyChecker) Check(ctx context.Context, domain string, types ...Type) (*ConsistencyResult, error) {
+	if len(types) == 0 {
+		types = DefaultDigTypes
+	}
+	zone, nsHosts, err := c.findZone(domain)
+	if err != nil {
+		return nil, err
+	}
+	result := &ConsistencyResult{Domain: domain, Zone: zone, Servers: c.nameservers(nsHosts)}
+
+	var wg sync.WaitGroup
+	for i := range result.Servers {
+		if result.Servers[i].Err != nil {
+			continue
+		}
+		wg.Add(1)
+		go func(sa *ServerAnswers) {
+			defer wg.Done()
+			c.queryServer(ctx, sa, zone, domain, types)
+		}(&result.Servers[i])
+	}
+	wg.Wait()
+
+	for _, qtype := range types {
+		result.Types = append(result.Types, compareAnswers(result.Servers, qtype))
+	}
+	return result, nil
+}
+
+// nameservers resolves each nameserver host, returning one entry per
+// address, or one with Err set when the host has no address.
+func (c *ConsistencyChecker) nameservers(hosts []string) []ServerAnswers {
+	var servers []ServerAnswers
+	for _, host := range hosts {
+		addrs, err := c.Lookup.LookupHost(host)
+		if err != nil || len(addrs) == 0 {
+			servers = append(servers, ServerAnswers{Nameserver: host, Err: fmt.Errorf("no address for %s: %v", host, err)})
+			continue
+		}
+		for _, addr := range addrs {
+			servers = append(servers, ServerAnswers{Nameserver: host, Server: c.serverAddr(addr)})
+		}
+	}
+	return servers
+}
+
+// findZone walks up from domain until it finds a name with NS records,
+// using lookupNSRecords like dig does for the NS section.
+func (c *ConsistencyChecker) findZone(domain string) (string, []string, error) {
+	name := Fqdn(domain)
+	for {
+		nss, err := lookupNSRecords(c.Lookup, name)
+		if err == nil && len(nss) > 0 {
+			return name, nss, nil
+		}
+		dot := strings.IndexByte(name, '.')
+		if dot < 0 || dot == len(name)-1 {
+			return "", nil, fmt.Errorf("no NS records found for %s or its parents", domain)
+		}
+		name = name[dot+1:]
+	}
+}
+
+func (c *ConsistencyChecker) queryServer(ctx context.Context, sa *ServerAnswers, zone, domain string, types []Type) {
+	client := &DNSClient{Server: sa.Server, Timeout: c.Timeout}
+	sa.Records = make(map[Type][]RR)
+	sa.Errs = make(map[Type]error)
+
+	rrs, err := authoritativeRecords(ctx, client, zone, TypeSOA)
+	switch {
+	case err != nil:
+		sa.SerialErr = err
+	case len(rrs) == 0:
+		sa.SerialErr = fmt.Errorf("no SOA record for %s in the answer", zone)
+	default:
+		sa.Serial = rrs[0].Data.(*SOA).Serial
+	}
+	for _, qtype := range types {
+		rrs, err := authoritativeRecords(ctx, client, domain, qtype)
+		sa.Records[qtype] = rrs
+		sa.Errs[qtype] = err
+	}
+}
+
+// authoritativeRecords sends a non-recursive query and returns the answer
+// records of the requested type.
+func authoritativeRecords(ctx context.Context, client *DNSClient, name string, qtype Type) ([]RR, error) {
+	query := NewQuery(name, qtype)
+	query.RecursionDesired = false
+	resp, err := client.Exchange(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	if err := rcodeError(resp, name, qtype); err != nil {
+		return nil, err
+	}
+	var rrs []RR
+	for _, rr := range resp.Answer {
+		if rr.Type == qtype {
+			rrs = append(rrs, rr)
+		}
+	}
+	return rrs, nil
+}
+
+// compareAnswers groups the servers by the record data they returned for
+// qtype. TTLs are ignored so that only data differences are reported.
+func compareAnswers(servers []ServerAnswers, qtype Type) TypeConsistency {
+	result := TypeConsistency{Type: qtype}
+	index := make(map[string]int)
+	for _, sa := range servers {
+		if sa.Err != nil {
+			continue
+		}
+		var records []string
+		for _, rr := range sa.Records[qtype] {
+			records = append(records, rr.Data.String())
+		}
+		sort.Strings(records)
+		status := ErrorStatus(sa.Errs[qtype])
+		key := status + "\x00" + strings.Join(records, "\x00")
+
+		label := sa.Nameserver + " (" + sa.Server + ")"
+		if i, ok := index[key]; ok {
+			result.Sets[i].Servers = append(result.Sets[i].Servers, label)
+			continue
+		}
+		index[key] = len(result.Sets)
+		result.Sets = append(result.Sets, AnswerSet{Records: records, Status: status, Servers: []string{label}})
+	}
+	sort.SliceStable(result.Sets, func(i, j int) bool {
+		return len(result.Sets[i].Servers) > len(result.Sets[j].Servers)
+	})
+	return result
+}
+
+func (c *ConsistencyChecker) serverAddr(addr string) string {
+	port := c.Port
+	if port == "" {
+		port = dnsPort
+	}
+	return net.JoinHostPort(addr, port)
+}
@@ -0,0 +1,129 @@
+package network
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConsistencyChecker(t *testing.T) {
+	pc1, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, port, _ := net.SplitHostPort(pc1.LocalAddr().String())
+	pc2, err := net.ListenPacket("udp", net.JoinHostPort("127.0.0.2", port))
+	if err != nil {
+		pc1.Close()
+		t.Skipf("cannot bind a second loopback address: %v", err)
+	}
+
+	soa := func(serial uint32) RR {
+		return RR{Name: "example.com.", Type: TypeSOA, Class: ClassINET, TTL: 3600, Data: &SOA{MName: "ns1.example.com.", RName: "admin.example.com.", Serial: serial}}
+	}
+	a := func(ip string, ttl uint32) RR {
+		return RR{Name: "www.example.com.", Type: TypeA, Class: ClassINET, TTL: ttl, Data: &A{IP: net.ParseIP(ip).To4()}}
+	}
+	txt := RR{Name: "www.example.com.", Type: TypeTXT, Class: ClassINET, TTL: 300, Data: &TXT{Strings: []string{"hello"}}}
+
+	serveTestDNS(t, pc1, testZone{soa(2024060102), a("192.0.2.10", 300), txt}.handle)
+	serveTestDNS(t, pc2, testZone{soa(2024060101), a("192.0.2.20", 60), txt}.handle)
+
+	mockLookup := MockHostLookup{
+		LookupNSFunc: func(domain string) ([]*net.NS, error) {
+			if domain == "example.com." {
+				return []*net.NS{{Host: "ns1.example.com."}, {Host: "ns2.example.com."}}, nil
+			}
+			return nil, fmt.Errorf("no such host")
+		},
+		LookupHostFunc: func(domain string) ([]string, error) {
+			switch domain {
+			case "ns1.example.com.":
+				return []string{"127.0.0.1"}, nil
+			case "ns2.example.com.":
+				return []string{"127.0.0.2"}, nil
+			}
+			return nil, fmt.Errorf("no such host")
+		},
+	}
+
+	checker := &ConsistencyChecker{Lookup: mockLookup, Port: port, Timeout: time.Second}
+	result, err := checker.Check(context.Background(), "www.example.com", TypeA, TypeTXT)
+	assert.NoError(t, err)
+	assert.Equal(t, "example.com.", result.Zone)
+	assert.Len(t, result.Servers, 2)
+	assert.Equal(t, uint32(2024060102), result.Servers[0].Serial)
+	assert.Equal(t, uint32(2024060101), result.Servers[1].Serial)
+	assert.NoError(t, result.Servers[0].SerialErr)
+
+	assert.False(t, result.Types[0].Consistent())
+	assert.Len(t, result.Types[0].Sets, 2)
+	assert.Equal(t, []string{"192.0.2.10"}, result.Types[0].Sets[0].Records)
+	assert.Equal(t, []string{"ns1.example.com. (" + net.JoinHostPort("127.0.0.1", port) + ")"}, result.Types[0].Sets[0].Servers)
+
+	assert.True(t, result.Types[1].Consistent())
+	assert.Len(t, result.Types[1].Sets[0].Servers, 2)
+}
+
+func TestConsistencyCheckerSOAError(t *testing.T) {
+	addr := startTestDNSServer(t, func(req *Message) *Message {
+		resp := testRecords.handle(req)
+		if req.Question[0].Type == TypeSOA {
+			resp.RCode, resp.Answer = RCodeServerFailure, nil
+		}
+		return resp
+	})
+	host, port, _ := net.SplitHostPort(addr)
+	mockLookup := MockHostLookup{
+		LookupNSFunc: func(domain string) ([]*net.NS, error) {
+			return []*net.NS{{Host: "ns1.example.com."}}, nil
+		},
+		LookupHostFunc: func(domain string) ([]string, error) {
+			return []string{host}, nil
+		},
+	}
+
+	checker := &ConsistencyChecker{Lookup: mockLookup, Port: port, Timeout: time.Second}
+	result, err := checker.Check(context.Background(), "example.com", TypeA)
+	assert.NoError(t, err)
+	if assert.Len(t, result.Servers, 1) {
+		assert.Equal(t, uint32(0), result.Servers[0].Serial)
+		assert.Equal(t, "SERVFAIL", ErrorStatus(result.Servers[0].SerialErr))
+	}
+	assert.True(t, result.Types[0].Consistent())
+}
+
+func TestConsistencyCheckerNoZone(t *testing.T) {
+	mockLookup := MockHostLookup{
+		LookupNSFunc: func(domain string) ([]*net.NS, error) {
+			return nil, fmt.Errorf("no such host")
+		},
+	}
+
+	checker := &ConsistencyChecker{Lookup: mockLookup}
+	_, err := checker.Check(context.Background(), "www.example.invalid", TypeA)
+	assert.Error(t, err)
+}
+
+func TestCompareAnswers(t *testing.T) {
+	mx := func(pref uint16, host string) RR {
+		return RR{Name: "example.com.", Type: TypeMX, Class: ClassINET, TTL: 300, Data: &MX{Pref: pref, Host: host}}
+	}
+	servers := []ServerAnswers{
+		{Nameserver: "ns1.", Server: "192.0.2.1:53", Records: map[Type][]RR{TypeMX: {mx(10, "a."), mx(20, "b.")}}, Errs: map[Type]error{}},
+		{Nameserver: "ns2.", Server: "192.0.2.2:53", Records: map[Type][]RR{TypeMX: {mx(20, "b."), mx(10, "a.")}}, Errs: map[Type]error{}},
+		{Nameserver: "ns3.", Server: "192.0.2.3:53", Records: map[Type][]RR{}, Errs: map[Type]error{TypeMX: &DNSError{RCode: RCodeRefused}}},
+		{Nameserver: "ns4.", Err: fmt.Errorf("no address")},
+	}
+
+	result := compareAnswers(servers, TypeMX)
+	assert.False(t, result.Consistent())
+	assert.Len(t, result.Sets, 2)
+	assert.Equal(t, []string{"10 a.", "20 b."}, result.Sets[0].Records)
+	assert.Len(t, result.Sets[0].Servers, 2)
+	assert.Equal(t, "REFUSED", result.Sets[1].Status)
+}
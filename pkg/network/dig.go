@@ -1,72 +1,73 @@
 package network
 
 import (
+	"context"
 	"fmt"
 	"net"
-
-	"github.com/fatih/color"
+	"strings"
+	"sync"
+	"time"
 )
 
-// Dig takes a domain and performs DNS queries
-func Dig(domain string) {
-	nh := NetHostLookup{}
+// DefaultDigTypes are the record types queried when none are requested.
+var DefaultDigTypes = []Type{TypeA, TypeAAAA, TypeMX, TypeNS, TypeCNAME, TypeTXT}
+
+// DigResult holds the records found for a domain, one section per type.
+type DigResult struct {
+	Domain   string
+	Sections []DigSection
+}
+
+// DigSection holds the answer records of one record type. Err is set when
+// the query for this type failed and is a *DNSError for wire queries.
+// Server, Transport and RTT describe the response, when one was received.
+type DigSection struct {
+	Type      Type
+	Records   []RR
+	Err       error
+	Server    string
+	Transport string
+	RTT       time.Duration
+	// TCPFallback is set when a truncated UDP answer was retried over TCP.
+	TCPFallback bool
+	// EDNS is the response's decoded OPT record, nil without EDNS(0).
+	EDNS *EDNS
+}
+
+// Status returns the dig style status of the section's query, such as
+// "NOERROR", "NXDOMAIN" or "SERVFAIL (timeout after 5s)".
+func (s DigSection) Status() string {
+	return ErrorStatus(s.Err)
+}
 
-	// Perform a DNS lookup for the A records
-	ars := lookupARecords(nh, domain)
-	if len(ars) > 0 {
-		color.Green("A records for %s:\n", domain)
-		for _, ar := range ars {
-			color.Cyan(ar)
+// Section returns the section for qtype, or nil if it was not queried.
+func (r *DigResult) Section(qtype Type) *DigSection {
+	for i := range r.Sections {
+		if r.Sections[i].Type == qtype {
+			return &r.Sections[i]
 		}
-	} else {
-		color.Yellow("No A records found for: %s\n", domain)
 	}
-	fmt.Println()
+	return nil
+}
 
-	// Perform a DNS lookup for the MX records
-	mxs := lookupMXRecords(nh, domain)
-	if len(mxs) > 0 {
-		color.Green("MX records for %s:\n", domain)
-		for _, mx := range mxs {
-			color.Cyan(mx)
-		}
-	} else {
-		color.Yellow("No MX records found for: %s\n", domain)
+// Dig queries domain for each record type, or DefaultDigTypes when none are
+// given, and returns the records grouped by type in the order requested.
+func Dig(ctx context.Context, q Querier, domain string, types ...Type) *DigResult {
+	if len(types) == 0 {
+		types = DefaultDigTypes
 	}
-	fmt.Println()
+	result := &DigResult{Domain: domain, Sections: make([]DigSection, len(types))}
 
-	// Perform a DNS lookup for the NS records
-	nss := lookupNSRecords(nh, domain)
-	if len(nss) > 0 {
-		color.Green("NS records for %s:\n", domain)
-		for _, ns := range nss {
-			color.Cyan(ns)
-		}
-	} else {
-		color.Yellow("No NS records found for: %s\n", domain)
-	}
-	fmt.Println()
-
-	// Perform a DNS lookup for the CNAME record
-	cn := lookupCNAMERecord(nh, domain)
-	if cn != "" {
-		color.Green("CNAME record for %s:\n", domain)
-		color.Cyan(cn)
-	} else {
-		color.Yellow("No CNAME found for %s\n", domain)
-	}
-	fmt.Println()
-
-	// Perform a DNS lookup for the TXT records
-	txt := lookupTXTRecords(nh, domain)
-	if len(txt) > 0 {
-		color.Green("TXT records for %s:\n", domain)
-		for _, tx := range txt {
-			color.Cyan(tx)
-		}
-	} else {
-		color.Yellow("No TXT records found for: %s\n", domain)
+	var wg sync.WaitGroup
+	for i, qtype := range types {
+		wg.Add(1)
+		go func(i int, qtype Type) {
+			defer wg.Done()
+			result.Sections[i] = digSection(ctx, q, domain, qtype)
+		}(i, qtype)
 	}
+	wg.Wait()
+	return result
 }
 
 // HostLookup defines an interface for looking up hostnames.
@@ -76,6 +77,7 @@ type HostLookup interface {
 	LookupNS(domain string) ([]*net.NS, error)
 	LookupCNAME(domain string) (string, error)
 	LookupTXT(domain string) ([]string, error)
+	LookupAddr(addr string) ([]string, error)
 }
 
 // NetHostLookup is a concrete implementation of HostLookup using the net package.
@@ -86,13 +88,39 @@ func (n NetHostLookup) LookupHost(domain string) ([]string, error) {
 	return net.LookupHost(domain)
 }
 
-func lookupARecords(lookup HostLookup, domain string) []string {
-	var output []string
+func lookupARecords(lookup HostLookup, domain string) ([]RR, error) {
+	return lookupAddrs(lookup, domain, TypeA)
+}
+
+func lookupAAAARecords(lookup HostLookup, domain string) ([]RR, error) {
+	return lookupAddrs(lookup, domain, TypeAAAA)
+}
+
+// lookupAddrs returns the addresses of one family from LookupHost as
+// records of qtype.
+func lookupAddrs(lookup HostLookup, domain string, qtype Type) ([]RR, error) {
+	output := []RR{}
 	addrs, err := lookup.LookupHost(domain)
 	if err != nil {
-		return []string{}
+		return output, err
 	}
-	return append(output, addrs...)
+	for _, addr := range addrs {
+		ip := net.ParseIP(addr)
+		switch {
+		case ip == nil:
+		case ip.To4() != nil && qtype == TypeA:
+			output = append(output, lookupRR(domain, TypeA, &A{IP: ip.To4()}))
+		case ip.To4() == nil && qtype == TypeAAAA:
+			output = append(output, lookupRR(domain, TypeAAAA, &AAAA{IP: ip}))
+		}
+	}
+	return output, nil
+}
+
+// lookupRR wraps an answer from a HostLookup, which does not report TTLs,
+// in a record owned by domain.
+func lookupRR(domain string, qtype Type, data RData) RR {
+	return RR{Name: Fqdn(domain), Type: qtype, Class: ClassINET, Data: data}
 }
 
 // LookupMX looks up the MX records using net.LookupMX.
@@ -100,16 +128,16 @@ func (n NetHostLookup) LookupMX(domain string) ([]*net.MX, error) {
 	return net.LookupMX(domain)
 }
 
-func lookupMXRecords(lookup HostLookup, domain string) []string {
-	var output []string
+func lookupMXRecords(lookup HostLookup, domain string) ([]RR, error) {
+	output := []RR{}
 	mxRecords, err := lookup.LookupMX(domain)
 	if err != nil {
-		return []string{}
+		return output, err
 	}
 	for _, mx := range mxRecords {
-		output = append(output, fmt.Sprintf("%s %d\n", mx.Host, mx.Pref))
+		output = append(output, lookupRR(domain, TypeMX, &MX{Pref: mx.Pref, Host: mx.Host}))
 	}
-	return output
+	return output, nil
 }
 
 // LookupNS looks up the NS records using net.LookupNS.
@@ -117,16 +145,16 @@ func (n NetHostLookup) LookupNS(domain string) ([]*net.NS, error) {
 	return net.LookupNS(domain)
 }
 
-func lookupNSRecords(lookup HostLookup, domain string) []string {
+func lookupNSRecords(lookup HostLookup, domain string) ([]string, error) {
 	var output []string
 	nsRecords, err := lookup.LookupNS(domain)
 	if err != nil {
-		return []string{}
+		return []string{}, err
 	}
 	for _, ns := range nsRecords {
 		output = append(output, ns.Host)
 	}
-	return output
+	return output, nil
 }
 
 // LookupCNAME looks up the CNAME record using net.LookupCNAME.
@@ -134,12 +162,18 @@ func (n NetHostLookup) LookupCNAME(domain string) (string, error) {
 	return net.LookupCNAME(domain)
 }
 
-func lookupCNAMERecord(lookup HostLookup, domain string) string {
+// lookupCNAMERecord returns the CNAME record of domain, or none when domain
+// is its own canonical name.
+func lookupCNAMERecord(lookup HostLookup, domain string) ([]RR, error) {
+	output := []RR{}
 	cname, err := lookup.LookupCNAME(domain)
 	if err != nil {
-		return ""
+		return output, err
+	}
+	if !strings.EqualFold(Fqdn(cname), Fqdn(domain)) {
+		output = append(output, lookupRR(domain, TypeCNAME, &CNAME{Target: Fqdn(cname)}))
 	}
-	return cname
+	return output, nil
 }
 
 // LookupTXT looks up the TXT records using net.LookupTXT.
@@ -147,11 +181,161 @@ func (n NetHostLookup) LookupTXT(domain string) ([]string, error) {
 	return net.LookupTXT(domain)
 }
 
-func lookupTXTRecords(lookup HostLookup, domain string) []string {
+func lookupTXTRecords(lookup HostLookup, domain string) ([]string, error) {
 	var output []string
 	txtRecords, err := lookup.LookupTXT(domain)
 	if err != nil {
-		return []string{}
+		return []string{}, err
+	}
+	return append(output, txtRecords...), nil
+}
+
+// LookupAddr looks up the names for an address using net.LookupAddr.
+func (n NetHostLookup) LookupAddr(addr string) ([]string, error) {
+	return net.LookupAddr(addr)
+}
+
+// HostLookupQuerier answers queries through a HostLookup, so that Dig can
+// use the system resolver, which honours /etc/hosts, the search list and
+// nsswitch.conf. A HostLookup only knows the A, AAAA, MX, NS, CNAME and TXT
+// types; queries for any other go to Fallback, or fail when it is nil.
+// Answers carry no TTLs and a missing name or record is returned as the
+// HostLookup's error.
+type HostLookupQuerier struct {
+	Lookup   HostLookup
+	Fallback Querier
+}
+
+// Query looks up name through the HostLookup and returns the records as a
+// response from the system resolver.
+func (q HostLookupQuerier) Query(ctx context.Context, name string, qtype Type) (*Response, error) {
+	var lookup func(HostLookup, string) ([]RR, error)
+	switch qtype {
+	case TypeA:
+		lookup = lookupARecords
+	case TypeAAAA:
+		lookup = lookupAAAARecords
+	case TypeMX:
+		lookup = lookupMXRecords
+	case TypeNS:
+		lookup = lookupNSRRs
+	case TypeCNAME:
+		lookup = lookupCNAMERecord
+	case TypeTXT:
+		lookup = lookupTXTRRs
+	default:
+		if q.Fallback != nil {
+			return q.Fallback.Query(ctx, name, qtype)
+		}
+		return nil, fmt.Errorf("%s queries need a DNS server, such as @server", qtype)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	rrs, err := lookup(q.Lookup, name)
+	if err != nil {
+		return nil, err
+	}
+	msg := NewQuery(name, qtype)
+	msg.Response, msg.RecursionAvailable, msg.Answer = true, true, rrs
+	return &Response{Message: msg, Server: "system resolver", RTT: time.Since(start), Transport: "nsswitch"}, nil
+}
+
+func lookupNSRRs(lookup HostLookup, domain string) ([]RR, error) {
+	hosts, err := lookupNSRecords(lookup, domain)
+	output := []RR{}
+	for _, host := range hosts {
+		output = append(output, lookupRR(domain, TypeNS, &NS{Host: host}))
+	}
+	return output, err
+}
+
+func lookupTXTRRs(lookup HostLookup, domain string) ([]RR, error) {
+	txts, err := lookupTXTRecords(lookup, domain)
+	output := []RR{}
+	for _, txt := range txts {
+		output = append(output, lookupRR(domain, TypeTXT, &TXT{Strings: []string{txt}}))
+	}
+	return output, err
+}
+
+// ReverseLookup looks up the PTR records of an IPv4 or IPv6 address
+// through lookup and returns them as the result for its reverse name.
+func ReverseLookup(lookup HostLookup, addr string) (*DigResult, error) {
+	name, err := ReverseName(addr)
+	if err != nil {
+		return nil, err
+	}
+	section := DigSection{Type: TypePTR}
+	section.Records, section.Err = lookupPTRRecords(lookup, name, addr)
+	return &DigResult{Domain: name, Sections: []DigSection{section}}, nil
+}
+
+func lookupPTRRecords(lookup HostLookup, name, addr string) ([]RR, error) {
+	output := []RR{}
+	hosts, err := lookup.LookupAddr(addr)
+	if err != nil {
+		return output, err
+	}
+	for _, host := range hosts {
+		output = append(output, lookupRR(name, TypePTR, &PTR{Host: Fqdn(host)}))
+	}
+	return output, nil
+}
+
+// ReverseName returns the in-addr.arpa or ip6.arpa name used to look up
+// the PTR records of an IP address.
+func ReverseName(addr string) (string, error) {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return "", fmt.Errorf("invalid IP address: %s", addr)
+	}
+	var sb strings.Builder
+	if ip4 := ip.To4(); ip4 != nil {
+		for i := len(ip4) - 1; i >= 0; i-- {
+			fmt.Fprintf(&sb, "%d.", ip4[i])
+		}
+		sb.WriteString("in-addr.arpa.")
+		return sb.String(), nil
+	}
+	const hexDigits = "0123456789abcdef"
+	for i := len(ip) - 1; i >= 0; i-- {
+		sb.WriteByte(hexDigits[ip[i]&0x0F])
+		sb.WriteByte('.')
+		sb.WriteByte(hexDigits[ip[i]>>4])
+		sb.WriteByte('.')
+	}
+	sb.WriteString("ip6.arpa.")
+	return sb.String(), nil
+}
+
+// digSection queries domain for qtype and records how the answer arrived.
+func digSection(ctx context.Context, q Querier, domain string, qtype Type) DigSection {
+	section := DigSection{Type: qtype}
+	resp, err := q.Query(ctx, domain, qtype)
+	if err != nil {
+		section.Records, section.Err = []RR{}, err
+		return section
+	}
+	section.Server, section.Transport, section.RTT, section.TCPFallback = resp.Server, resp.Transport, resp.RTT, resp.TCPFallback
+	section.EDNS = resp.EDNS()
+	section.Records, section.Err = answerRecords(resp, domain, qtype)
+	return section
+}
+
+// answerRecords returns the answer records of type qtype, or all of them
+// for ANY, turning error rcodes into a *DNSError.
+func answerRecords(resp *Response, domain string, qtype Type) ([]RR, error) {
+	output := []RR{}
+	if err := rcodeError(resp, domain, qtype); err != nil {
+		return output, err
+	}
+	for _, rr := range resp.Answer {
+		if qtype == TypeANY || rr.Type == qtype {
+			output = append(output, rr)
+		}
 	}
-	return append(output, txtRecords...)
+	return output, nil
 }
@@ -1,6 +1,7 @@
 package network
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"testing"
@@ -15,6 +16,7 @@ type MockHostLookup struct {
 	LookupNSFunc    func(domain string) ([]*net.NS, error)
 	LookupCNAMEFunc func(domain string) (string, error)
 	LookupTXTFunc   func(domain string) ([]string, error)
+	LookupAddrFunc  func(addr string) ([]string, error)
 }
 
 func (m MockHostLookup) LookupHost(domain string) ([]string, error) {
@@ -37,15 +39,28 @@ func (m MockHostLookup) LookupTXT(domain string) ([]string, error) {
 	return m.LookupTXTFunc(domain)
 }
 
+func (m MockHostLookup) LookupAddr(addr string) ([]string, error) {
+	return m.LookupAddrFunc(addr)
+}
+
+// MockQuerier is a mock implementation of the Querier interface.
+type MockQuerier struct {
+	QueryFunc func(ctx context.Context, name string, qtype Type) (*Response, error)
+}
+
+func (m MockQuerier) Query(ctx context.Context, name string, qtype Type) (*Response, error) {
+	return m.QueryFunc(ctx, name, qtype)
+}
+
 func TestLookupARecords(t *testing.T) {
 	tests := []struct {
 		domain   string
 		addrs    []string
 		err      error
-		expected []string
+		expected []RR
 	}{
-		{"example.com", []string{"93.184.216.34"}, nil, []string{"93.184.216.34"}},
-		{"invalid-domain", nil, fmt.Errorf("no such host"), []string{}},
+		{"example.com", []string{"93.184.216.34"}, nil, []RR{{Name: "example.com.", Type: TypeA, Class: ClassINET, Data: &A{IP: net.ParseIP("93.184.216.34").To4()}}}},
+		{"invalid-domain", nil, fmt.Errorf("no such host"), []RR{}},
 	}
 
 	for _, tt := range tests {
@@ -57,21 +72,43 @@ func TestLookupARecords(t *testing.T) {
 				},
 			}
 
-			result := lookupARecords(mockLookup, tt.domain)
+			result, err := lookupARecords(mockLookup, tt.domain)
 			assert.Equal(t, tt.expected, result)
+			assert.Equal(t, tt.err, err)
 		})
 	}
 }
 
+func TestLookupAAAARecords(t *testing.T) {
+	mockLookup := MockHostLookup{
+		LookupHostFunc: func(domain string) ([]string, error) {
+			return []string{"93.184.216.34", "2606:2800:220:1::248"}, nil
+		},
+	}
+
+	ars, err := lookupARecords(mockLookup, "example.com")
+	assert.NoError(t, err)
+	if assert.Len(t, ars, 1) {
+		assert.Equal(t, "93.184.216.34", ars[0].Data.String())
+	}
+
+	aaaas, err := lookupAAAARecords(mockLookup, "example.com")
+	assert.NoError(t, err)
+	if assert.Len(t, aaaas, 1) {
+		assert.Equal(t, TypeAAAA, aaaas[0].Type)
+		assert.Equal(t, "2606:2800:220:1::248", aaaas[0].Data.String())
+	}
+}
+
 func TestLookupMXRecords(t *testing.T) {
 	tests := []struct {
 		domain    string
 		mxRecords []*net.MX
 		err       error
-		expected  []string
+		expected  []RR
 	}{
-		{"example.com", []*net.MX{{Host: "mail.example.com.", Pref: 10}}, nil, []string{"mail.example.com. 10\n"}},
-		{"invalid-domain", nil, fmt.Errorf("no such host"), []string{}},
+		{"example.com", []*net.MX{{Host: "mail.example.com.", Pref: 10}}, nil, []RR{{Name: "example.com.", Type: TypeMX, Class: ClassINET, Data: &MX{Pref: 10, Host: "mail.example.com."}}}},
+		{"invalid-domain", nil, fmt.Errorf("no such host"), []RR{}},
 	}
 
 	for _, tt := range tests {
@@ -83,8 +120,9 @@ func TestLookupMXRecords(t *testing.T) {
 				},
 			}
 
-			result := lookupMXRecords(mockLookup, tt.domain)
+			result, err := lookupMXRecords(mockLookup, tt.domain)
 			assert.Equal(t, tt.expected, result)
+			assert.Equal(t, tt.err, err)
 		})
 	}
 }
@@ -109,8 +147,9 @@ func TestLookupNSRecords(t *testing.T) {
 				},
 			}
 
-			result := lookupNSRecords(mockLookup, tt.domain)
+			result, err := lookupNSRecords(mockLookup, tt.domain)
 			assert.Equal(t, tt.expected, result)
+			assert.Equal(t, tt.err, err)
 		})
 	}
 }
@@ -120,10 +159,11 @@ func TestLookupCNAMERecord(t *testing.T) {
 		domain   string
 		cname    string
 		err      error
-		expected string
+		expected []RR
 	}{
-		{"www.example.com", "example.com.", nil, "example.com."},
-		{"invalid-domain", "", fmt.Errorf("no such host"), ""},
+		{"www.example.com", "example.com.", nil, []RR{{Name: "www.example.com.", Type: TypeCNAME, Class: ClassINET, Data: &CNAME{Target: "example.com."}}}},
+		{"example.com", "example.com.", nil, []RR{}},
+		{"invalid-domain", "", fmt.Errorf("no such host"), []RR{}},
 	}
 
 	for _, tt := range tests {
@@ -135,8 +175,9 @@ func TestLookupCNAMERecord(t *testing.T) {
 				},
 			}
 
-			result := lookupCNAMERecord(mockLookup, tt.domain)
+			result, err := lookupCNAMERecord(mockLookup, tt.domain)
 			assert.Equal(t, tt.expected, result)
+			assert.Equal(t, tt.err, err)
 		})
 	}
 }
@@ -161,8 +202,191 @@ func TestLookupTXTRecords(t *testing.T) {
 				},
 			}
 
-			result := lookupTXTRecords(mockLookup, tt.domain)
+			result, err := lookupTXTRecords(mockLookup, tt.domain)
 			assert.Equal(t, tt.expected, result)
+			assert.Equal(t, tt.err, err)
 		})
 	}
 }
+
+func TestReverseLookup(t *testing.T) {
+	tests := []struct {
+		addr      string
+		names     []string
+		err       error
+		domain    string
+		expected  []RR
+		expectErr bool
+	}{
+		{"192.0.2.10", []string{"host.example.com."}, nil, "10.2.0.192.in-addr.arpa.",
+			[]RR{{Name: "10.2.0.192.in-addr.arpa.", Type: TypePTR, Class: ClassINET, Data: &PTR{Host: "host.example.com."}}}, false},
+		{"2001:db8::10", []string{"v6.example.com."}, nil, "0.1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa.",
+			[]RR{{Name: "0.1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa.", Type: TypePTR, Class: ClassINET, Data: &PTR{Host: "v6.example.com."}}}, false},
+		{"192.0.2.11", nil, &net.DNSError{Err: "no such host", IsNotFound: true}, "11.2.0.192.in-addr.arpa.", []RR{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			mockLookup := MockHostLookup{
+				LookupAddrFunc: func(addr string) ([]string, error) {
+					assert.Equal(t, tt.addr, addr)
+					return tt.names, tt.err
+				},
+			}
+
+			result, err := ReverseLookup(mockLookup, tt.addr)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.domain, result.Domain)
+			section := result.Section(TypePTR)
+			assert.Equal(t, tt.expected, section.Records)
+			if tt.expectErr {
+				assert.Equal(t, "NXDOMAIN", section.Status())
+			} else {
+				assert.NoError(t, section.Err)
+			}
+		})
+	}
+
+	_, err := ReverseLookup(MockHostLookup{}, "not-an-ip")
+	assert.Error(t, err)
+}
+
+func TestReverseName(t *testing.T) {
+	tests := []struct {
+		addr      string
+		expected  string
+		expectErr bool
+	}{
+		{"192.0.2.10", "10.2.0.192.in-addr.arpa.", false},
+		{"2001:db8::567:89ab", "b.a.9.8.7.6.5.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa.", false},
+		{"::ffff:192.0.2.1", "1.2.0.192.in-addr.arpa.", false},
+		{"example.com", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			result, err := ReverseName(tt.addr)
+			if tt.expectErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestDigSection(t *testing.T) {
+	srv := RR{Name: "_ldap._tcp.example.com.", Type: TypeSRV, Class: ClassINET, TTL: 60, Data: &SRV{Priority: 0, Weight: 100, Port: 389, Target: "dc1.example.com."}}
+	soa := RR{Name: "example.com.", Type: TypeSOA, Class: ClassINET, TTL: 60, Data: &SOA{MName: "ns1.example.com.", RName: "admin.example.com.", Serial: 7}}
+	tests := []struct {
+		name      string
+		qtype     Type
+		answer    []RR
+		rcode     RCode
+		err       error
+		expected  []RR
+		expectErr bool
+	}{
+		{"typed answer", TypeSRV, []RR{srv}, RCodeSuccess, nil, []RR{srv}, false},
+		{"filters other types", TypeSRV, []RR{soa, srv}, RCodeSuccess, nil, []RR{srv}, false},
+		{"any keeps all types", TypeANY, []RR{soa, srv}, RCodeSuccess, nil, []RR{soa, srv}, false},
+		{"nxdomain", TypeSRV, nil, RCodeNameError, nil, []RR{}, true},
+		{"query error", TypeSRV, nil, RCodeSuccess, fmt.Errorf("i/o timeout"), []RR{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockQuerier := MockQuerier{
+				QueryFunc: func(ctx context.Context, name string, qtype Type) (*Response, error) {
+					assert.Equal(t, "_ldap._tcp.example.com", name)
+					assert.Equal(t, tt.qtype, qtype)
+					if tt.err != nil {
+						return nil, tt.err
+					}
+					return &Response{Message: &Message{Header: Header{RCode: tt.rcode}, Answer: tt.answer}}, nil
+				},
+			}
+
+			section := digSection(context.Background(), mockQuerier, "_ldap._tcp.example.com", tt.qtype)
+			assert.Equal(t, tt.qtype, section.Type)
+			assert.Equal(t, tt.expected, section.Records)
+			if tt.expectErr {
+				assert.Error(t, section.Err)
+			} else {
+				assert.NoError(t, section.Err)
+			}
+		})
+	}
+}
+
+func TestDig(t *testing.T) {
+	addr := startTestDNSServer(t, testRecords.handle)
+	client := NewDNSClient(addr)
+
+	result := Dig(context.Background(), client, "example.com", TypeA, TypeMX, TypeCAA)
+	assert.Equal(t, "example.com", result.Domain)
+	assert.Len(t, result.Sections, 3)
+
+	a := result.Section(TypeA)
+	assert.NoError(t, a.Err)
+	assert.Len(t, a.Records, 1)
+	assert.Equal(t, uint32(300), a.Records[0].TTL)
+	assert.Equal(t, ClassINET, a.Records[0].Class)
+	assert.Equal(t, "93.184.216.34", a.Records[0].Data.(*A).IP.String())
+
+	mx := result.Section(TypeMX)
+	assert.NoError(t, mx.Err)
+	assert.Len(t, mx.Records, 2)
+
+	caa := result.Section(TypeCAA)
+	assert.NoError(t, caa.Err)
+	assert.Empty(t, caa.Records)
+
+	assert.Nil(t, result.Section(TypeSRV))
+
+	missing := Dig(context.Background(), client, "missing.example.com")
+	assert.Len(t, missing.Sections, len(DefaultDigTypes))
+	for _, section := range missing.Sections {
+		assert.Error(t, section.Err)
+		assert.Equal(t, "NXDOMAIN", section.Status())
+	}
+}
+
+func TestDigHostLookupQuerier(t *testing.T) {
+	notFound := &net.DNSError{Err: "no such host", Name: "missing.example.com", IsNotFound: true}
+	mockLookup := MockHostLookup{
+		LookupHostFunc: func(domain string) ([]string, error) {
+			if domain == "missing.example.com" {
+				return nil, notFound
+			}
+			return []string{"93.184.216.34", "2606:2800:220:1::248"}, nil
+		},
+		LookupMXFunc: func(domain string) ([]*net.MX, error) {
+			return []*net.MX{{Host: "mx1.example.com.", Pref: 10}}, nil
+		},
+		LookupTXTFunc: func(domain string) ([]string, error) {
+			return []string{"v=spf1 -all"}, nil
+		},
+	}
+	querier := HostLookupQuerier{Lookup: mockLookup}
+
+	result := Dig(context.Background(), querier, "example.com", TypeA, TypeAAAA, TypeMX, TypeTXT, TypeSRV)
+	assert.Equal(t, "93.184.216.34", result.Section(TypeA).Records[0].Data.String())
+	assert.Equal(t, "2606:2800:220:1::248", result.Section(TypeAAAA).Records[0].Data.String())
+	assert.Equal(t, "10 mx1.example.com.", result.Section(TypeMX).Records[0].Data.String())
+	assert.Equal(t, []string{"v=spf1 -all"}, result.Section(TypeTXT).Records[0].Data.(*TXT).Strings)
+	assert.Equal(t, "system resolver", result.Section(TypeA).Server)
+	assert.ErrorContains(t, result.Section(TypeSRV).Err, "need a DNS server")
+
+	missing := Dig(context.Background(), querier, "missing.example.com", TypeA)
+	assert.Equal(t, "NXDOMAIN", missing.Sections[0].Status())
+
+	srv := RR{Name: "_ldap._tcp.example.com.", Type: TypeSRV, Class: ClassINET, TTL: 60, Data: &SRV{Port: 389, Target: "dc1.example.com."}}
+	querier.Fallback = MockQuerier{QueryFunc: func(ctx context.Context, name string, qtype Type) (*Response, error) {
+		assert.Equal(t, TypeSRV, qtype)
+		return &Response{Message: &Message{Answer: []RR{srv}}}, nil
+	}}
+	result = Dig(context.Background(), querier, "_ldap._tcp.example.com", TypeSRV)
+	assert.Equal(t, []RR{srv}, result.Sections[0].Records)
+}
@@ -0,0 +1,170 @@
+package network
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"strings"
+)
+
+var dkimTags = []string{"v", "h", "k", "n", "p", "s", "t"}
+
+// DKIMRecord is a parsed DKIM public key record (RFC 6376 section 3.6.1).
+type DKIMRecord struct {
+	Raw     string
+	KeyType string
+	// PublicKey is the decoded key, empty when the key has been revoked.
+	PublicKey []byte
+	KeyBits   int
+	// Hashes lists the acceptable hash algorithms; empty allows all.
+	Hashes  []string
+	Flags   []string
+	Testing bool
+}
+
+// DKIMResult is the DKIM key published for a selector.
+type DKIMResult struct {
+	Selector string
+	Domain   string
+	Record   *DKIMRecord
+	Findings []MailFinding
+}
+
+// ParseDKIM parses a DKIM key record, reporting syntax errors and weak or
+// revoked keys.
+func ParseDKIM(txt string) (*DKIMRecord, []MailFinding) {
+	var f findings
+	record := &DKIMRecord{Raw: txt, KeyType: "rsa"}
+	tags, err := parseTagList(txt)
+	if err != nil {
+		f.add(SeverityError, "%v", err)
+	}
+
+	var key string
+	hasKey := false
+	for i, t := range tags {
+		switch t.Name {
+		case "v":
+			if i != 0 || t.Value != "DKIM1" {
+				f.add(SeverityError, "v=%s must be the first tag and DKIM1", t.Value)
+			}
+		case "k":
+			record.KeyType = strings.ToLower(t.Value)
+		case "p":
+			key, hasKey = strings.Join(strings.Fields(t.Value), ""), true
+		case "h":
+			record.Hashes = splitTagValues(t.Value)
+		case "t":
+			record.Flags = splitTagValues(t.Value)
+			for _, flag := range record.Flags {
+				if flag == "y" {
+					record.Testing = true
+				}
+			}
+		case "n", "s":
+		default:
+			msg := "unknown tag %q is ignored"
+			if suggestion := closestWord(t.Name, dkimTags); suggestion != "" {
+				msg += ", did you mean " + suggestion + "=?"
+			}
+			f.add(SeverityWarning, msg, t.Name)
+		}
+	}
+
+	if len(record.Hashes) == 1 && record.Hashes[0] == "sha1" {
+		f.add(SeverityWarning, "h=sha1 only allows the obsolete SHA-1 hash")
+	}
+	if record.Testing {
+		f.add(SeverityWarning, "t=y marks the key as testing; receivers may ignore DKIM failures")
+	}
+
+	switch {
+	case !hasKey:
+		f.add(SeverityError, "missing the required p= public key tag")
+		return record, f
+	case key == "":
+		f.add(SeverityWarning, "empty p= means the key has been revoked")
+		return record, f
+	}
+	der, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		f.add(SeverityError, "p= is not valid base64: %v", err)
+		return record, f
+	}
+	record.PublicKey = der
+
+	switch record.KeyType {
+	case "rsa":
+		pub, err := x509.ParsePKIXPublicKey(der)
+		if err != nil {
+			f.add(SeverityError, "p= is not an RSA public key: %v", err)
+			break
+		}
+		rsaKey, ok := pub.(*rsa.PublicKey)
+		if !ok {
+			f.add(SeverityError, "p= holds a %T, not an RSA public key", pub)
+			break
+		}
+		record.KeyBits = rsaKey.N.BitLen()
+		switch {
+		case record.KeyBits < 1024:
+			f.add(SeverityError, "%d-bit RSA key is too short; verifiers must reject keys under 1024 bits", record.KeyBits)
+		case record.KeyBits < 2048:
+			f.add(SeverityWarning, "%d-bit RSA key is weak; use 2048 bits or more", record.KeyBits)
+		}
+	case "ed25519":
+		if len(der) != ed25519.PublicKeySize {
+			f.add(SeverityError, "p= is %d bytes, an ed25519 key is %d", len(der), ed25519.PublicKeySize)
+			break
+		}
+		record.KeyBits = 256
+	default:
+		f.add(SeverityError, "unknown key type k=%s (rsa or ed25519)", record.KeyType)
+	}
+	return record, f
+}
+
+// splitTagValues splits a colon separated tag value such as "sha1:sha256".
+func splitTagValues(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ":") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, strings.ToLower(v))
+		}
+	}
+	return out
+}
+
+// dkim looks up the DKIM key of selector at selector._domainkey.domain.
+func (c *MailChecker) dkim(domain, selector string) *DKIMResult {
+	result := &DKIMResult{Selector: selector, Domain: selector + "._domainkey." + domain}
+	var f findings
+	txts, err := lookupTXTRecords(c.Lookup, result.Domain)
+	if err != nil && !isNotFound(err) {
+		f.add(SeverityError, "TXT lookup for %s failed, status: %s", result.Domain, ErrorStatus(err))
+		result.Findings = f
+		return result
+	}
+	// A DKIM record need not start with v=DKIM1, so any record carrying a
+	// p= tag counts.
+	var keys []string
+	for _, txt := range txts {
+		if strings.HasPrefix(txt, "v=DKIM1") || strings.Contains(txt, "p=") {
+			keys = append(keys, txt)
+		}
+	}
+	switch {
+	case len(keys) == 0:
+		f.add(SeverityError, "no DKIM key found for selector %s at %s", selector, result.Domain)
+	case len(keys) > 1:
+		f.add(SeverityError, "%s has %d DKIM keys; verification results are undefined", result.Domain, len(keys))
+	}
+	if len(keys) > 0 {
+		var parsed []MailFinding
+		result.Record, parsed = ParseDKIM(keys[0])
+		f = append(f, parsed...)
+	}
+	result.Findings = f
+	return result
+}
@@ -0,0 +1,83 @@
+package network
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// rsaKeyTag returns a base64 RSA public key of the given size. The modulus
+// is not a real key, only its length matters to ParseDKIM.
+func rsaKeyTag(t *testing.T, bits int) string {
+	t.Helper()
+	n := new(big.Int).Lsh(big.NewInt(1), uint(bits-1))
+	der, err := x509.MarshalPKIXPublicKey(&rsa.PublicKey{N: n.Add(n, big.NewInt(1)), E: 65537})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(der)
+}
+
+func TestParseDKIM(t *testing.T) {
+	record, fs := ParseDKIM("v=DKIM1; k=rsa; h=sha256; p=" + rsaKeyTag(t, 2048))
+	assert.Empty(t, fs)
+	assert.Equal(t, "rsa", record.KeyType)
+	assert.Equal(t, 2048, record.KeyBits)
+	assert.Equal(t, []string{"sha256"}, record.Hashes)
+	assert.False(t, record.Testing)
+
+	// Keys may be split over several strings, leaving spaces in p=.
+	key := rsaKeyTag(t, 2048)
+	record, fs = ParseDKIM("p=" + key[:100] + " " + key[100:])
+	assert.Empty(t, fs)
+	assert.Equal(t, 2048, record.KeyBits)
+
+	record, _ = ParseDKIM("v=DKIM1; t=y:s; p=" + key)
+	assert.True(t, record.Testing)
+	assert.Equal(t, []string{"y", "s"}, record.Flags)
+
+	tests := []struct {
+		txt      string
+		severity Severity
+		message  string
+	}{
+		{"v=DKIM1; k=rsa", SeverityError, "missing the required p= public key tag"},
+		{"v=DKIM1; p=", SeverityWarning, "key has been revoked"},
+		{"v=DKIM1; p=not*base64", SeverityError, "not valid base64"},
+		{"v=DKIM1; p=" + rsaKeyTag(t, 512), SeverityError, "512-bit RSA key is too short"},
+		{"v=DKIM1; p=" + rsaKeyTag(t, 1024), SeverityWarning, "1024-bit RSA key is weak"},
+		{"v=DKIM1; p=AAAA", SeverityError, "not an RSA public key"},
+		{"v=DKIM1; k=ed25519; p=AAAA", SeverityError, "an ed25519 key is 32"},
+		{"v=DKIM1; k=dsa; p=AAAA", SeverityError, "unknown key type k=dsa"},
+		{"v=DKIM1; t=y; p=", SeverityWarning, "t=y marks the key as testing"},
+		{"v=DKIM1; h=sha1; p=", SeverityWarning, "obsolete SHA-1"},
+		{"k=rsa; v=DKIM1; p=", SeverityError, "must be the first tag"},
+		{"v=DKIM1; kk=rsa; p=", SeverityWarning, `unknown tag "kk" is ignored, did you mean`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.txt, func(t *testing.T) {
+			_, fs := ParseDKIM(tt.txt)
+			assert.True(t, hasFinding(fs, tt.severity, tt.message), "findings: %v", fs)
+		})
+	}
+}
+
+func TestMailCheckDKIM(t *testing.T) {
+	checker := &MailChecker{Lookup: txtLookup(map[string][]string{
+		"s1._domainkey.example.com": {"v=DKIM1; p=" + rsaKeyTag(t, 2048)},
+		"s2._domainkey.example.com": {"unrelated text"},
+	})}
+
+	result := checker.dkim("example.com", "s1")
+	assert.Equal(t, "s1", result.Selector)
+	assert.Equal(t, 2048, result.Record.KeyBits)
+	assert.Empty(t, result.Findings)
+
+	result = checker.dkim("example.com", "s2")
+	assert.Nil(t, result.Record)
+	assert.True(t, hasFinding(result.Findings, SeverityError, "no DKIM key found for selector s2"))
+}
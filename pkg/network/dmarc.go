@@ -0,0 +1,170 @@
+package network
+
+import (
+	"strconv"
+	"strings"
+)
+
+var (
+	dmarcTags     = []string{"v", "p", "sp", "np", "pct", "rua", "ruf", "adkim", "aspf", "fo", "rf", "ri"}
+	dmarcPolicies = []string{"none", "quarantine", "reject"}
+)
+
+// DMARCRecord is a parsed DMARC policy record (RFC 7489 section 6.3).
+// Optional tags that are absent hold their defaults.
+type DMARCRecord struct {
+	Raw             string
+	Policy          string
+	SubdomainPolicy string
+	Percent         int
+	AggregateURIs   []string
+	FailureURIs     []string
+	// DKIMAlignment and SPFAlignment are "r" (relaxed) or "s" (strict).
+	DKIMAlignment  string
+	SPFAlignment   string
+	FailureOptions string
+	ReportInterval int
+}
+
+// DMARCResult is the DMARC record published for a domain.
+type DMARCResult struct {
+	Domain   string
+	Record   *DMARCRecord
+	Findings []MailFinding
+}
+
+// ParseDMARC parses a DMARC record, reporting syntax errors and settings
+// that weaken the policy.
+func ParseDMARC(txt string) (*DMARCRecord, []MailFinding) {
+	var f findings
+	record := &DMARCRecord{Raw: txt, Percent: 100, DKIMAlignment: "r", SPFAlignment: "r", FailureOptions: "0", ReportInterval: 86400}
+	tags, err := parseTagList(txt)
+	if err != nil {
+		f.add(SeverityError, "%v", err)
+	}
+	if len(tags) == 0 || tags[0].Name != "v" || tags[0].Value != "DMARC1" {
+		f.add(SeverityError, "record does not start with v=DMARC1")
+		return record, f
+	}
+
+	for _, t := range tags[1:] {
+		name := strings.ToLower(t.Name)
+		switch name {
+		case "p", "sp", "np":
+			policy := strings.ToLower(t.Value)
+			if !containsString(dmarcPolicies, policy) {
+				msg := "%s=%s is not a valid policy (none, quarantine or reject)"
+				if suggestion := closestWord(policy, dmarcPolicies); suggestion != "" {
+					msg += ", did you mean " + suggestion + "?"
+				}
+				f.add(SeverityError, msg, name, t.Value)
+				continue
+			}
+			switch name {
+			case "p":
+				record.Policy = policy
+			case "sp":
+				record.SubdomainPolicy = policy
+			}
+		case "pct":
+			n, err := strconv.Atoi(t.Value)
+			if err != nil || n < 0 || n > 100 {
+				f.add(SeverityError, "pct=%s is not a percentage from 0 to 100", t.Value)
+				continue
+			}
+			record.Percent = n
+		case "rua", "ruf":
+			var uris []string
+			for _, uri := range strings.Split(t.Value, ",") {
+				uri = strings.TrimSpace(uri)
+				if !strings.HasPrefix(strings.ToLower(uri), "mailto:") || !strings.Contains(uri, "@") {
+					f.add(SeverityError, "%s URI %q is not a mailto: address", name, uri)
+					continue
+				}
+				uris = append(uris, uri)
+			}
+			if name == "rua" {
+				record.AggregateURIs = uris
+			} else {
+				record.FailureURIs = uris
+			}
+		case "adkim", "aspf":
+			mode := strings.ToLower(t.Value)
+			if mode != "r" && mode != "s" {
+				f.add(SeverityError, "%s=%s must be r (relaxed) or s (strict)", name, t.Value)
+				continue
+			}
+			if name == "adkim" {
+				record.DKIMAlignment = mode
+			} else {
+				record.SPFAlignment = mode
+			}
+		case "fo":
+			record.FailureOptions = t.Value
+		case "ri":
+			n, err := strconv.Atoi(t.Value)
+			if err != nil || n <= 0 {
+				f.add(SeverityError, "ri=%s is not a number of seconds", t.Value)
+				continue
+			}
+			record.ReportInterval = n
+		case "rf":
+		case "v":
+			f.add(SeverityError, "v= must be the first tag only")
+		default:
+			msg := "unknown tag %q is ignored"
+			if suggestion := closestWord(name, dmarcTags); suggestion != "" {
+				msg += ", did you mean " + suggestion + "=?"
+			}
+			f.add(SeverityWarning, msg, t.Name)
+		}
+	}
+
+	switch record.Policy {
+	case "":
+		f.add(SeverityError, "missing the required p= policy tag")
+	case "none":
+		f.add(SeverityWarning, "p=none only monitors; failing mail is still delivered")
+	}
+	if record.SubdomainPolicy == "none" && record.Policy != "" && record.Policy != "none" {
+		f.add(SeverityWarning, "sp=none leaves subdomains unprotected while p=%s", record.Policy)
+	}
+	if record.Percent < 100 && record.Policy != "none" {
+		f.add(SeverityWarning, "pct=%d applies the policy to only part of the failing mail", record.Percent)
+	}
+	if len(record.AggregateURIs) == 0 {
+		f.add(SeverityWarning, "no rua= address, so no aggregate reports are sent")
+	}
+	return record, f
+}
+
+// dmarc looks up the DMARC record at _dmarc.domain.
+func (c *MailChecker) dmarc(domain string) *DMARCResult {
+	result := &DMARCResult{Domain: "_dmarc." + domain}
+	var f findings
+	records, err := txtWithPrefix(c.Lookup, result.Domain, "v=DMARC1")
+	switch {
+	case err != nil:
+		f.add(SeverityError, "TXT lookup for %s failed, status: %s", result.Domain, ErrorStatus(err))
+	case len(records) == 0:
+		f.add(SeverityError, "no DMARC record found at %s", result.Domain)
+	case len(records) > 1:
+		f.add(SeverityError, "%s has %d DMARC records; receivers will ignore them all", result.Domain, len(records))
+	}
+	if len(records) > 0 {
+		var parsed []MailFinding
+		result.Record, parsed = ParseDMARC(records[0])
+		f = append(f, parsed...)
+	}
+	result.Findings = f
+	return result
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+package network
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDMARC(t *testing.T) {
+	record, fs := ParseDMARC("v=DMARC1; p=quarantine; sp=reject; pct=100; rua=mailto:agg@example.com,mailto:agg@example.net; ruf=mailto:fail@example.com; adkim=s; aspf=r; fo=1; ri=3600")
+	assert.Empty(t, fs)
+	assert.Equal(t, &DMARCRecord{
+		Raw:             record.Raw,
+		Policy:          "quarantine",
+		SubdomainPolicy: "reject",
+		Percent:         100,
+		AggregateURIs:   []string{"mailto:agg@example.com", "mailto:agg@example.net"},
+		FailureURIs:     []string{"mailto:fail@example.com"},
+		DKIMAlignment:   "s",
+		SPFAlignment:    "r",
+		FailureOptions:  "1",
+		ReportInterval:  3600,
+	}, record)
+
+	tests := []struct {
+		txt      string
+		severity Severity
+		message  string
+	}{
+		{"p=reject; v=DMARC1", SeverityError, "does not start with v=DMARC1"},
+		{"v=DMARC1; rua=mailto:a@example.com", SeverityError, "missing the required p= policy tag"},
+		{"v=DMARC1; p=rejct; rua=mailto:a@example.com", SeverityError, "did you mean reject?"},
+		{"v=DMARC1; p=none; rua=mailto:a@example.com", SeverityWarning, "p=none only monitors"},
+		{"v=DMARC1; p=reject; pct=50; rua=mailto:a@example.com", SeverityWarning, "pct=50 applies the policy to only part"},
+		{"v=DMARC1; p=reject; pct=150", SeverityError, "pct=150 is not a percentage"},
+		{"v=DMARC1; p=reject", SeverityWarning, "no rua= address"},
+		{"v=DMARC1; p=reject; sp=none; rua=mailto:a@example.com", SeverityWarning, "sp=none leaves subdomains unprotected"},
+		{"v=DMARC1; p=reject; rua=https://example.com/report", SeverityError, "is not a mailto: address"},
+		{"v=DMARC1; p=reject; adkim=strict", SeverityError, "adkim=strict must be r (relaxed) or s (strict)"},
+		{"v=DMARC1; p=reject; rau=mailto:a@example.com", SeverityWarning, `unknown tag "rau" is ignored, did you mean rua=?`},
+		{"v=DMARC1; p=reject; p=none", SeverityError, `duplicate tag "p"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.txt, func(t *testing.T) {
+			_, fs := ParseDMARC(tt.txt)
+			assert.True(t, hasFinding(fs, tt.severity, tt.message), "findings: %v", fs)
+		})
+	}
+}
+
+func TestMailCheckDMARC(t *testing.T) {
+	checker := &MailChecker{Lookup: txtLookup(map[string][]string{
+		"_dmarc.example.com": {"v=DMARC1; p=reject; rua=mailto:a@example.com", "v=DMARC1; p=none"},
+	})}
+	result := checker.dmarc("example.com")
+	assert.Equal(t, "_dmarc.example.com", result.Domain)
+	assert.True(t, hasFinding(result.Findings, SeverityError, "has 2 DMARC records"))
+	assert.Equal(t, "reject", result.Record.Policy)
+}
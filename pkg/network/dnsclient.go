@@ -0,0 +1,332 @@
+package network
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultDNSTimeout is used when a DNSClient has no timeout configured.
+	DefaultDNSTimeout = 5 * time.Second
+
+	resolvConfPath = "/etc/resolv.conf"
+	dnsPort        = "53"
+)
+
+// Querier defines an interface for sending a single DNS question.
+type Querier interface {
+	Query(ctx context.Context, name string, qtype Type) (*Response, error)
+}
+
+// Response is a decoded DNS response together with details of the exchange.
+type Response struct {
+	*Message
+	Server string
+	RTT    time.Duration
+	// Transport names the protocol that carried the response.
+	Transport string
+	// TCPFallback is set when a truncated UDP response was retried over TCP.
+	TCPFallback bool
+}
+
+// DNSClient is a concrete implementation of HostLookup that builds DNS
+// messages itself and exchanges them with a single nameserver. Queries go
+// over UDP, and are retried over TCP when the answer is truncated, unless
+// another Transport is set.
+type DNSClient struct {
+	Server    string
+	Timeout   time.Duration
+	Transport Transport
+	// UDPSize is the EDNS(0) payload size advertised in queries. Zero
+	// sends queries without an OPT record.
+	UDPSize uint16
+	// DNSSEC sets the DO bit to request signatures, and CD so that data a
+	// validating resolver would reject is still returned for checking.
+	DNSSEC bool
+	// Options are EDNS(0) options, such as NSID or a client subnet, added
+	// to every query.
+	Options []EDNSOption
+}
+
+// NewDNSClient returns a DNSClient for server, which may be given as
+// "@host", "host" or "host:port". Port 53 is assumed when none is given and
+// an empty server selects the first nameserver in /etc/resolv.conf.
+func NewDNSClient(server string) *DNSClient {
+	return &DNSClient{Server: normalizeServer(server), Timeout: DefaultDNSTimeout, UDPSize: DefaultUDPSize}
+}
+
+// NewTLSClient returns a DNSClient that queries server over DNS-over-TLS,
+// on port 853 unless another port is given.
+func NewTLSClient(server string) *DNSClient {
+	server = strings.TrimPrefix(server, "@")
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(strings.Trim(server, "[]"), dnsOverTLSPort)
+	}
+	return &DNSClient{Server: server, Timeout: DefaultDNSTimeout, Transport: TLSTransport{}, UDPSize: DefaultUDPSize}
+}
+
+// NewHTTPSClient returns a DNSClient that queries the resolver at endpoint,
+// such as https://dns.example/dns-query, over DNS-over-HTTPS using the
+// given HTTP method.
+func NewHTTPSClient(endpoint, method string) *DNSClient {
+	return &DNSClient{Server: endpoint, Timeout: DefaultDNSTimeout, Transport: HTTPSTransport{Method: method}, UDPSize: DefaultUDPSize}
+}
+
+func normalizeServer(server string) string {
+	server = strings.TrimPrefix(server, "@")
+	if server == "" {
+		server = systemNameserver()
+	}
+	if _, _, err := net.SplitHostPort(server); err == nil {
+		return server
+	}
+	return net.JoinHostPort(strings.Trim(server, "[]"), dnsPort)
+}
+
+func (c *DNSClient) timeout() time.Duration {
+	if c.Timeout > 0 {
+		return c.Timeout
+	}
+	return DefaultDNSTimeout
+}
+
+func (c *DNSClient) transport() Transport {
+	if c.Transport != nil {
+		return c.Transport
+	}
+	return UDPTransport{}
+}
+
+// Query sends a recursive query for name and qtype to the client's server.
+func (c *DNSClient) Query(ctx context.Context, name string, qtype Type) (*Response, error) {
+	return c.Exchange(ctx, NewQuery(name, qtype))
+}
+
+// Exchange sends query to the client's server and returns the decoded
+// response. A truncated UDP response is retried over TCP. Failures to get
+// a response are returned as a *DNSError.
+func (c *DNSClient) Exchange(ctx context.Context, query *Message) (*Response, error) {
+	query = withEDNS(query, c.UDPSize, c.DNSSEC, c.Options...)
+	transport := c.transport()
+	resp, err := c.exchange(ctx, query, transport)
+	if err != nil {
+		return nil, err
+	}
+	if _, isUDP := transport.(UDPTransport); isUDP && resp.Truncated {
+		resp, err = c.exchange(ctx, query, TCPTransport{})
+		if err != nil {
+			return nil, err
+		}
+		resp.TCPFallback = true
+	}
+	return resp, nil
+}
+
+func (c *DNSClient) exchange(ctx context.Context, query *Message, transport Transport) (*Response, error) {
+	var q Question
+	if len(query.Question) > 0 {
+		q = query.Question[0]
+	}
+	out, err := query.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout())
+	defer cancel()
+
+	start := time.Now()
+	in, err := transport.Exchange(ctx, c.Server, out)
+	if err != nil {
+		return nil, transportError(fmt.Errorf("%s: %w", transport, err), q.Name, q.Type, c.Server, c.timeout())
+	}
+	rtt := time.Since(start)
+
+	resp := &Message{}
+	if err := resp.Unpack(in); err != nil {
+		return nil, transportError(fmt.Errorf("%s: malformed response: %v", transport, err), q.Name, q.Type, c.Server, c.timeout())
+	}
+	if resp.ID != query.ID || !resp.Response {
+		return nil, transportError(fmt.Errorf("%s: response does not match query", transport), q.Name, q.Type, c.Server, c.timeout())
+	}
+	return &Response{Message: resp, Server: c.Server, RTT: rtt, Transport: transport.String()}, nil
+}
+
+// lookup queries name and returns the answer records of the requested type.
+func (c *DNSClient) lookup(name string, qtype Type) ([]RR, error) {
+	resp, err := c.Query(context.Background(), name, qtype)
+	if err != nil {
+		return nil, err
+	}
+	if err := rcodeError(resp, name, qtype); err != nil {
+		return nil, err
+	}
+	var rrs []RR
+	for _, rr := range resp.Answer {
+		if rr.Type == qtype {
+			rrs = append(rrs, rr)
+		}
+	}
+	return rrs, nil
+}
+
+// LookupHost returns the A and AAAA addresses of domain. A query that
+// fails for any reason other than the name not existing fails the lookup,
+// rather than returning the other family's addresses as if complete.
+func (c *DNSClient) LookupHost(domain string) ([]string, error) {
+	var addrs []string
+	var notFound error
+	for _, qtype := range []Type{TypeA, TypeAAAA} {
+		rrs, err := c.lookup(domain, qtype)
+		if err != nil {
+			if !isNotFound(err) {
+				return nil, err
+			}
+			if notFound == nil {
+				notFound = err
+			}
+			continue
+		}
+		for _, rr := range rrs {
+			switch data := rr.Data.(type) {
+			case *A:
+				addrs = append(addrs, data.IP.String())
+			case *AAAA:
+				addrs = append(addrs, data.IP.String())
+			}
+		}
+	}
+	if len(addrs) == 0 {
+		if notFound != nil {
+			return nil, notFound
+		}
+		return nil, fmt.Errorf("lookup %s: no such host", domain)
+	}
+	return addrs, nil
+}
+
+// LookupMX returns the MX records of domain sorted by preference.
+func (c *DNSClient) LookupMX(domain string) ([]*net.MX, error) {
+	rrs, err := c.lookup(domain, TypeMX)
+	if err != nil {
+		return nil, err
+	}
+	var mxs []*net.MX
+	for _, rr := range rrs {
+		if mx, ok := rr.Data.(*MX); ok {
+			mxs = append(mxs, &net.MX{Host: mx.Host, Pref: mx.Pref})
+		}
+	}
+	sort.SliceStable(mxs, func(i, j int) bool { return mxs[i].Pref < mxs[j].Pref })
+	return mxs, nil
+}
+
+// LookupNS returns the NS records of domain.
+func (c *DNSClient) LookupNS(domain string) ([]*net.NS, error) {
+	rrs, err := c.lookup(domain, TypeNS)
+	if err != nil {
+		return nil, err
+	}
+	var nss []*net.NS
+	for _, rr := range rrs {
+		if ns, ok := rr.Data.(*NS); ok {
+			nss = append(nss, &net.NS{Host: ns.Host})
+		}
+	}
+	return nss, nil
+}
+
+// LookupCNAME returns the canonical name of domain by following the CNAME
+// records returned with its A query. Like net.LookupCNAME, a name without
+// a CNAME is its own canonical name.
+func (c *DNSClient) LookupCNAME(domain string) (string, error) {
+	resp, err := c.Query(context.Background(), domain, TypeA)
+	if err != nil {
+		return "", err
+	}
+	if err := rcodeError(resp, domain, TypeA); err != nil {
+		return "", err
+	}
+	name := Fqdn(domain)
+	for hops := 0; hops < len(resp.Answer); hops++ {
+		next := ""
+		for _, rr := range resp.Answer {
+			if cname, ok := rr.Data.(*CNAME); ok && strings.EqualFold(rr.Name, name) {
+				next = cname.Target
+				break
+			}
+		}
+		if next == "" {
+			break
+		}
+		name = next
+	}
+	return name, nil
+}
+
+// LookupTXT returns the TXT records of domain, joining the character
+// strings of each record.
+func (c *DNSClient) LookupTXT(domain string) ([]string, error) {
+	rrs, err := c.lookup(domain, TypeTXT)
+	if err != nil {
+		return nil, err
+	}
+	var txts []string
+	for _, rr := range rrs {
+		if txt, ok := rr.Data.(*TXT); ok {
+			txts = append(txts, strings.Join(txt.Strings, ""))
+		}
+	}
+	return txts, nil
+}
+
+// LookupAddr returns the names an address maps back to via its PTR records.
+func (c *DNSClient) LookupAddr(addr string) ([]string, error) {
+	name, err := ReverseName(addr)
+	if err != nil {
+		return nil, err
+	}
+	rrs, err := c.lookup(name, TypePTR)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, rr := range rrs {
+		if ptr, ok := rr.Data.(*PTR); ok {
+			names = append(names, ptr.Host)
+		}
+	}
+	return names, nil
+}
+
+// systemNameserver returns the first nameserver configured for the host,
+// falling back to the local stub resolver.
+func systemNameserver() string {
+	file, err := os.Open(resolvConfPath)
+	if err == nil {
+		defer file.Close()
+		if ns := firstNameserver(file); ns != "" {
+			return ns
+		}
+	}
+	return "127.0.0.1"
+}
+
+func firstNameserver(r io.Reader) string {
+	conf, err := ParseResolvConf(r)
+	if err != nil || len(conf.Nameservers) == 0 {
+		return ""
+	}
+	return conf.Nameservers[0]
+}
+
+func newMessageID() uint16 {
+	return uint16(rand.Uint32())
+}
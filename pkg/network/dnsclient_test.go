@@ -0,0 +1,206 @@
+package network
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// startTestDNSServer runs an in-process DNS server on a loopback UDP port
+// and returns its address. A nil response from handler drops the query.
+func startTestDNSServer(t *testing.T, handler func(req *Message) *Message) string {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return serveTestDNS(t, pc, handler)
+}
+
+// serveTestDNS answers queries arriving on pc with handler.
+func serveTestDNS(t *testing.T, pc net.PacketConn, handler func(req *Message) *Message) string {
+	t.Cleanup(func() { pc.Close() })
+
+	go func() {
+		buf := make([]byte, 65535)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			req := &Message{}
+			if err := req.Unpack(buf[:n]); err != nil {
+				continue
+			}
+			resp := handler(req)
+			if resp == nil {
+				continue
+			}
+			out, err := resp.Pack()
+			if err != nil {
+				continue
+			}
+			pc.WriteTo(out, addr)
+		}
+	}()
+	return pc.LocalAddr().String()
+}
+
+// replyTo returns an empty response header for req.
+func replyTo(req *Message) *Message {
+	return &Message{
+		Header: Header{
+			ID:                 req.ID,
+			Response:           true,
+			RecursionDesired:   req.RecursionDesired,
+			RecursionAvailable: true,
+		},
+		Question: req.Question,
+	}
+}
+
+// testZone answers queries from a fixed set of records, following CNAMEs
+// like a recursive resolver and returning NXDOMAIN for unknown names.
+type testZone []RR
+
+func (z testZone) handle(req *Message) *Message {
+	resp := replyTo(req)
+	q := req.Question[0]
+	name := q.Name
+	for hops := 0; hops < 8; hops++ {
+		known, next := false, ""
+		for _, rr := range z {
+			if !strings.EqualFold(rr.Name, name) {
+				continue
+			}
+			known = true
+			switch {
+			case rr.Type == q.Type:
+				resp.Answer = append(resp.Answer, rr)
+			case rr.Type == TypeCNAME:
+				resp.Answer = append(resp.Answer, rr)
+				next = rr.Data.(*CNAME).Target
+			}
+		}
+		if !known {
+			if hops == 0 {
+				resp.RCode = RCodeNameError
+			}
+			break
+		}
+		if next == "" {
+			break
+		}
+		name = next
+	}
+	return resp
+}
+
+var testRecords = testZone{
+	{Name: "example.com.", Type: TypeA, Class: ClassINET, TTL: 300, Data: &A{IP: net.IPv4(93, 184, 216, 34).To4()}},
+	{Name: "example.com.", Type: TypeAAAA, Class: ClassINET, TTL: 300, Data: &AAAA{IP: net.ParseIP("2606:2800:220:1::248")}},
+	{Name: "example.com.", Type: TypeMX, Class: ClassINET, TTL: 300, Data: &MX{Pref: 20, Host: "mx2.example.com."}},
+	{Name: "example.com.", Type: TypeMX, Class: ClassINET, TTL: 300, Data: &MX{Pref: 10, Host: "mx1.example.com."}},
+	{Name: "example.com.", Type: TypeNS, Class: ClassINET, TTL: 300, Data: &NS{Host: "ns1.example.com."}},
+	{Name: "example.com.", Type: TypeTXT, Class: ClassINET, TTL: 300, Data: &TXT{Strings: []string{"v=spf1 ", "-all"}}},
+	{Name: "www.example.com.", Type: TypeCNAME, Class: ClassINET, TTL: 60, Data: &CNAME{Target: "example.com."}},
+	{Name: "34.216.184.93.in-addr.arpa.", Type: TypePTR, Class: ClassINET, TTL: 300, Data: &PTR{Host: "example.com."}},
+}
+
+func TestDNSClientLookups(t *testing.T) {
+	addr := startTestDNSServer(t, testRecords.handle)
+	client := NewDNSClient(addr)
+
+	hosts, err := client.LookupHost("example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"93.184.216.34", "2606:2800:220:1::248"}, hosts)
+
+	mxs, err := client.LookupMX("example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, []*net.MX{{Host: "mx1.example.com.", Pref: 10}, {Host: "mx2.example.com.", Pref: 20}}, mxs)
+
+	nss, err := client.LookupNS("example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, []*net.NS{{Host: "ns1.example.com."}}, nss)
+
+	cname, err := client.LookupCNAME("www.example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, "example.com.", cname)
+
+	txts, err := client.LookupTXT("example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"v=spf1 -all"}, txts)
+
+	names, err := client.LookupAddr("93.184.216.34")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"example.com."}, names)
+
+	_, err = client.LookupHost("missing.example.com")
+	assert.ErrorContains(t, err, "NXDOMAIN")
+}
+
+func TestDNSClientLookupHostPartialFailure(t *testing.T) {
+	addr := startTestDNSServer(t, func(req *Message) *Message {
+		resp := testRecords.handle(req)
+		if req.Question[0].Type == TypeA {
+			resp.RCode, resp.Answer = RCodeServerFailure, nil
+		}
+		return resp
+	})
+	client := NewDNSClient(addr)
+
+	hosts, err := client.LookupHost("example.com")
+	assert.ErrorContains(t, err, "SERVFAIL")
+	assert.Nil(t, hosts)
+}
+
+func TestDNSClientIgnoresMismatchedID(t *testing.T) {
+	addr := startTestDNSServer(t, func(req *Message) *Message {
+		resp := testRecords.handle(req)
+		resp.ID++
+		return resp
+	})
+	client := &DNSClient{Server: addr, Timeout: 200 * time.Millisecond}
+
+	_, err := client.Query(context.Background(), "example.com", TypeA)
+	assert.Error(t, err)
+}
+
+func TestDNSClientTimeout(t *testing.T) {
+	addr := startTestDNSServer(t, func(req *Message) *Message { return nil })
+	client := &DNSClient{Server: addr, Timeout: 100 * time.Millisecond}
+
+	start := time.Now()
+	_, err := client.Query(context.Background(), "example.com", TypeA)
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), 2*time.Second)
+}
+
+func TestNormalizeServer(t *testing.T) {
+	tests := []struct {
+		server   string
+		expected string
+	}{
+		{"@10.0.0.2", "10.0.0.2:53"},
+		{"10.0.0.2:5353", "10.0.0.2:5353"},
+		{"2001:db8::1", "[2001:db8::1]:53"},
+		{"[2001:db8::1]:53", "[2001:db8::1]:53"},
+		{"ns1.example.com", "ns1.example.com:53"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.server, func(t *testing.T) {
+			assert.Equal(t, tt.expected, normalizeServer(tt.server))
+		})
+	}
+}
+
+func TestFirstNameserver(t *testing.T) {
+	conf := "# generated\nsearch corp.example.com\nnameserver 10.0.0.2\nnameserver 10.0.0.3\n"
+	assert.Equal(t, "10.0.0.2", firstNameserver(strings.NewReader(conf)))
+	assert.Equal(t, "", firstNameserver(strings.NewReader("search example.com\n")))
+}
@@ -0,0 +1,126 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// DNSError describes a DNS query that failed, either because the server
+// answered with an error response code or because no answer arrived.
+type DNSError struct {
+	Name   string
+	Type   Type
+	Server string
+	// RCode is the response code received, or SERVFAIL when the query
+	// failed before a response arrived.
+	RCode RCode
+	// Err is the transport error when no response was received.
+	Err error
+	// Timeout is how long the query waited when it timed out.
+	Timeout time.Duration
+
+	IsTimeout  bool
+	IsNotFound bool
+}
+
+// Error implements the error interface.
+func (e *DNSError) Error() string {
+	s := fmt.Sprintf("lookup %s %s", e.Name, e.Type)
+	if e.Server != "" {
+		s += " on " + e.Server
+	}
+	return s + ": " + e.Reason()
+}
+
+// Unwrap returns the underlying transport error.
+func (e *DNSError) Unwrap() error { return e.Err }
+
+// Reason explains the failure without the query details.
+func (e *DNSError) Reason() string {
+	switch {
+	case e.IsTimeout:
+		return fmt.Sprintf("timeout after %s", e.Timeout)
+	case e.Err != nil:
+		return e.Err.Error()
+	}
+	return e.RCode.String()
+}
+
+// Status formats the failure like dig's status line, for example
+// "NXDOMAIN" or "SERVFAIL (timeout after 5s)".
+func (e *DNSError) Status() string {
+	if e.IsTimeout || e.Err != nil {
+		return fmt.Sprintf("%s (%s)", e.RCode, e.Reason())
+	}
+	return e.RCode.String()
+}
+
+// rcodeError returns a DNSError for a response with a failing response code,
+// or nil when the response code is NOERROR.
+func rcodeError(resp *Response, name string, qtype Type) error {
+	if resp.RCode == RCodeSuccess {
+		return nil
+	}
+	return &DNSError{
+		Name:       name,
+		Type:       qtype,
+		Server:     resp.Server,
+		RCode:      resp.RCode,
+		IsNotFound: resp.RCode == RCodeNameError,
+	}
+}
+
+// transportError wraps an error from sending a query or reading its response.
+func transportError(err error, name string, qtype Type, server string, timeout time.Duration) error {
+	dnsErr := &DNSError{
+		Name:   name,
+		Type:   qtype,
+		Server: server,
+		RCode:  RCodeServerFailure,
+		Err:    err,
+	}
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		dnsErr.IsTimeout = true
+		dnsErr.Timeout = timeout
+	}
+	return dnsErr
+}
+
+// ErrorStatus returns the dig style status for the outcome of a query: the
+// response code, with the reason when the query never got a response.
+// Errors from the net package resolver are mapped onto the same codes.
+func ErrorStatus(err error) string {
+	if err == nil {
+		return RCodeSuccess.String()
+	}
+	var dnsErr *DNSError
+	if errors.As(err, &dnsErr) {
+		return dnsErr.Status()
+	}
+	var netErr *net.DNSError
+	if errors.As(err, &netErr) {
+		switch {
+		case netErr.IsNotFound:
+			return RCodeNameError.String()
+		case netErr.IsTimeout:
+			return fmt.Sprintf("%s (timeout)", RCodeServerFailure)
+		}
+		return fmt.Sprintf("%s (%s)", RCodeServerFailure, netErr.Err)
+	}
+	return fmt.Sprintf("%s (%v)", RCodeServerFailure, err)
+}
+
+// isNotFound reports whether err means the name or record does not exist,
+// for both *DNSError and *net.DNSError.
+func isNotFound(err error) bool {
+	var dnsErr *DNSError
+	if errors.As(err, &dnsErr) {
+		return dnsErr.IsNotFound
+	}
+	var netErr *net.DNSError
+	return errors.As(err, &netErr) && netErr.IsNotFound
+}
@@ -0,0 +1,60 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorStatus(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected string
+	}{
+		{"no error", nil, "NOERROR"},
+		{"nxdomain", &DNSError{Name: "example.com.", Type: TypeA, RCode: RCodeNameError, IsNotFound: true}, "NXDOMAIN"},
+		{"servfail", &DNSError{Name: "example.com.", Type: TypeA, RCode: RCodeServerFailure}, "SERVFAIL"},
+		{"timeout", &DNSError{RCode: RCodeServerFailure, Err: context.DeadlineExceeded, IsTimeout: true, Timeout: 5 * time.Second}, "SERVFAIL (timeout after 5s)"},
+		{"transport", &DNSError{RCode: RCodeServerFailure, Err: errors.New("connection refused")}, "SERVFAIL (connection refused)"},
+		{"net not found", &net.DNSError{Err: "no such host", Name: "example.com", IsNotFound: true}, "NXDOMAIN"},
+		{"net timeout", &net.DNSError{Err: "i/o timeout", Name: "example.com", IsTimeout: true}, "SERVFAIL (timeout)"},
+		{"other", errors.New("boom"), "SERVFAIL (boom)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, ErrorStatus(tt.err))
+		})
+	}
+}
+
+func TestDNSErrorMessage(t *testing.T) {
+	err := &DNSError{Name: "example.com.", Type: TypeMX, Server: "10.0.0.2:53", RCode: RCodeRefused}
+	assert.Equal(t, "lookup example.com. MX on 10.0.0.2:53: REFUSED", err.Error())
+}
+
+func TestDNSClientErrorClasses(t *testing.T) {
+	servfail := startTestDNSServer(t, func(req *Message) *Message {
+		resp := replyTo(req)
+		resp.RCode = RCodeServerFailure
+		return resp
+	})
+	silent := startTestDNSServer(t, func(req *Message) *Message { return nil })
+
+	result := Dig(context.Background(), NewDNSClient(servfail), "example.com", TypeA)
+	assert.Equal(t, "SERVFAIL", result.Sections[0].Status())
+
+	client := &DNSClient{Server: silent, Timeout: 100 * time.Millisecond}
+	result = Dig(context.Background(), client, "example.com", TypeA)
+	assert.Equal(t, "SERVFAIL (timeout after 100ms)", result.Sections[0].Status())
+
+	var dnsErr *DNSError
+	assert.True(t, errors.As(result.Sections[0].Err, &dnsErr))
+	assert.True(t, dnsErr.IsTimeout)
+	assert.Equal(t, silent, dnsErr.Server)
+}
@@ -0,0 +1,504 @@
+package network
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Type is a DNS resource record type.
+type Type uint16
+
+// Supported resource record types.
+const (
+	TypeA      Type = 1
+	TypeNS     Type = 2
+	TypeCNAME  Type = 5
+	TypeSOA    Type = 6
+	TypePTR    Type = 12
+	TypeMX     Type = 15
+	TypeTXT    Type = 16
+	TypeAAAA   Type = 28
+	TypeSRV    Type = 33
+	TypeOPT    Type = 41
+	TypeDS     Type = 43
+	TypeRRSIG  Type = 46
+	TypeNSEC   Type = 47
+	TypeDNSKEY Type = 48
+	TypeNSEC3  Type = 50
+	TypeIXFR   Type = 251
+	TypeAXFR   Type = 252
+	TypeANY    Type = 255
+	TypeCAA    Type = 257
+)
+
+var typeNames = map[Type]string{
+	TypeA:      "A",
+	TypeNS:     "NS",
+	TypeCNAME:  "CNAME",
+	TypeSOA:    "SOA",
+	TypePTR:    "PTR",
+	TypeMX:     "MX",
+	TypeTXT:    "TXT",
+	TypeAAAA:   "AAAA",
+	TypeSRV:    "SRV",
+	TypeOPT:    "OPT",
+	TypeDS:     "DS",
+	TypeRRSIG:  "RRSIG",
+	TypeNSEC:   "NSEC",
+	TypeDNSKEY: "DNSKEY",
+	TypeNSEC3:  "NSEC3",
+	TypeIXFR:   "IXFR",
+	TypeAXFR:   "AXFR",
+	TypeANY:    "ANY",
+	TypeCAA:    "CAA",
+}
+
+// String returns the mnemonic for the type, or TYPEnnn for unknown types.
+func (t Type) String() string {
+	if name, ok := typeNames[t]; ok {
+		return name
+	}
+	return "TYPE" + strconv.Itoa(int(t))
+}
+
+// ParseType returns the type for a mnemonic such as "MX" or the generic
+// TYPEnnn form. Matching is case-insensitive.
+func ParseType(s string) (Type, error) {
+	upper := strings.ToUpper(s)
+	for t, name := range typeNames {
+		if name == upper {
+			return t, nil
+		}
+	}
+	if strings.HasPrefix(upper, "TYPE") {
+		n, err := strconv.ParseUint(upper[4:], 10, 16)
+		if err == nil {
+			return Type(n), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown record type: %s", s)
+}
+
+// Class is a DNS resource record class.
+type Class uint16
+
+// Supported resource record classes.
+const (
+	ClassINET  Class = 1
+	ClassCHAOS Class = 3
+	ClassANY   Class = 255
+)
+
+// String returns the mnemonic for the class, or CLASSnnn for unknown classes.
+func (c Class) String() string {
+	switch c {
+	case ClassINET:
+		return "IN"
+	case ClassCHAOS:
+		return "CH"
+	case ClassANY:
+		return "ANY"
+	}
+	return "CLASS" + strconv.Itoa(int(c))
+}
+
+// RCode is a DNS response code.
+type RCode uint16
+
+// Response codes defined in RFC 1035.
+const (
+	RCodeSuccess        RCode = 0
+	RCodeFormatError    RCode = 1
+	RCodeServerFailure  RCode = 2
+	RCodeNameError      RCode = 3
+	RCodeNotImplemented RCode = 4
+	RCodeRefused        RCode = 5
+)
+
+var rcodeNames = map[RCode]string{
+	RCodeSuccess:        "NOERROR",
+	RCodeFormatError:    "FORMERR",
+	RCodeServerFailure:  "SERVFAIL",
+	RCodeNameError:      "NXDOMAIN",
+	RCodeNotImplemented: "NOTIMP",
+	RCodeRefused:        "REFUSED",
+}
+
+// String returns the mnemonic for the response code.
+func (r RCode) String() string {
+	if name, ok := rcodeNames[r]; ok {
+		return name
+	}
+	return "RCODE" + strconv.Itoa(int(r))
+}
+
+var (
+	errMsgTruncated = errors.New("dns: message truncated")
+	errLabelTooLong = errors.New("dns: label longer than 63 octets")
+	errNameTooLong  = errors.New("dns: name longer than 255 octets")
+	errEmptyLabel   = errors.New("dns: empty label in name")
+	errPointerLoop  = errors.New("dns: too many compression pointers")
+	errBadLabel     = errors.New("dns: invalid label type")
+)
+
+// Header is the fixed header of a DNS message.
+type Header struct {
+	ID                 uint16
+	Response           bool
+	Opcode             uint8
+	Authoritative      bool
+	Truncated          bool
+	RecursionDesired   bool
+	RecursionAvailable bool
+	AuthenticData      bool
+	CheckingDisabled   bool
+	RCode              RCode
+}
+
+func (h Header) flags() uint16 {
+	f := uint16(h.Opcode&0x0F)<<11 | uint16(h.RCode&0x0F)
+	if h.Response {
+		f |= 1 << 15
+	}
+	if h.Authoritative {
+		f |= 1 << 10
+	}
+	if h.Truncated {
+		f |= 1 << 9
+	}
+	if h.RecursionDesired {
+		f |= 1 << 8
+	}
+	if h.RecursionAvailable {
+		f |= 1 << 7
+	}
+	if h.AuthenticData {
+		f |= 1 << 5
+	}
+	if h.CheckingDisabled {
+		f |= 1 << 4
+	}
+	return f
+}
+
+func (h *Header) setFlags(f uint16) {
+	h.Response = f&(1<<15) != 0
+	h.Opcode = uint8(f>>11) & 0x0F
+	h.Authoritative = f&(1<<10) != 0
+	h.Truncated = f&(1<<9) != 0
+	h.RecursionDesired = f&(1<<8) != 0
+	h.RecursionAvailable = f&(1<<7) != 0
+	h.AuthenticData = f&(1<<5) != 0
+	h.CheckingDisabled = f&(1<<4) != 0
+	h.RCode = RCode(f & 0x0F)
+}
+
+// Question is an entry in the question section of a DNS message.
+type Question struct {
+	Name  string
+	Type  Type
+	Class Class
+}
+
+// RR is a resource record.
+type RR struct {
+	Name  string
+	Type  Type
+	Class Class
+	TTL   uint32
+	Data  RData
+}
+
+// String formats the record in zone file presentation format.
+func (rr RR) String() string {
+	data := ""
+	if rr.Data != nil {
+		data = rr.Data.String()
+	}
+	return fmt.Sprintf("%s\t%d\t%s\t%s\t%s", rr.Name, rr.TTL, rr.Class, rr.Type, data)
+}
+
+// Message is a DNS message as sent on the wire.
+type Message struct {
+	Header
+	Question   []Question
+	Answer     []RR
+	Authority  []RR
+	Additional []RR
+}
+
+// NewQuery builds a recursive query for a single name and type.
+func NewQuery(name string, qtype Type) *Message {
+	return &Message{
+		Header:   Header{ID: newMessageID(), RecursionDesired: true},
+		Question: []Question{{Name: Fqdn(name), Type: qtype, Class: ClassINET}},
+	}
+}
+
+// Pack encodes the message into wire format without name compression.
+func (m *Message) Pack() ([]byte, error) {
+	b := make([]byte, 12, 512)
+	binary.BigEndian.PutUint16(b[0:], m.ID)
+	binary.BigEndian.PutUint16(b[2:], m.flags())
+	binary.BigEndian.PutUint16(b[4:], uint16(len(m.Question)))
+	binary.BigEndian.PutUint16(b[6:], uint16(len(m.Answer)))
+	binary.BigEndian.PutUint16(b[8:], uint16(len(m.Authority)))
+	binary.BigEndian.PutUint16(b[10:], uint16(len(m.Additional)))
+
+	var err error
+	for _, q := range m.Question {
+		if b, err = packName(b, q.Name); err != nil {
+			return nil, err
+		}
+		b = binary.BigEndian.AppendUint16(b, uint16(q.Type))
+		b = binary.BigEndian.AppendUint16(b, uint16(q.Class))
+	}
+	for _, section := range [][]RR{m.Answer, m.Authority, m.Additional} {
+		for _, rr := range section {
+			if rr.Type == TypeOPT {
+				// The upper eight bits of an extended RCODE live in the OPT TTL.
+				rr.TTL = rr.TTL&0x00FFFFFF | uint32(m.RCode>>4)<<24
+			}
+			if b, err = packRR(b, rr); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return b, nil
+}
+
+// Unpack decodes a wire format message into m.
+func (m *Message) Unpack(msg []byte) error {
+	if len(msg) < 12 {
+		return errMsgTruncated
+	}
+	*m = Message{}
+	m.ID = binary.BigEndian.Uint16(msg[0:])
+	m.setFlags(binary.BigEndian.Uint16(msg[2:]))
+	qdcount := int(binary.BigEndian.Uint16(msg[4:]))
+	counts := []int{
+		int(binary.BigEndian.Uint16(msg[6:])),
+		int(binary.BigEndian.Uint16(msg[8:])),
+		int(binary.BigEndian.Uint16(msg[10:])),
+	}
+
+	off := 12
+	for i := 0; i < qdcount; i++ {
+		name, n, err := unpackName(msg, off)
+		if err != nil {
+			return err
+		}
+		if n+4 > len(msg) {
+			return errMsgTruncated
+		}
+		m.Question = append(m.Question, Question{
+			Name:  name,
+			Type:  Type(binary.BigEndian.Uint16(msg[n:])),
+			Class: Class(binary.BigEndian.Uint16(msg[n+2:])),
+		})
+		off = n + 4
+	}
+
+	sections := []*[]RR{&m.Answer, &m.Authority, &m.Additional}
+	for i, section := range sections {
+		for j := 0; j < counts[i]; j++ {
+			rr, n, err := unpackRR(msg, off)
+			if err != nil {
+				return err
+			}
+			*section = append(*section, rr)
+			off = n
+		}
+	}
+	if opt := m.OPT(); opt != nil {
+		m.RCode |= RCode(opt.TTL>>24) << 4
+	}
+	return nil
+}
+
+func packRR(b []byte, rr RR) ([]byte, error) {
+	b, err := packName(b, rr.Name)
+	if err != nil {
+		return nil, err
+	}
+	b = binary.BigEndian.AppendUint16(b, uint16(rr.Type))
+	b = binary.BigEndian.AppendUint16(b, uint16(rr.Class))
+	b = binary.BigEndian.AppendUint32(b, rr.TTL)
+	lenOff := len(b)
+	b = append(b, 0, 0)
+	if rr.Data != nil {
+		if b, err = rr.Data.pack(b); err != nil {
+			return nil, err
+		}
+	}
+	rdlen := len(b) - lenOff - 2
+	if rdlen > 0xFFFF {
+		return nil, fmt.Errorf("dns: rdata for %s too long", rr.Name)
+	}
+	binary.BigEndian.PutUint16(b[lenOff:], uint16(rdlen))
+	return b, nil
+}
+
+func unpackRR(msg []byte, off int) (RR, int, error) {
+	name, off, err := unpackName(msg, off)
+	if err != nil {
+		return RR{}, 0, err
+	}
+	if off+10 > len(msg) {
+		return RR{}, 0, errMsgTruncated
+	}
+	rr := RR{
+		Name:  name,
+		Type:  Type(binary.BigEndian.Uint16(msg[off:])),
+		Class: Class(binary.BigEndian.Uint16(msg[off+2:])),
+		TTL:   binary.BigEndian.Uint32(msg[off+4:]),
+	}
+	rdlen := int(binary.BigEndian.Uint16(msg[off+8:]))
+	off += 10
+	if off+rdlen > len(msg) {
+		return RR{}, 0, errMsgTruncated
+	}
+	rr.Data, err = unpackRData(msg, off, rdlen, rr.Type)
+	if err != nil {
+		return RR{}, 0, fmt.Errorf("dns: %s %s record: %v", rr.Name, rr.Type, err)
+	}
+	return rr, off + rdlen, nil
+}
+
+// Fqdn returns name with a trailing dot appended if it is missing.
+func Fqdn(name string) string {
+	if strings.HasSuffix(name, ".") && !strings.HasSuffix(name, "\\.") {
+		return name
+	}
+	return name + "."
+}
+
+// packName appends the uncompressed wire form of a presentation format name.
+func packName(b []byte, name string) ([]byte, error) {
+	if name == "" || name == "." {
+		return append(b, 0), nil
+	}
+	start := len(b)
+	label := make([]byte, 0, 63)
+	flush := func() error {
+		if len(label) == 0 {
+			return errEmptyLabel
+		}
+		if len(label) > 63 {
+			return errLabelTooLong
+		}
+		b = append(b, byte(len(label)))
+		b = append(b, label...)
+		label = label[:0]
+		return nil
+	}
+
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		switch c {
+		case '\\':
+			if i+3 < len(name) && isDigit(name[i+1]) && isDigit(name[i+2]) && isDigit(name[i+3]) {
+				v, _ := strconv.Atoi(name[i+1 : i+4])
+				if v > 255 {
+					return nil, fmt.Errorf("dns: invalid escape in %q", name)
+				}
+				label = append(label, byte(v))
+				i += 3
+			} else if i+1 < len(name) {
+				label = append(label, name[i+1])
+				i++
+			} else {
+				return nil, fmt.Errorf("dns: trailing backslash in %q", name)
+			}
+		case '.':
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		default:
+			label = append(label, c)
+		}
+	}
+	if len(label) > 0 {
+		if err := flush(); err != nil {
+			return nil, err
+		}
+	}
+	b = append(b, 0)
+	if len(b)-start > 255 {
+		return nil, errNameTooLong
+	}
+	return b, nil
+}
+
+// unpackName decodes a possibly compressed name at off and returns it with
+// the offset just past the name in the original message.
+func unpackName(msg []byte, off int) (string, int, error) {
+	var sb strings.Builder
+	end := -1
+	ptrs := 0
+	wire := 0
+	for {
+		if off >= len(msg) {
+			return "", 0, errMsgTruncated
+		}
+		c := int(msg[off])
+		switch c & 0xC0 {
+		case 0x00:
+			if c == 0 {
+				off++
+				if end < 0 {
+					end = off
+				}
+				if sb.Len() == 0 {
+					return ".", end, nil
+				}
+				return sb.String(), end, nil
+			}
+			if off+1+c > len(msg) {
+				return "", 0, errMsgTruncated
+			}
+			wire += c + 1
+			if wire > 255 {
+				return "", 0, errNameTooLong
+			}
+			for _, ch := range msg[off+1 : off+1+c] {
+				writeLabelByte(&sb, ch)
+			}
+			sb.WriteByte('.')
+			off += 1 + c
+		case 0xC0:
+			if off+1 >= len(msg) {
+				return "", 0, errMsgTruncated
+			}
+			if end < 0 {
+				end = off + 2
+			}
+			ptrs++
+			if ptrs > 127 {
+				return "", 0, errPointerLoop
+			}
+			off = int(binary.BigEndian.Uint16(msg[off:]) & 0x3FFF)
+		default:
+			return "", 0, errBadLabel
+		}
+	}
+}
+
+func writeLabelByte(sb *strings.Builder, ch byte) {
+	switch {
+	case ch == '.' || ch == '\\':
+		sb.WriteByte('\\')
+		sb.WriteByte(ch)
+	case ch < '!' || ch > '~':
+		fmt.Fprintf(sb, "\\%03d", ch)
+	default:
+		sb.WriteByte(ch)
+	}
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
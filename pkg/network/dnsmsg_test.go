@@ -0,0 +1,170 @@
+package network
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessagePackUnpack(t *testing.T) {
+	msg := &Message{
+		Header: Header{ID: 0xBEEF, Response: true, Authoritative: true, RecursionDesired: true, RCode: RCodeSuccess},
+		Question: []Question{
+			{Name: "example.com.", Type: TypeA, Class: ClassINET},
+		},
+		Answer: []RR{
+			{Name: "example.com.", Type: TypeA, Class: ClassINET, TTL: 300, Data: &A{IP: net.IPv4(93, 184, 216, 34).To4()}},
+			{Name: "example.com.", Type: TypeAAAA, Class: ClassINET, TTL: 300, Data: &AAAA{IP: net.ParseIP("2001:db8::1")}},
+			{Name: "example.com.", Type: TypeMX, Class: ClassINET, TTL: 60, Data: &MX{Pref: 10, Host: "mail.example.com."}},
+			{Name: "example.com.", Type: TypeTXT, Class: ClassINET, TTL: 60, Data: &TXT{Strings: []string{"v=spf1", "-all"}}},
+		},
+		Authority: []RR{
+			{Name: "example.com.", Type: TypeSOA, Class: ClassINET, TTL: 3600, Data: &SOA{
+				MName: "ns1.example.com.", RName: "hostmaster.example.com.",
+				Serial: 2024060101, Refresh: 7200, Retry: 900, Expire: 1209600, Minimum: 300,
+			}},
+		},
+		Additional: []RR{
+			{Name: "ns1.example.com.", Type: TypeNS, Class: ClassINET, TTL: 60, Data: &NS{Host: "ns1.example.net."}},
+			{Name: "example.com.", Type: Type(65280), Class: ClassINET, TTL: 60, Data: &Unknown{Data: []byte{1, 2, 3}}},
+			{Name: "_sip._tcp.example.com.", Type: TypeSRV, Class: ClassINET, TTL: 60, Data: &SRV{Priority: 10, Weight: 5, Port: 5060, Target: "sip.example.com."}},
+			{Name: "example.com.", Type: TypeCAA, Class: ClassINET, TTL: 60, Data: &CAA{Flags: 0, Tag: "issue", Value: "letsencrypt.org"}},
+			{Name: "example.com.", Type: TypeDS, Class: ClassINET, TTL: 60, Data: &DS{KeyTag: 370, Algorithm: 13, DigestType: 2, Digest: []byte{0xde, 0xad}}},
+			{Name: "example.com.", Type: TypeDNSKEY, Class: ClassINET, TTL: 60, Data: &DNSKEY{Flags: 257, Protocol: 3, Algorithm: 13, PublicKey: []byte{0xbe, 0xef}}},
+		},
+	}
+
+	wire, err := msg.Pack()
+	assert.NoError(t, err)
+
+	got := &Message{}
+	assert.NoError(t, got.Unpack(wire))
+	assert.Equal(t, msg, got)
+}
+
+func TestUnpackCompressedName(t *testing.T) {
+	// Header with one question and one answer whose owner name is a
+	// pointer to the question name at offset 12.
+	wire := []byte{
+		0x12, 0x34, 0x81, 0x80, 0, 1, 0, 1, 0, 0, 0, 0,
+		7, 'e', 'x', 'a', 'm', 'p', 'l', 'e', 3, 'c', 'o', 'm', 0,
+		0, 5, 0, 1,
+		0xC0, 12, 0, 5, 0, 1, 0, 0, 0, 30, 0, 6,
+		3, 'w', 'w', 'w', 0xC0, 12,
+	}
+
+	msg := &Message{}
+	assert.NoError(t, msg.Unpack(wire))
+	assert.Equal(t, "example.com.", msg.Question[0].Name)
+	assert.Equal(t, "example.com.", msg.Answer[0].Name)
+	assert.Equal(t, &CNAME{Target: "www.example.com."}, msg.Answer[0].Data)
+}
+
+func TestUnpackNameErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		wire []byte
+	}{
+		{"truncated label", []byte{5, 'a', 'b'}},
+		{"pointer loop", []byte{0xC0, 0}},
+		{"reserved label type", []byte{0x80}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := unpackName(tt.wire, 0)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestPackName(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		expected  []byte
+		expectErr bool
+	}{
+		{"root", ".", []byte{0}, false},
+		{"relative", "a.bc", []byte{1, 'a', 2, 'b', 'c', 0}, false},
+		{"fully qualified", "a.bc.", []byte{1, 'a', 2, 'b', 'c', 0}, false},
+		{"escaped dot", `a\.b.c.`, []byte{3, 'a', '.', 'b', 1, 'c', 0}, false},
+		{"decimal escape", `\065.`, []byte{1, 'A', 0}, false},
+		{"empty label", "a..b", nil, true},
+		{"label too long", string(make([]byte, 64)) + ".", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := packName(nil, tt.input)
+			if tt.expectErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestParseType(t *testing.T) {
+	tests := []struct {
+		input     string
+		expected  Type
+		expectErr bool
+	}{
+		{"A", TypeA, false},
+		{"srv", TypeSRV, false},
+		{"CAA", TypeCAA, false},
+		{"ANY", TypeANY, false},
+		{"TYPE65280", Type(65280), false},
+		{"BOGUS", 0, true},
+		{"TYPE70000", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			result, err := ParseType(tt.input)
+			if tt.expectErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestRDataString(t *testing.T) {
+	tests := []struct {
+		data     RData
+		expected string
+	}{
+		{&SRV{Priority: 10, Weight: 5, Port: 5060, Target: "sip.example.com."}, "10 5 5060 sip.example.com."},
+		{&CAA{Flags: 128, Tag: "issue", Value: "ca.example.net"}, `128 issue "ca.example.net"`},
+		{&DS{KeyTag: 20326, Algorithm: 8, DigestType: 2, Digest: []byte{0xe0, 0x6d}}, "20326 8 2 E06D"},
+		{&DNSKEY{Flags: 256, Protocol: 3, Algorithm: 15, PublicKey: []byte("key")}, "256 3 15 a2V5"},
+		{&Unknown{Data: []byte{0xab}}, `\# 1 ab`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expected, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.data.String())
+		})
+	}
+}
+
+func TestTypeClassRCodeString(t *testing.T) {
+	assert.Equal(t, "MX", TypeMX.String())
+	assert.Equal(t, "TYPE65280", Type(65280).String())
+	assert.Equal(t, "IN", ClassINET.String())
+	assert.Equal(t, "CLASS42", Class(42).String())
+	assert.Equal(t, "NXDOMAIN", RCodeNameError.String())
+	assert.Equal(t, "RCODE15", RCode(15).String())
+}
+
+func TestRRString(t *testing.T) {
+	rr := RR{Name: "example.com.", Type: TypeTXT, Class: ClassINET, TTL: 60, Data: &TXT{Strings: []string{`say "hi"`}}}
+	assert.Equal(t, "example.com.\t60\tIN\tTXT\t\"say \\\"hi\\\"\"", rr.String())
+}
@@ -0,0 +1,591 @@
+package network
+
+import (
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RData is the type specific data of a resource record.
+type RData interface {
+	// String returns the data in zone file presentation format.
+	String() string
+	pack(b []byte) ([]byte, error)
+}
+
+// A is an IPv4 address record.
+type A struct {
+	IP net.IP
+}
+
+func (r *A) String() string { return r.IP.String() }
+
+func (r *A) pack(b []byte) ([]byte, error) {
+	ip := r.IP.To4()
+	if ip == nil {
+		return nil, fmt.Errorf("dns: %v is not an IPv4 address", r.IP)
+	}
+	return append(b, ip...), nil
+}
+
+// AAAA is an IPv6 address record.
+type AAAA struct {
+	IP net.IP
+}
+
+func (r *AAAA) String() string { return r.IP.String() }
+
+func (r *AAAA) pack(b []byte) ([]byte, error) {
+	ip := r.IP.To16()
+	if ip == nil {
+		return nil, fmt.Errorf("dns: %v is not an IPv6 address", r.IP)
+	}
+	return append(b, ip...), nil
+}
+
+// NS is a nameserver record.
+type NS struct {
+	Host string
+}
+
+func (r *NS) String() string { return r.Host }
+
+func (r *NS) pack(b []byte) ([]byte, error) { return packName(b, r.Host) }
+
+// CNAME is a canonical name record.
+type CNAME struct {
+	Target string
+}
+
+func (r *CNAME) String() string { return r.Target }
+
+func (r *CNAME) pack(b []byte) ([]byte, error) { return packName(b, r.Target) }
+
+// PTR is a pointer record.
+type PTR struct {
+	Host string
+}
+
+func (r *PTR) String() string { return r.Host }
+
+func (r *PTR) pack(b []byte) ([]byte, error) { return packName(b, r.Host) }
+
+// MX is a mail exchanger record.
+type MX struct {
+	Pref uint16
+	Host string
+}
+
+func (r *MX) String() string { return fmt.Sprintf("%d %s", r.Pref, r.Host) }
+
+func (r *MX) pack(b []byte) ([]byte, error) {
+	b = binary.BigEndian.AppendUint16(b, r.Pref)
+	return packName(b, r.Host)
+}
+
+// TXT is a text record made up of one or more character strings.
+type TXT struct {
+	Strings []string
+}
+
+func (r *TXT) String() string {
+	quoted := make([]string, len(r.Strings))
+	for i, s := range r.Strings {
+		quoted[i] = quoteString(s)
+	}
+	return strings.Join(quoted, " ")
+}
+
+func (r *TXT) pack(b []byte) ([]byte, error) {
+	for _, s := range r.Strings {
+		if len(s) > 255 {
+			return nil, errors.New("dns: TXT string longer than 255 octets")
+		}
+		b = append(b, byte(len(s)))
+		b = append(b, s...)
+	}
+	return b, nil
+}
+
+// SOA is a start of authority record.
+type SOA struct {
+	MName   string
+	RName   string
+	Serial  uint32
+	Refresh uint32
+	Retry   uint32
+	Expire  uint32
+	Minimum uint32
+}
+
+func (r *SOA) String() string {
+	return fmt.Sprintf("%s %s %d %d %d %d %d",
+		r.MName, r.RName, r.Serial, r.Refresh, r.Retry, r.Expire, r.Minimum)
+}
+
+func (r *SOA) pack(b []byte) ([]byte, error) {
+	b, err := packName(b, r.MName)
+	if err != nil {
+		return nil, err
+	}
+	if b, err = packName(b, r.RName); err != nil {
+		return nil, err
+	}
+	for _, v := range []uint32{r.Serial, r.Refresh, r.Retry, r.Expire, r.Minimum} {
+		b = binary.BigEndian.AppendUint32(b, v)
+	}
+	return b, nil
+}
+
+// SRV is a service location record.
+type SRV struct {
+	Priority uint16
+	Weight   uint16
+	Port     uint16
+	Target   string
+}
+
+func (r *SRV) String() string {
+	return fmt.Sprintf("%d %d %d %s", r.Priority, r.Weight, r.Port, r.Target)
+}
+
+func (r *SRV) pack(b []byte) ([]byte, error) {
+	b = binary.BigEndian.AppendUint16(b, r.Priority)
+	b = binary.BigEndian.AppendUint16(b, r.Weight)
+	b = binary.BigEndian.AppendUint16(b, r.Port)
+	return packName(b, r.Target)
+}
+
+// CAA is a certification authority authorization record.
+type CAA struct {
+	Flags uint8
+	Tag   string
+	Value string
+}
+
+func (r *CAA) String() string {
+	return fmt.Sprintf("%d %s %s", r.Flags, r.Tag, quoteString(r.Value))
+}
+
+func (r *CAA) pack(b []byte) ([]byte, error) {
+	if len(r.Tag) == 0 || len(r.Tag) > 255 {
+		return nil, errors.New("dns: invalid CAA tag length")
+	}
+	b = append(b, r.Flags, byte(len(r.Tag)))
+	b = append(b, r.Tag...)
+	return append(b, r.Value...), nil
+}
+
+// DS is a delegation signer record.
+type DS struct {
+	KeyTag     uint16
+	Algorithm  uint8
+	DigestType uint8
+	Digest     []byte
+}
+
+func (r *DS) String() string {
+	return fmt.Sprintf("%d %d %d %s", r.KeyTag, r.Algorithm, r.DigestType, strings.ToUpper(hex.EncodeToString(r.Digest)))
+}
+
+func (r *DS) pack(b []byte) ([]byte, error) {
+	b = binary.BigEndian.AppendUint16(b, r.KeyTag)
+	b = append(b, r.Algorithm, r.DigestType)
+	return append(b, r.Digest...), nil
+}
+
+// DNSKEY is a DNSSEC public key record.
+type DNSKEY struct {
+	Flags     uint16
+	Protocol  uint8
+	Algorithm uint8
+	PublicKey []byte
+}
+
+func (r *DNSKEY) String() string {
+	return fmt.Sprintf("%d %d %d %s", r.Flags, r.Protocol, r.Algorithm, base64.StdEncoding.EncodeToString(r.PublicKey))
+}
+
+func (r *DNSKEY) pack(b []byte) ([]byte, error) {
+	b = binary.BigEndian.AppendUint16(b, r.Flags)
+	b = append(b, r.Protocol, r.Algorithm)
+	return append(b, r.PublicKey...), nil
+}
+
+// RRSIG is a DNSSEC signature over the RRset of one type (RFC 4034).
+type RRSIG struct {
+	TypeCovered Type
+	Algorithm   uint8
+	Labels      uint8
+	OrigTTL     uint32
+	Expiration  uint32
+	Inception   uint32
+	KeyTag      uint16
+	SignerName  string
+	Signature   []byte
+}
+
+func (r *RRSIG) String() string {
+	return fmt.Sprintf("%s %d %d %d %s %s %d %s %s", r.TypeCovered, r.Algorithm, r.Labels, r.OrigTTL,
+		sigTime(r.Expiration), sigTime(r.Inception), r.KeyTag, r.SignerName, base64.StdEncoding.EncodeToString(r.Signature))
+}
+
+func (r *RRSIG) pack(b []byte) ([]byte, error) {
+	b, err := r.packSigned(b)
+	if err != nil {
+		return nil, err
+	}
+	return append(b, r.Signature...), nil
+}
+
+// packSigned packs the fields that precede the signature, which are part
+// of the signed data.
+func (r *RRSIG) packSigned(b []byte) ([]byte, error) {
+	b = binary.BigEndian.AppendUint16(b, uint16(r.TypeCovered))
+	b = append(b, r.Algorithm, r.Labels)
+	b = binary.BigEndian.AppendUint32(b, r.OrigTTL)
+	b = binary.BigEndian.AppendUint32(b, r.Expiration)
+	b = binary.BigEndian.AppendUint32(b, r.Inception)
+	b = binary.BigEndian.AppendUint16(b, r.KeyTag)
+	return packName(b, r.SignerName)
+}
+
+// NSEC proves the nonexistence of names and types between its owner and
+// NextDomain (RFC 4034).
+type NSEC struct {
+	NextDomain string
+	Types      []Type
+}
+
+func (r *NSEC) String() string {
+	return strings.TrimSpace(r.NextDomain + " " + typeList(r.Types))
+}
+
+func (r *NSEC) pack(b []byte) ([]byte, error) {
+	b, err := packName(b, r.NextDomain)
+	if err != nil {
+		return nil, err
+	}
+	return packTypeBitmap(b, r.Types), nil
+}
+
+// NSEC3 is the hashed form of NSEC (RFC 5155).
+type NSEC3 struct {
+	HashAlgorithm uint8
+	Flags         uint8
+	Iterations    uint16
+	Salt          []byte
+	NextHashed    []byte
+	Types         []Type
+}
+
+func (r *NSEC3) String() string {
+	salt := "-"
+	if len(r.Salt) > 0 {
+		salt = strings.ToUpper(hex.EncodeToString(r.Salt))
+	}
+	return strings.TrimSpace(fmt.Sprintf("%d %d %d %s %s %s", r.HashAlgorithm, r.Flags, r.Iterations, salt,
+		base32Hex.EncodeToString(r.NextHashed), typeList(r.Types)))
+}
+
+func (r *NSEC3) pack(b []byte) ([]byte, error) {
+	if len(r.Salt) > 255 || len(r.NextHashed) > 255 {
+		return nil, errors.New("dns: NSEC3 salt or hash too long")
+	}
+	b = append(b, r.HashAlgorithm, r.Flags)
+	b = binary.BigEndian.AppendUint16(b, r.Iterations)
+	b = append(b, byte(len(r.Salt)))
+	b = append(b, r.Salt...)
+	b = append(b, byte(len(r.NextHashed)))
+	b = append(b, r.NextHashed...)
+	return packTypeBitmap(b, r.Types), nil
+}
+
+// EDNSOption is a single option carried in an OPT record.
+type EDNSOption struct {
+	Code uint16
+	Data []byte
+}
+
+// OPT is the EDNS(0) pseudo record. Its class holds the requestor's UDP
+// payload size and its TTL holds the extended RCODE, version and flags.
+type OPT struct {
+	Options []EDNSOption
+}
+
+func (r *OPT) String() string {
+	parts := make([]string, len(r.Options))
+	for i, o := range r.Options {
+		parts[i] = o.String()
+	}
+	return strings.Join(parts, "; ")
+}
+
+func (r *OPT) pack(b []byte) ([]byte, error) {
+	for _, o := range r.Options {
+		b = binary.BigEndian.AppendUint16(b, o.Code)
+		b = binary.BigEndian.AppendUint16(b, uint16(len(o.Data)))
+		b = append(b, o.Data...)
+	}
+	return b, nil
+}
+
+// Unknown holds the raw data of a record type without a decoder.
+type Unknown struct {
+	Data []byte
+}
+
+// String uses the generic RFC 3597 notation.
+func (r *Unknown) String() string {
+	return fmt.Sprintf("\\# %d %s", len(r.Data), hex.EncodeToString(r.Data))
+}
+
+func (r *Unknown) pack(b []byte) ([]byte, error) { return append(b, r.Data...), nil }
+
+// unpackRData decodes length octets of rdata at off for the given type.
+func unpackRData(msg []byte, off, length int, typ Type) (RData, error) {
+	end := off + length
+	data := msg[off:end]
+	name := func(at int) (string, int, error) {
+		return unpackName(msg[:end], at)
+	}
+
+	switch typ {
+	case TypeA:
+		if length != net.IPv4len {
+			return nil, errMsgTruncated
+		}
+		return &A{IP: net.IP(append([]byte(nil), data...))}, nil
+	case TypeAAAA:
+		if length != net.IPv6len {
+			return nil, errMsgTruncated
+		}
+		return &AAAA{IP: net.IP(append([]byte(nil), data...))}, nil
+	case TypeNS:
+		host, _, err := name(off)
+		return &NS{Host: host}, err
+	case TypeCNAME:
+		target, _, err := name(off)
+		return &CNAME{Target: target}, err
+	case TypePTR:
+		host, _, err := name(off)
+		return &PTR{Host: host}, err
+	case TypeMX:
+		if length < 3 {
+			return nil, errMsgTruncated
+		}
+		host, _, err := name(off + 2)
+		return &MX{Pref: binary.BigEndian.Uint16(data), Host: host}, err
+	case TypeTXT:
+		txt := &TXT{}
+		for i := 0; i < len(data); {
+			n := int(data[i])
+			if i+1+n > len(data) {
+				return nil, errMsgTruncated
+			}
+			txt.Strings = append(txt.Strings, string(data[i+1:i+1+n]))
+			i += 1 + n
+		}
+		return txt, nil
+	case TypeSOA:
+		mname, next, err := name(off)
+		if err != nil {
+			return nil, err
+		}
+		rname, next, err := name(next)
+		if err != nil {
+			return nil, err
+		}
+		if next+20 > end {
+			return nil, errMsgTruncated
+		}
+		return &SOA{
+			MName:   mname,
+			RName:   rname,
+			Serial:  binary.BigEndian.Uint32(msg[next:]),
+			Refresh: binary.BigEndian.Uint32(msg[next+4:]),
+			Retry:   binary.BigEndian.Uint32(msg[next+8:]),
+			Expire:  binary.BigEndian.Uint32(msg[next+12:]),
+			Minimum: binary.BigEndian.Uint32(msg[next+16:]),
+		}, nil
+	case TypeSRV:
+		if length < 7 {
+			return nil, errMsgTruncated
+		}
+		target, _, err := name(off + 6)
+		return &SRV{
+			Priority: binary.BigEndian.Uint16(data),
+			Weight:   binary.BigEndian.Uint16(data[2:]),
+			Port:     binary.BigEndian.Uint16(data[4:]),
+			Target:   target,
+		}, err
+	case TypeCAA:
+		if length < 2 || 2+int(data[1]) > length {
+			return nil, errMsgTruncated
+		}
+		tagEnd := 2 + int(data[1])
+		return &CAA{Flags: data[0], Tag: string(data[2:tagEnd]), Value: string(data[tagEnd:])}, nil
+	case TypeDS:
+		if length < 4 {
+			return nil, errMsgTruncated
+		}
+		return &DS{
+			KeyTag:     binary.BigEndian.Uint16(data),
+			Algorithm:  data[2],
+			DigestType: data[3],
+			Digest:     append([]byte(nil), data[4:]...),
+		}, nil
+	case TypeDNSKEY:
+		if length < 4 {
+			return nil, errMsgTruncated
+		}
+		return &DNSKEY{
+			Flags:     binary.BigEndian.Uint16(data),
+			Protocol:  data[2],
+			Algorithm: data[3],
+			PublicKey: append([]byte(nil), data[4:]...),
+		}, nil
+	case TypeRRSIG:
+		if length < 18 {
+			return nil, errMsgTruncated
+		}
+		signer, next, err := name(off + 18)
+		if err != nil {
+			return nil, err
+		}
+		return &RRSIG{
+			TypeCovered: Type(binary.BigEndian.Uint16(data)),
+			Algorithm:   data[2],
+			Labels:      data[3],
+			OrigTTL:     binary.BigEndian.Uint32(data[4:]),
+			Expiration:  binary.BigEndian.Uint32(data[8:]),
+			Inception:   binary.BigEndian.Uint32(data[12:]),
+			KeyTag:      binary.BigEndian.Uint16(data[16:]),
+			SignerName:  signer,
+			Signature:   append([]byte(nil), msg[next:end]...),
+		}, nil
+	case TypeNSEC:
+		next, at, err := name(off)
+		if err != nil {
+			return nil, err
+		}
+		types, err := unpackTypeBitmap(msg[at:end])
+		return &NSEC{NextDomain: next, Types: types}, err
+	case TypeNSEC3:
+		if length < 5 || 5+int(data[4]) >= length {
+			return nil, errMsgTruncated
+		}
+		saltEnd := 5 + int(data[4])
+		hashEnd := saltEnd + 1 + int(data[saltEnd])
+		if hashEnd > length {
+			return nil, errMsgTruncated
+		}
+		types, err := unpackTypeBitmap(data[hashEnd:])
+		return &NSEC3{
+			HashAlgorithm: data[0],
+			Flags:         data[1],
+			Iterations:    binary.BigEndian.Uint16(data[2:]),
+			Salt:          append([]byte(nil), data[5:saltEnd]...),
+			NextHashed:    append([]byte(nil), data[saltEnd+1:hashEnd]...),
+			Types:         types,
+		}, err
+	case TypeOPT:
+		opt := &OPT{}
+		for i := 0; i < len(data); {
+			if i+4 > len(data) {
+				return nil, errMsgTruncated
+			}
+			code := binary.BigEndian.Uint16(data[i:])
+			n := int(binary.BigEndian.Uint16(data[i+2:]))
+			if i+4+n > len(data) {
+				return nil, errMsgTruncated
+			}
+			opt.Options = append(opt.Options, EDNSOption{Code: code, Data: append([]byte(nil), data[i+4:i+4+n]...)})
+			i += 4 + n
+		}
+		return opt, nil
+	}
+	return &Unknown{Data: append([]byte(nil), data...)}, nil
+}
+
+// base32Hex is the unpadded base32 alphabet NSEC3 uses for hashed names.
+var base32Hex = base32.HexEncoding.WithPadding(base32.NoPadding)
+
+// sigTime formats an RRSIG timestamp as YYYYMMDDHHmmSS.
+func sigTime(t uint32) string {
+	return time.Unix(int64(t), 0).UTC().Format("20060102150405")
+}
+
+// typeList formats the types of an NSEC or NSEC3 bitmap.
+func typeList(types []Type) string {
+	names := make([]string, len(types))
+	for i, t := range types {
+		names[i] = t.String()
+	}
+	return strings.Join(names, " ")
+}
+
+// packTypeBitmap appends the windowed type bitmap of RFC 4034 section 4.1.2.
+func packTypeBitmap(b []byte, types []Type) []byte {
+	sorted := append([]Type(nil), types...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	for i := 0; i < len(sorted); {
+		window := byte(sorted[i] >> 8)
+		var bitmap [32]byte
+		n := 0
+		for ; i < len(sorted) && byte(sorted[i]>>8) == window; i++ {
+			lo := byte(sorted[i])
+			bitmap[lo/8] |= 0x80 >> (lo % 8)
+			n = int(lo/8) + 1
+		}
+		b = append(b, window, byte(n))
+		b = append(b, bitmap[:n]...)
+	}
+	return b
+}
+
+func unpackTypeBitmap(data []byte) ([]Type, error) {
+	var types []Type
+	for len(data) > 0 {
+		if len(data) < 2 || data[1] == 0 || data[1] > 32 || 2+int(data[1]) > len(data) {
+			return nil, errMsgTruncated
+		}
+		window, n := Type(data[0])<<8, int(data[1])
+		for i, octet := range data[2 : 2+n] {
+			for bit := 0; bit < 8; bit++ {
+				if octet&(0x80>>bit) != 0 {
+					types = append(types, window|Type(i*8+bit))
+				}
+			}
+		}
+		data = data[2+n:]
+	}
+	return types, nil
+}
+
+// quoteString renders a character string with quotes and escapes.
+func quoteString(s string) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"' || c == '\\':
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+		case c < ' ' || c > '~':
+			fmt.Fprintf(&sb, "\\%03d", c)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
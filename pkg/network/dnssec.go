@@ -0,0 +1,980 @@
+package network
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DNSSEC signing algorithms (RFC 8624) that signatures can be verified for.
+const (
+	AlgorithmRSASHA1          uint8 = 5
+	AlgorithmRSASHA1NSEC3SHA1 uint8 = 7
+	AlgorithmRSASHA256        uint8 = 8
+	AlgorithmRSASHA512        uint8 = 10
+	AlgorithmECDSAP256SHA256  uint8 = 13
+	AlgorithmECDSAP384SHA384  uint8 = 14
+	AlgorithmED25519          uint8 = 15
+)
+
+// DS digest types.
+const (
+	DigestSHA1   uint8 = 1
+	DigestSHA256 uint8 = 2
+	DigestSHA384 uint8 = 4
+)
+
+const (
+	dnskeyZoneFlag = 0x0100
+	nsec3OptOut    = 0x01
+	nsec3SHA1      = 1
+	rootZone       = "."
+
+	// typeDNAME only needs recognising, to skip aliased names in the walk.
+	typeDNAME Type = 39
+)
+
+// RootTrustAnchors are the DS records of the root zone's key signing keys,
+// KSK-2017 and KSK-2024, as published in the IANA trust anchor document.
+var RootTrustAnchors = []RR{
+	rootAnchor(20326, "E06D44B80B8F1D39A95C0B0D7C65D08458E880409BBC683457104237C7F8EC8D"),
+	rootAnchor(38696, "683D2D0ACB8C9B712A1948B27F741219298D0A450D612C483AF444A4C0FB2B16"),
+}
+
+func rootAnchor(tag uint16, digest string) RR {
+	d, _ := hex.DecodeString(digest)
+	return RR{Name: rootZone, Type: TypeDS, Class: ClassINET, Data: &DS{KeyTag: tag, Algorithm: AlgorithmRSASHA256, DigestType: DigestSHA256, Digest: d}}
+}
+
+// DNSSECStatus is the outcome of validating an answer (RFC 4033 section 5).
+type DNSSECStatus int
+
+const (
+	// DNSSECSecure means a chain of signatures leads from a trust anchor.
+	DNSSECSecure DNSSECStatus = iota
+	// DNSSECInsecure means a parent zone proved the answer is not signed.
+	DNSSECInsecure
+	// DNSSECBogus means signatures were expected but failed to verify.
+	DNSSECBogus
+)
+
+func (s DNSSECStatus) String() string {
+	switch s {
+	case DNSSECSecure:
+		return "secure"
+	case DNSSECInsecure:
+		return "insecure"
+	}
+	return "bogus"
+}
+
+// ZoneTrust is one link in a chain of trust: a zone, the DS records that
+// vouch for its keys and the outcome of checking them.
+type ZoneTrust struct {
+	Zone   string
+	DS     []RR
+	Keys   []RR
+	Status DNSSECStatus
+	Reason string
+}
+
+// DNSSECResult is the validated answer to one query.
+type DNSSECResult struct {
+	Name  string
+	Type  Type
+	RCode RCode
+	// Answer holds the answer section, including the RRSIG records.
+	Answer []RR
+	// Chain lists the zones between the root and the answer, top down.
+	Chain  []ZoneTrust
+	Status DNSSECStatus
+	Reason string
+}
+
+// Validator checks answers against the DNSSEC chain of trust, fetching the
+// DNSKEY and DS records of each zone from the root down.
+type Validator struct {
+	// Querier sends the queries. A *DNSClient should have DNSSEC set so that
+	// signatures are returned.
+	Querier Querier
+	// TrustAnchors are DS records for the root, RootTrustAnchors when nil.
+	TrustAnchors []RR
+	// Now returns the time signatures are checked at, time.Now when nil.
+	Now func() time.Time
+}
+
+// validation holds the zones established while validating one answer.
+type validation struct {
+	v      *Validator
+	ctx    context.Context
+	result *DNSSECResult
+	// cuts maps a name to the zone it starts, or nil for names that are
+	// not zone cuts.
+	cuts map[string]*ZoneTrust
+}
+
+// Validate queries name for qtype and validates every RRset in the answer,
+// or the signed denial of existence for negative answers.
+func (v *Validator) Validate(ctx context.Context, name string, qtype Type) *DNSSECResult {
+	name = Fqdn(name)
+	result := &DNSSECResult{Name: name, Type: qtype}
+	val := &validation{v: v, ctx: ctx, result: result, cuts: make(map[string]*ZoneTrust)}
+
+	resp, err := v.Querier.Query(ctx, name, qtype)
+	if err != nil {
+		result.Status, result.Reason = DNSSECBogus, fmt.Sprintf("query failed: %v", err)
+		return result
+	}
+	result.RCode, result.Answer = resp.RCode, resp.Answer
+	if resp.RCode != RCodeSuccess && resp.RCode != RCodeNameError {
+		result.Status, result.Reason = DNSSECBogus, fmt.Sprintf("server returned %s", resp.RCode)
+		return result
+	}
+
+	rrsets, sigs := splitRRsets(resp.Answer)
+	if len(rrsets) == 0 {
+		zone := val.zoneFor(name)
+		result.Status, result.Reason = zone.Status, zone.Reason
+		if zone.Status == DNSSECSecure {
+			result.Status, result.Reason = val.verifyDenial(resp, zone)
+		}
+		return result
+	}
+
+	result.Status, result.Reason = DNSSECSecure, fmt.Sprintf("%d RRsets verified", len(rrsets))
+	for _, rrset := range rrsets {
+		owner := rrset[0].Name
+		zone := val.zoneFor(owner)
+		status, reason := zone.Status, zone.Reason
+		if status == DNSSECSecure {
+			sig, err := val.verifySignature(rrset, sigs, zone)
+			switch {
+			case err != nil:
+				status, reason = DNSSECBogus, fmt.Sprintf("%s %s: %v", owner, rrset[0].Type, err)
+			case int(sig.Labels) < len(nameLabels(strings.TrimPrefix(owner, "*."))):
+				status, reason = val.verifyWildcard(resp, owner, sig, zone)
+			}
+		}
+		if status > result.Status {
+			result.Status, result.Reason = status, reason
+		}
+	}
+	return result
+}
+
+// zoneFor walks from the root towards name and returns the deepest zone
+// containing it, or the first link of the chain that is not secure.
+func (val *validation) zoneFor(name string) *ZoneTrust {
+	zone := val.root()
+	labels := nameLabels(name)
+	for i := len(labels) - 1; i >= 0 && zone.Status == DNSSECSecure; i-- {
+		cand := strings.Join(labels[i:], ".") + "."
+		cut, ok := val.cuts[cand]
+		if !ok {
+			cut = val.delegation(cand, zone)
+			val.cuts[cand] = cut
+			if cut != nil && cut != noSuchName {
+				val.result.Chain = append(val.result.Chain, *cut)
+			}
+		}
+		if cut == noSuchName {
+			break
+		}
+		if cut != nil {
+			zone = cut
+		}
+	}
+	return zone
+}
+
+// noSuchName marks a name that does not exist, ending the walk.
+var noSuchName = &ZoneTrust{}
+
+// root establishes the root zone's keys from the trust anchors.
+func (val *validation) root() *ZoneTrust {
+	if zone, ok := val.cuts[rootZone]; ok {
+		return zone
+	}
+	anchors := val.v.TrustAnchors
+	if anchors == nil {
+		anchors = RootTrustAnchors
+	}
+	zone := val.zoneKeys(rootZone, anchors)
+	if zone.Status == DNSSECSecure {
+		zone.Reason = "DNSKEY matches the trust anchor"
+	}
+	val.cuts[rootZone] = zone
+	val.result.Chain = append(val.result.Chain, *zone)
+	return zone
+}
+
+// delegation queries the DS records of cand in parent. It returns the
+// child zone when cand is a signed or unsigned delegation, nil when cand is
+// not a zone cut and noSuchName when cand does not exist.
+func (val *validation) delegation(cand string, parent *ZoneTrust) *ZoneTrust {
+	resp, err := val.v.Querier.Query(val.ctx, cand, TypeDS)
+	if err != nil {
+		return &ZoneTrust{Zone: cand, Status: DNSSECBogus, Reason: fmt.Sprintf("DS query failed: %v", err)}
+	}
+	if aliased(resp.Answer, cand) {
+		// The answer and its authority section come from the alias
+		// target's zone. An alias is never a zone cut, and the CNAME
+		// itself is validated with the answer.
+		return nil
+	}
+	switch resp.RCode {
+	case RCodeSuccess:
+	case RCodeNameError:
+		return noSuchName
+	default:
+		return &ZoneTrust{Zone: cand, Status: DNSSECBogus, Reason: fmt.Sprintf("DS query returned %s", resp.RCode)}
+	}
+
+	rrsets, sigs := splitRRsets(resp.Answer)
+	for _, rrset := range rrsets {
+		if rrset[0].Type != TypeDS || !strings.EqualFold(rrset[0].Name, cand) {
+			continue
+		}
+		if err := val.verifyRRset(rrset, sigs, parent); err != nil {
+			return &ZoneTrust{Zone: cand, DS: rrset, Status: DNSSECBogus, Reason: fmt.Sprintf("DS RRset: %v", err)}
+		}
+		return val.zoneKeys(cand, rrset)
+	}
+
+	insecure, err := val.provesNoDS(resp, cand, parent)
+	switch {
+	case err != nil:
+		return &ZoneTrust{Zone: cand, Status: DNSSECBogus, Reason: err.Error()}
+	case insecure:
+		return &ZoneTrust{Zone: cand, Status: DNSSECInsecure, Reason: fmt.Sprintf("%s proves the delegation has no DS records", parent.Zone)}
+	}
+	return nil
+}
+
+// aliased reports whether answer holds a CNAME owned by name or a DNAME
+// owned by one of its ancestors.
+func aliased(answer []RR, name string) bool {
+	for _, rr := range answer {
+		switch {
+		case rr.Type == TypeCNAME && strings.EqualFold(rr.Name, name):
+			return true
+		case rr.Type == typeDNAME && isSubdomain(name, rr.Name) && !strings.EqualFold(rr.Name, name):
+			return true
+		}
+	}
+	return false
+}
+
+// zoneKeys fetches the DNSKEY RRset of zone and checks it against ds: a
+// key must match a DS record and sign the RRset.
+func (val *validation) zoneKeys(zone string, ds []RR) *ZoneTrust {
+	trust := &ZoneTrust{Zone: zone, DS: ds, Status: DNSSECBogus}
+	resp, err := val.v.Querier.Query(val.ctx, zone, TypeDNSKEY)
+	if err != nil {
+		trust.Reason = fmt.Sprintf("DNSKEY query failed: %v", err)
+		return trust
+	}
+	rrsets, sigs := splitRRsets(resp.Answer)
+	for _, rrset := range rrsets {
+		if rrset[0].Type == TypeDNSKEY && strings.EqualFold(rrset[0].Name, zone) {
+			trust.Keys = rrset
+		}
+	}
+	if len(trust.Keys) == 0 {
+		trust.Reason = fmt.Sprintf("no DNSKEY records (%s)", resp.RCode)
+		return trust
+	}
+
+	var anchors []*DNSKEY
+	supported := false
+	trust.Reason = "no DNSKEY matches the DS records"
+	for _, rr := range ds {
+		d := rr.Data.(*DS)
+		if !algorithmSupported(d.Algorithm) || digestHash(d.DigestType) == nil {
+			continue
+		}
+		supported = true
+		for _, krr := range trust.Keys {
+			key := krr.Data.(*DNSKEY)
+			if key.KeyTag() != d.KeyTag || key.Algorithm != d.Algorithm {
+				continue
+			}
+			digest, err := key.ToDS(zone, d.DigestType)
+			if err == nil && bytes.Equal(digest.Digest, d.Digest) {
+				anchors = append(anchors, key)
+			} else {
+				trust.Reason = fmt.Sprintf("DS digest mismatch for key tag %d", d.KeyTag)
+			}
+		}
+	}
+	if !supported {
+		trust.Status, trust.Reason = DNSSECInsecure, "no DS record uses a supported algorithm"
+		return trust
+	}
+	if len(anchors) == 0 {
+		return trust
+	}
+
+	signers := &ZoneTrust{Zone: zone}
+	for _, key := range anchors {
+		signers.Keys = append(signers.Keys, RR{Name: zone, Type: TypeDNSKEY, Class: ClassINET, Data: key})
+	}
+	if err := val.verifyRRset(trust.Keys, sigs, signers); err != nil {
+		trust.Reason = fmt.Sprintf("DNSKEY RRset: %v", err)
+		return trust
+	}
+	tags := make([]string, len(anchors))
+	for i, key := range anchors {
+		tags[i] = fmt.Sprint(key.KeyTag())
+	}
+	trust.Status, trust.Reason = DNSSECSecure, fmt.Sprintf("DNSKEY %s matches DS and signs the key set", strings.Join(tags, ", "))
+	return trust
+}
+
+// provesNoDS checks the signed NSEC or NSEC3 records of a DS query without
+// an answer. It reports whether cand is a delegation without DS records;
+// false means cand exists but is not a zone cut.
+func (val *validation) provesNoDS(resp *Response, cand string, parent *ZoneTrust) (bool, error) {
+	rrsets, sigs := splitRRsets(resp.Authority)
+	var nsecs, nsec3s []RR
+	for _, rrset := range rrsets {
+		if rrset[0].Type != TypeNSEC && rrset[0].Type != TypeNSEC3 {
+			continue
+		}
+		if err := val.verifyRRset(rrset, sigs, parent); err != nil {
+			return false, fmt.Errorf("no DS proof for %s: %s: %v", cand, rrset[0].Type, err)
+		}
+		if rrset[0].Type == TypeNSEC {
+			nsecs = append(nsecs, rrset...)
+		} else {
+			nsec3s = append(nsec3s, rrset...)
+		}
+	}
+
+	for _, rr := range nsecs {
+		nsec := rr.Data.(*NSEC)
+		if strings.EqualFold(rr.Name, cand) {
+			return delegationWithoutDS(nsec.Types, cand)
+		}
+		if nsecCovers(rr.Name, nsec.NextDomain, cand) {
+			// An empty non-terminal: the name exists only as a parent.
+			return false, nil
+		}
+	}
+	for _, rr := range nsec3s {
+		nsec3 := rr.Data.(*NSEC3)
+		hashed, err := nsec3Hash(cand, nsec3)
+		if err != nil {
+			return false, err
+		}
+		owner, _, _ := strings.Cut(rr.Name, ".")
+		ownerHash, err := base32Hex.DecodeString(strings.ToUpper(owner))
+		if err != nil {
+			continue
+		}
+		if bytes.Equal(ownerHash, hashed) {
+			return delegationWithoutDS(nsec3.Types, cand)
+		}
+		if hashCovers(ownerHash, nsec3.NextHashed, hashed) {
+			// Opt-out spans may hold unsigned delegations (RFC 5155 6).
+			return nsec3.Flags&nsec3OptOut != 0, nil
+		}
+	}
+	return false, fmt.Errorf("no signed denial of existence for the DS records of %s", cand)
+}
+
+// delegationWithoutDS interprets the type bitmap of a name's NSEC record.
+func delegationWithoutDS(types []Type, cand string) (bool, error) {
+	if hasType(types, TypeDS) {
+		return false, fmt.Errorf("NSEC for %s lists DS records the server did not return", cand)
+	}
+	return hasType(types, TypeNS) && !hasType(types, TypeSOA), nil
+}
+
+// verifyDenial checks that the signed NSEC or NSEC3 records of a negative
+// answer prove it (RFC 4035 section 5.4, RFC 5155 section 8): for NXDOMAIN
+// that neither the name nor a wildcard that could expand to it exists, and
+// for NODATA that the name, or the wildcard matching it, lacks the type.
+func (val *validation) verifyDenial(resp *Response, zone *ZoneTrust) (DNSSECStatus, string) {
+	nsecs, nsec3s, err := val.authorityNSECs(resp, zone)
+	if err != nil {
+		return DNSSECBogus, err.Error()
+	}
+
+	name, qtype := val.result.Name, val.result.Type
+	nxdomain := resp.RCode == RCodeNameError
+	var optOut bool
+	switch {
+	case len(nsecs) > 0:
+		err = nsecDenial(nsecs, name, qtype, nxdomain)
+	case len(nsec3s) > 0:
+		optOut, err = nsec3Denial(nsec3s, name, qtype, nxdomain)
+	default:
+		err = errors.New("negative answer carries no signed NSEC or NSEC3 records")
+	}
+	switch {
+	case err != nil:
+		return DNSSECBogus, err.Error()
+	case optOut:
+		return DNSSECInsecure, fmt.Sprintf("%s covers %s with an opt-out NSEC3 span", zone.Zone, name)
+	}
+	return DNSSECSecure, fmt.Sprintf("denial of existence proved by %s", zone.Zone)
+}
+
+// verifyWildcard checks an answer synthesised from a wildcard, whose
+// RRSIG has fewer labels than owner: signed NSEC or NSEC3 records must show
+// that the next closer name, and so owner itself, does not exist (RFC 4035
+// section 5.3.4, RFC 5155 section 8.8). Without that proof a signed
+// wildcard answer could be replayed for any name.
+func (val *validation) verifyWildcard(resp *Response, owner string, sig *RRSIG, zone *ZoneTrust) (DNSSECStatus, string) {
+	nsecs, nsec3s, err := val.authorityNSECs(resp, zone)
+	if err != nil {
+		return DNSSECBogus, err.Error()
+	}
+	labels := nameLabels(owner)
+	nextCloser := joinLabels(labels[len(labels)-int(sig.Labels)-1:])
+	wildcard := wildcardOf(joinLabels(labels[len(labels)-int(sig.Labels):]))
+
+	for _, rr := range nsecs {
+		next := rr.Data.(*NSEC).NextDomain
+		if nsecCovers(rr.Name, next, nextCloser) && !isSubdomain(next, nextCloser) {
+			return DNSSECSecure, fmt.Sprintf("%s expanded from %s, proved by %s", owner, wildcard, zone.Zone)
+		}
+	}
+	if nsec3 := nsec3Find(nsec3s, nextCloser, true); nsec3 != nil {
+		if nsec3.Flags&nsec3OptOut != 0 {
+			return DNSSECInsecure, fmt.Sprintf("%s covers %s with an opt-out NSEC3 span", zone.Zone, nextCloser)
+		}
+		return DNSSECSecure, fmt.Sprintf("%s expanded from %s, proved by %s", owner, wildcard, zone.Zone)
+	}
+	return DNSSECBogus, fmt.Sprintf("%s is expanded from %s without proof that %s does not exist", owner, wildcard, nextCloser)
+}
+
+// authorityNSECs verifies the RRsets of the authority section against
+// zone and returns its NSEC and NSEC3 records.
+func (val *validation) authorityNSECs(resp *Response, zone *ZoneTrust) (nsecs, nsec3s []RR, err error) {
+	rrsets, sigs := splitRRsets(resp.Authority)
+	for _, rrset := range rrsets {
+		if err := val.verifyRRset(rrset, sigs, zone); err != nil {
+			return nil, nil, fmt.Errorf("%s %s: %v", rrset[0].Name, rrset[0].Type, err)
+		}
+		switch rrset[0].Type {
+		case TypeNSEC:
+			nsecs = append(nsecs, rrset...)
+		case TypeNSEC3:
+			nsec3s = append(nsec3s, rrset...)
+		}
+	}
+	return nsecs, nsec3s, nil
+}
+
+// nsecDenial checks that NSEC records prove the negative answer for name.
+func nsecDenial(nsecs []RR, name string, qtype Type, nxdomain bool) error {
+	for _, rr := range nsecs {
+		if !strings.EqualFold(rr.Name, name) {
+			continue
+		}
+		if nxdomain {
+			return fmt.Errorf("NSEC shows %s exists", name)
+		}
+		return bitmapDenies(rr.Data.(*NSEC).Types, name, qtype)
+	}
+
+	var owner, next string
+	for _, rr := range nsecs {
+		if nsec := rr.Data.(*NSEC); nsecCovers(rr.Name, nsec.NextDomain, name) {
+			owner, next = rr.Name, nsec.NextDomain
+			break
+		}
+	}
+	if owner == "" {
+		return fmt.Errorf("no NSEC record matches or covers %s", name)
+	}
+	if isSubdomain(next, name) {
+		// An empty non-terminal: the name exists only as a parent of next.
+		if nxdomain {
+			return fmt.Errorf("NSEC shows %s exists as a parent of %s", name, next)
+		}
+		return nil
+	}
+
+	closest := commonAncestor(name, owner)
+	if c := commonAncestor(name, next); len(c) > len(closest) {
+		closest = c
+	}
+	wildcard := wildcardOf(closest)
+	for _, rr := range nsecs {
+		nsec := rr.Data.(*NSEC)
+		if strings.EqualFold(rr.Name, wildcard) {
+			if nxdomain {
+				return fmt.Errorf("NSEC shows the wildcard %s exists", wildcard)
+			}
+			return bitmapDenies(nsec.Types, wildcard, qtype)
+		}
+		if nxdomain && nsecCovers(rr.Name, nsec.NextDomain, wildcard) {
+			return nil
+		}
+	}
+	if nxdomain {
+		return fmt.Errorf("no NSEC record proves the wildcard %s does not exist", wildcard)
+	}
+	return fmt.Errorf("no NSEC record matches %s or the wildcard %s", name, wildcard)
+}
+
+// nsec3Denial checks that NSEC3 records prove the negative answer for name.
+// It reports whether the proof rests on an opt-out span, which may hold
+// unsigned delegations.
+func nsec3Denial(nsec3s []RR, name string, qtype Type, nxdomain bool) (bool, error) {
+	if nsec3 := nsec3Find(nsec3s, name, false); nsec3 != nil {
+		if nxdomain {
+			return false, fmt.Errorf("NSEC3 shows %s exists", name)
+		}
+		return false, bitmapDenies(nsec3.Types, name, qtype)
+	}
+
+	closest, nextCloser, err := nsec3ClosestEncloser(nsec3s, name)
+	if err != nil {
+		return false, err
+	}
+	optOut := nextCloser.Flags&nsec3OptOut != 0
+	wildcard := wildcardOf(closest)
+	if nsec3 := nsec3Find(nsec3s, wildcard, false); nsec3 != nil {
+		if nxdomain {
+			return false, fmt.Errorf("NSEC3 shows the wildcard %s exists", wildcard)
+		}
+		return false, bitmapDenies(nsec3.Types, wildcard, qtype)
+	}
+	switch {
+	case nxdomain && nsec3Find(nsec3s, wildcard, true) == nil:
+		return false, fmt.Errorf("no NSEC3 record proves the wildcard %s does not exist", wildcard)
+	case !nxdomain && !(qtype == TypeDS && optOut):
+		// Only a DS query may be answered from an opt-out span (RFC 5155
+		// section 8.6).
+		return false, fmt.Errorf("no NSEC3 record matches %s or the wildcard %s", name, wildcard)
+	}
+	return optOut, nil
+}
+
+// nsec3ClosestEncloser finds the closest encloser proof of RFC 5155 section
+// 8.3: an NSEC3 record matching the deepest existing ancestor of name and
+// one covering the next closer name below it.
+func nsec3ClosestEncloser(nsec3s []RR, name string) (string, *NSEC3, error) {
+	labels := nameLabels(name)
+	for i := 1; i <= len(labels); i++ {
+		closest := joinLabels(labels[i:])
+		if nsec3Find(nsec3s, closest, false) == nil {
+			continue
+		}
+		nextCloser := joinLabels(labels[i-1:])
+		nsec3 := nsec3Find(nsec3s, nextCloser, true)
+		if nsec3 == nil {
+			return "", nil, fmt.Errorf("no NSEC3 record covers the next closer name %s", nextCloser)
+		}
+		return closest, nsec3, nil
+	}
+	return "", nil, fmt.Errorf("no NSEC3 record matches an ancestor of %s", name)
+}
+
+// nsec3Find returns the NSEC3 record whose owner is the hash of name, or
+// with cover set, whose span covers it.
+func nsec3Find(nsec3s []RR, name string, cover bool) *NSEC3 {
+	for _, rr := range nsec3s {
+		nsec3 := rr.Data.(*NSEC3)
+		hashed, err := nsec3Hash(name, nsec3)
+		if err != nil {
+			continue
+		}
+		owner, _, _ := strings.Cut(rr.Name, ".")
+		ownerHash, err := base32Hex.DecodeString(strings.ToUpper(owner))
+		if err != nil {
+			continue
+		}
+		if cover && hashCovers(ownerHash, nsec3.NextHashed, hashed) || !cover && bytes.Equal(ownerHash, hashed) {
+			return nsec3
+		}
+	}
+	return nil
+}
+
+// bitmapDenies checks that the type bitmap of owner proves it has no
+// records of qtype: neither qtype nor a CNAME is listed, and owner is not a
+// delegation, whose NSEC can only answer for DS.
+func bitmapDenies(types []Type, owner string, qtype Type) error {
+	for _, t := range []Type{qtype, TypeCNAME} {
+		if hasType(types, t) {
+			return fmt.Errorf("NSEC for %s lists %s records", owner, t)
+		}
+	}
+	if qtype != TypeDS && hasType(types, TypeNS) && !hasType(types, TypeSOA) {
+		return fmt.Errorf("NSEC for %s is from the parent side of a delegation", owner)
+	}
+	return nil
+}
+
+func hasType(types []Type, t Type) bool {
+	for _, typ := range types {
+		if typ == t {
+			return true
+		}
+	}
+	return false
+}
+
+// commonAncestor returns the deepest name that both a and b fall under.
+func commonAncestor(a, b string) string {
+	la, lb := nameLabels(a), nameLabels(b)
+	n := 0
+	for n < len(la) && n < len(lb) && la[len(la)-1-n] == lb[len(lb)-1-n] {
+		n++
+	}
+	return joinLabels(la[len(la)-n:])
+}
+
+// joinLabels is the inverse of nameLabels.
+func joinLabels(labels []string) string {
+	if len(labels) == 0 {
+		return rootZone
+	}
+	return strings.Join(labels, ".") + "."
+}
+
+// wildcardOf returns the wildcard name directly below name.
+func wildcardOf(name string) string {
+	if name == rootZone {
+		return "*."
+	}
+	return "*." + name
+}
+
+// verifyRRset succeeds when one RRSIG in sigs made by zone verifies rrset.
+func (val *validation) verifyRRset(rrset, sigs []RR, zone *ZoneTrust) error {
+	_, err := val.verifySignature(rrset, sigs, zone)
+	return err
+}
+
+// verifySignature returns the first RRSIG in sigs made by zone that
+// verifies rrset.
+func (val *validation) verifySignature(rrset, sigs []RR, zone *ZoneTrust) (*RRSIG, error) {
+	now := time.Now()
+	if val.v.Now != nil {
+		now = val.v.Now()
+	}
+	err := fmt.Errorf("no RRSIG by %s", zone.Zone)
+	for _, rr := range sigs {
+		sig := rr.Data.(*RRSIG)
+		if sig.TypeCovered != rrset[0].Type || !strings.EqualFold(rr.Name, rrset[0].Name) || !strings.EqualFold(sig.SignerName, zone.Zone) {
+			continue
+		}
+		err = fmt.Errorf("no DNSKEY with key tag %d", sig.KeyTag)
+		for _, krr := range zone.Keys {
+			key := krr.Data.(*DNSKEY)
+			if key.Flags&dnskeyZoneFlag == 0 || key.Algorithm != sig.Algorithm || key.KeyTag() != sig.KeyTag {
+				continue
+			}
+			if err = VerifyRRSIG(rrset, sig, key, now); err == nil {
+				return sig, nil
+			}
+		}
+	}
+	return nil, err
+}
+
+// splitRRsets groups records by owner and type, keeping RRSIGs apart.
+func splitRRsets(rrs []RR) (rrsets [][]RR, sigs []RR) {
+	index := make(map[string]int)
+	for _, rr := range rrs {
+		switch rr.Type {
+		case TypeRRSIG:
+			sigs = append(sigs, rr)
+			continue
+		case TypeOPT:
+			continue
+		}
+		key := strings.ToLower(rr.Name) + "/" + rr.Type.String()
+		if i, ok := index[key]; ok {
+			rrsets[i] = append(rrsets[i], rr)
+			continue
+		}
+		index[key] = len(rrsets)
+		rrsets = append(rrsets, []RR{rr})
+	}
+	return rrsets, sigs
+}
+
+// KeyTag computes the key tag of RFC 4034 appendix B.
+func (r *DNSKEY) KeyTag() uint16 {
+	wire, _ := r.pack(nil)
+	var ac uint32
+	for i, b := range wire {
+		if i&1 == 0 {
+			ac += uint32(b) << 8
+		} else {
+			ac += uint32(b)
+		}
+	}
+	ac += ac >> 16 & 0xFFFF
+	return uint16(ac)
+}
+
+// ToDS computes the DS record for the key owned by owner.
+func (r *DNSKEY) ToDS(owner string, digestType uint8) (*DS, error) {
+	h := digestHash(digestType)
+	if h == nil {
+		return nil, fmt.Errorf("unsupported DS digest type %d", digestType)
+	}
+	name, err := canonicalName(owner)
+	if err != nil {
+		return nil, err
+	}
+	d := h()
+	d.Write(name)
+	wire, _ := r.pack(nil)
+	d.Write(wire)
+	return &DS{KeyTag: r.KeyTag(), Algorithm: r.Algorithm, DigestType: digestType, Digest: d.Sum(nil)}, nil
+}
+
+func digestHash(digestType uint8) func() hash.Hash {
+	switch digestType {
+	case DigestSHA1:
+		return sha1.New
+	case DigestSHA256:
+		return sha256.New
+	case DigestSHA384:
+		return sha512.New384
+	}
+	return nil
+}
+
+func algorithmSupported(alg uint8) bool {
+	switch alg {
+	case AlgorithmRSASHA1, AlgorithmRSASHA1NSEC3SHA1, AlgorithmRSASHA256, AlgorithmRSASHA512,
+		AlgorithmECDSAP256SHA256, AlgorithmECDSAP384SHA384, AlgorithmED25519:
+		return true
+	}
+	return false
+}
+
+// VerifyRRSIG checks that sig, made with key, signs rrset and is valid at
+// now.
+func VerifyRRSIG(rrset []RR, sig *RRSIG, key *DNSKEY, now time.Time) error {
+	t := uint32(now.Unix())
+	if serialCompare(t, sig.Expiration) > 0 {
+		return fmt.Errorf("signature by key %d expired at %s", sig.KeyTag, time.Unix(int64(sig.Expiration), 0).UTC().Format(time.RFC3339))
+	}
+	if serialCompare(t, sig.Inception) < 0 {
+		return fmt.Errorf("signature by key %d not valid before %s", sig.KeyTag, time.Unix(int64(sig.Inception), 0).UTC().Format(time.RFC3339))
+	}
+	data, err := signedData(rrset, sig)
+	if err != nil {
+		return err
+	}
+	if err := verifySignature(key, sig.Algorithm, data, sig.Signature); err != nil {
+		return fmt.Errorf("signature by key %d: %v", sig.KeyTag, err)
+	}
+	return nil
+}
+
+// signedData builds the data an RRSIG signs (RFC 4034 section 3.1.8.1):
+// the RRSIG fields and the RRset in canonical form and order.
+func signedData(rrset []RR, sig *RRSIG) ([]byte, error) {
+	header := *sig
+	header.SignerName = strings.ToLower(sig.SignerName)
+	data, err := header.packSigned(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	labels := nameLabels(rrset[0].Name)
+	if int(sig.Labels) > len(labels) {
+		return nil, errors.New("RRSIG label count exceeds the owner name")
+	}
+	owner := strings.Join(labels[len(labels)-int(sig.Labels):], ".")
+	if int(sig.Labels) < len(labels) {
+		// Synthesised from a wildcard (RFC 4035 section 5.3.2).
+		owner = strings.TrimSuffix("*."+owner, ".")
+	}
+	owner += "."
+	ownerWire, err := canonicalName(owner)
+	if err != nil {
+		return nil, err
+	}
+
+	var rdatas [][]byte
+	for _, rr := range rrset {
+		rdata, err := canonicalRData(rr.Data).pack(nil)
+		if err != nil {
+			return nil, err
+		}
+		rdatas = append(rdatas, rdata)
+	}
+	sort.Slice(rdatas, func(i, j int) bool { return bytes.Compare(rdatas[i], rdatas[j]) < 0 })
+	for i, rdata := range rdatas {
+		if i > 0 && bytes.Equal(rdata, rdatas[i-1]) {
+			continue
+		}
+		data = append(data, ownerWire...)
+		data = binary.BigEndian.AppendUint16(data, uint16(rrset[0].Type))
+		data = binary.BigEndian.AppendUint16(data, uint16(rrset[0].Class))
+		data = binary.BigEndian.AppendUint32(data, sig.OrigTTL)
+		data = binary.BigEndian.AppendUint16(data, uint16(len(rdata)))
+		data = append(data, rdata...)
+	}
+	return data, nil
+}
+
+// canonicalName packs name in lowercase wire format. Label length octets
+// are at most 63, so lowercasing the whole encoding is safe.
+func canonicalName(name string) ([]byte, error) {
+	b, err := packName(nil, name)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.ToLower(b), nil
+}
+
+// canonicalRData lowercases the domain names embedded in the record types
+// listed in RFC 4034 section 6.2, as amended by RFC 6840 section 5.1.
+func canonicalRData(rd RData) RData {
+	lower := strings.ToLower
+	switch r := rd.(type) {
+	case *NS:
+		return &NS{Host: lower(r.Host)}
+	case *CNAME:
+		return &CNAME{Target: lower(r.Target)}
+	case *PTR:
+		return &PTR{Host: lower(r.Host)}
+	case *MX:
+		return &MX{Pref: r.Pref, Host: lower(r.Host)}
+	case *SRV:
+		return &SRV{Priority: r.Priority, Weight: r.Weight, Port: r.Port, Target: lower(r.Target)}
+	case *SOA:
+		soa := *r
+		soa.MName, soa.RName = lower(r.MName), lower(r.RName)
+		return &soa
+	case *RRSIG:
+		sig := *r
+		sig.SignerName = lower(r.SignerName)
+		return &sig
+	}
+	return rd
+}
+
+// verifySignature checks sig over data with the public key in key.
+func verifySignature(key *DNSKEY, alg uint8, data, sig []byte) error {
+	switch alg {
+	case AlgorithmRSASHA1, AlgorithmRSASHA1NSEC3SHA1, AlgorithmRSASHA256, AlgorithmRSASHA512:
+		pub, err := rsaPublicKey(key.PublicKey)
+		if err != nil {
+			return err
+		}
+		h := crypto.SHA256
+		switch alg {
+		case AlgorithmRSASHA1, AlgorithmRSASHA1NSEC3SHA1:
+			h = crypto.SHA1
+		case AlgorithmRSASHA512:
+			h = crypto.SHA512
+		}
+		d := h.New()
+		d.Write(data)
+		return rsa.VerifyPKCS1v15(pub, h, d.Sum(nil), sig)
+	case AlgorithmECDSAP256SHA256, AlgorithmECDSAP384SHA384:
+		curve, h := elliptic.P256(), crypto.SHA256
+		if alg == AlgorithmECDSAP384SHA384 {
+			curve, h = elliptic.P384(), crypto.SHA384
+		}
+		size := curve.Params().BitSize / 8
+		if len(key.PublicKey) != 2*size || len(sig) != 2*size {
+			return errors.New("malformed ECDSA key or signature")
+		}
+		pub := &ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(key.PublicKey[:size]),
+			Y:     new(big.Int).SetBytes(key.PublicKey[size:]),
+		}
+		d := h.New()
+		d.Write(data)
+		if !ecdsa.Verify(pub, d.Sum(nil), new(big.Int).SetBytes(sig[:size]), new(big.Int).SetBytes(sig[size:])) {
+			return errors.New("verification failed")
+		}
+		return nil
+	case AlgorithmED25519:
+		if len(key.PublicKey) != ed25519.PublicKeySize {
+			return errors.New("malformed Ed25519 key")
+		}
+		if !ed25519.Verify(ed25519.PublicKey(key.PublicKey), data, sig) {
+			return errors.New("verification failed")
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported algorithm %d", alg)
+}
+
+// rsaPublicKey decodes the RFC 3110 key format: the exponent length in one
+// octet, or zero and two octets, then the exponent and the modulus.
+func rsaPublicKey(b []byte) (*rsa.PublicKey, error) {
+	if len(b) < 3 {
+		return nil, errors.New("malformed RSA key")
+	}
+	n, off := int(b[0]), 1
+	if n == 0 {
+		n, off = int(binary.BigEndian.Uint16(b[1:])), 3
+	}
+	if n == 0 || n > 8 || off+n >= len(b) {
+		return nil, errors.New("malformed RSA key")
+	}
+	e := new(big.Int).SetBytes(b[off : off+n])
+	if !e.IsInt64() || e.Int64() > 1<<31-1 {
+		return nil, errors.New("RSA exponent too large")
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(b[off+n:]), E: int(e.Int64())}, nil
+}
+
+// nsec3Hash hashes name with the parameters of an NSEC3 record (RFC 5155
+// section 5).
+func nsec3Hash(name string, params *NSEC3) ([]byte, error) {
+	if params.HashAlgorithm != nsec3SHA1 {
+		return nil, fmt.Errorf("unsupported NSEC3 hash algorithm %d", params.HashAlgorithm)
+	}
+	wire, err := canonicalName(name)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha1.Sum(append(wire, params.Salt...))
+	for i := 0; i < int(params.Iterations); i++ {
+		sum = sha1.Sum(append(sum[:], params.Salt...))
+	}
+	return sum[:], nil
+}
+
+// nsecCovers reports whether name falls strictly between owner and next in
+// canonical order, allowing for the last NSEC wrapping to the apex.
+func nsecCovers(owner, next, name string) bool {
+	if compareNames(owner, next) < 0 {
+		return compareNames(owner, name) < 0 && compareNames(name, next) < 0
+	}
+	return compareNames(owner, name) < 0 || compareNames(name, next) < 0
+}
+
+// hashCovers is nsecCovers for NSEC3 hashes.
+func hashCovers(owner, next, hashed []byte) bool {
+	if bytes.Compare(owner, next) < 0 {
+		return bytes.Compare(owner, hashed) < 0 && bytes.Compare(hashed, next) < 0
+	}
+	return bytes.Compare(owner, hashed) < 0 || bytes.Compare(hashed, next) < 0
+}
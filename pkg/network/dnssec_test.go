@@ -0,0 +1,448 @@
+package network
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testSigner holds a zone's signing key.
+type testSigner struct {
+	zone string
+	key  *DNSKEY
+	priv crypto.Signer
+}
+
+func newTestSigner(t *testing.T, zone string, alg uint8) *testSigner {
+	t.Helper()
+	s := &testSigner{zone: zone, key: &DNSKEY{Flags: 257, Protocol: 3, Algorithm: alg}}
+	switch alg {
+	case AlgorithmECDSAP256SHA256:
+		priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.priv = priv
+		s.key.PublicKey = append(priv.X.FillBytes(make([]byte, 32)), priv.Y.FillBytes(make([]byte, 32))...)
+	case AlgorithmRSASHA256:
+		priv, err := rsa.GenerateKey(rand.Reader, 1024)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.priv = priv
+		e := big.NewInt(int64(priv.E)).Bytes()
+		s.key.PublicKey = append(append([]byte{byte(len(e))}, e...), priv.N.Bytes()...)
+	case AlgorithmED25519:
+		pub, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.priv = priv
+		s.key.PublicKey = pub
+	}
+	return s
+}
+
+func (s *testSigner) dnskey() RR {
+	return RR{Name: s.zone, Type: TypeDNSKEY, Class: ClassINET, TTL: 3600, Data: s.key}
+}
+
+func (s *testSigner) ds(t *testing.T) RR {
+	t.Helper()
+	ds, err := s.key.ToDS(s.zone, DigestSHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return RR{Name: s.zone, Type: TypeDS, Class: ClassINET, TTL: 3600, Data: ds}
+}
+
+// sign returns an RRSIG over rrs valid for an hour either side of now.
+func (s *testSigner) sign(t *testing.T, rrs ...RR) RR {
+	now := time.Now()
+	return s.signAt(t, now.Add(-time.Hour), now.Add(time.Hour), rrs...)
+}
+
+func (s *testSigner) signAt(t *testing.T, inception, expiration time.Time, rrs ...RR) RR {
+	t.Helper()
+	// The wildcard label is not counted (RFC 4034 section 3.1.3).
+	labels := len(nameLabels(strings.TrimPrefix(rrs[0].Name, "*.")))
+	sig := &RRSIG{
+		TypeCovered: rrs[0].Type,
+		Algorithm:   s.key.Algorithm,
+		Labels:      uint8(labels),
+		OrigTTL:     rrs[0].TTL,
+		Expiration:  uint32(expiration.Unix()),
+		Inception:   uint32(inception.Unix()),
+		KeyTag:      s.key.KeyTag(),
+		SignerName:  s.zone,
+	}
+	data, err := signedData(rrs, sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	switch priv := s.priv.(type) {
+	case *ecdsa.PrivateKey:
+		sum := sha256.Sum256(data)
+		r, ss, err := ecdsa.Sign(rand.Reader, priv, sum[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig.Signature = append(r.FillBytes(make([]byte, 32)), ss.FillBytes(make([]byte, 32))...)
+	case *rsa.PrivateKey:
+		sum := sha256.Sum256(data)
+		sig.Signature, err = rsa.SignPKCS1v15(rand.Reader, priv, crypto.SHA256, sum[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+	case ed25519.PrivateKey:
+		sig.Signature = ed25519.Sign(priv, data)
+	}
+	return RR{Name: rrs[0].Name, Type: TypeRRSIG, Class: ClassINET, TTL: rrs[0].TTL, Data: sig}
+}
+
+// signedZone answers like a validating resolver with CD set: the records
+// of the name and type with their signatures, following CNAMEs, and for
+// names without the type, the name's NSEC records.
+type signedZone []RR
+
+func (z signedZone) handle(req *Message) *Message {
+	resp := replyTo(req)
+	q := req.Question[0]
+	covers := func(rr RR, t Type) bool {
+		return rr.Type == t || rr.Type == TypeRRSIG && rr.Data.(*RRSIG).TypeCovered == t
+	}
+	name := q.Name
+	for hops := 0; hops < 8; hops++ {
+		known, found, next := false, false, ""
+		for _, rr := range z {
+			if !strings.EqualFold(rr.Name, name) {
+				continue
+			}
+			known = true
+			switch {
+			case covers(rr, q.Type):
+				resp.Answer, found = append(resp.Answer, rr), true
+			case covers(rr, TypeCNAME):
+				resp.Answer = append(resp.Answer, rr)
+				if cname, ok := rr.Data.(*CNAME); ok {
+					next = cname.Target
+				}
+			}
+		}
+		switch {
+		case !known:
+			resp.RCode = RCodeNameError
+		case !found && next == "":
+			for _, rr := range z {
+				if strings.EqualFold(rr.Name, name) && covers(rr, TypeNSEC) {
+					resp.Authority = append(resp.Authority, rr)
+				}
+			}
+		}
+		if found || next == "" {
+			break
+		}
+		name = next
+	}
+	return resp
+}
+
+func TestValidator(t *testing.T) {
+	root := newTestSigner(t, ".", AlgorithmECDSAP256SHA256)
+	com := newTestSigner(t, "com.", AlgorithmRSASHA256)
+	example := newTestSigner(t, "example.com.", AlgorithmED25519)
+	bad := newTestSigner(t, "bad.com.", AlgorithmECDSAP256SHA256)
+
+	a := func(name, ip string) RR {
+		return RR{Name: name, Type: TypeA, Class: ClassINET, TTL: 300, Data: &A{IP: net.ParseIP(ip).To4()}}
+	}
+	nsec := func(name, next string, types ...Type) RR {
+		return RR{Name: name, Type: TypeNSEC, Class: ClassINET, TTL: 300, Data: &NSEC{NextDomain: next, Types: types}}
+	}
+	badDS := bad.ds(t)
+	badDS.Data.(*DS).Digest[0] ^= 0xFF
+
+	www := a("www.example.com.", "192.0.2.1")
+	expired := a("expired.example.com.", "192.0.2.2")
+	wwwNSEC := nsec("www.example.com.", "example.com.", TypeA, TypeRRSIG, TypeNSEC)
+	expiredNSEC := nsec("expired.example.com.", "www.example.com.", TypeA, TypeRRSIG, TypeNSEC)
+	insecureNSEC := nsec("insecure.com.", "com.", TypeNS, TypeRRSIG, TypeNSEC)
+	// lie.example.com claims to have TXT records yet answers without them.
+	lieNSEC := nsec("lie.example.com.", "www.example.com.", TypeTXT, TypeRRSIG, TypeNSEC)
+	// A DS query for alias.example.com is answered with the CNAME and the
+	// denial from www.example.com, which does not cover alias.
+	alias := RR{Name: "alias.example.com.", Type: TypeCNAME, Class: ClassINET, TTL: 300, Data: &CNAME{Target: "www.example.com."}}
+	comDS, exampleDS := com.ds(t), example.ds(t)
+	zone := signedZone{
+		root.dnskey(), root.sign(t, root.dnskey()),
+		comDS, root.sign(t, comDS),
+		com.dnskey(), com.sign(t, com.dnskey()),
+		exampleDS, com.sign(t, exampleDS),
+		badDS, com.sign(t, badDS),
+		insecureNSEC, com.sign(t, insecureNSEC),
+		example.dnskey(), example.sign(t, example.dnskey()),
+		www, example.sign(t, www),
+		wwwNSEC, example.sign(t, wwwNSEC),
+		lieNSEC, example.sign(t, lieNSEC),
+		alias, example.sign(t, alias),
+		expired, example.signAt(t, time.Now().Add(-48*time.Hour), time.Now().Add(-24*time.Hour), expired),
+		expiredNSEC, example.sign(t, expiredNSEC),
+		bad.dnskey(), bad.sign(t, bad.dnskey()),
+		a("www.bad.com.", "192.0.2.3"),
+		a("www.insecure.com.", "192.0.2.4"),
+	}
+	// Answers synthesised from *.example.com carry the wildcard's RRSIG
+	// and the NSEC records the server offers as proof that the name does
+	// not exist.
+	wild := a("*.example.com.", "192.0.2.5")
+	wildSig := example.sign(t, wild)
+	expanded := func(name string) []RR {
+		rr, sig := wild, wildSig
+		rr.Name, sig.Name = name, name
+		return []RR{rr, sig}
+	}
+	wildcardProofs := map[string][]RR{
+		"host.example.com.": {expiredNSEC, example.sign(t, expiredNSEC)},
+		"zzz.example.com.":  {expiredNSEC, example.sign(t, expiredNSEC)},
+	}
+	addr := startTestDNSServer(t, func(req *Message) *Message {
+		assert.True(t, req.DNSSECOK())
+		q := req.Question[0]
+		if proof, ok := wildcardProofs[q.Name]; ok && q.Type == TypeA {
+			resp := replyTo(req)
+			resp.Answer, resp.Authority = expanded(q.Name), proof
+			return resp
+		}
+		return zone.handle(req)
+	})
+
+	client := NewDNSClient(addr)
+	client.DNSSEC = true
+	validator := &Validator{Querier: client, TrustAnchors: []RR{root.ds(t)}}
+
+	tests := []struct {
+		name   string
+		qtype  Type
+		status DNSSECStatus
+		reason string
+		chain  []string
+	}{
+		{"www.example.com", TypeA, DNSSECSecure, "", []string{".", "com.", "example.com."}},
+		{"www.example.com", TypeTXT, DNSSECSecure, "denial of existence", []string{".", "com.", "example.com."}},
+		{"alias.example.com", TypeA, DNSSECSecure, "2 RRsets verified", []string{".", "com.", "example.com."}},
+		{"lie.example.com", TypeTXT, DNSSECBogus, "lists TXT", []string{".", "com.", "example.com."}},
+		{"host.example.com", TypeA, DNSSECSecure, "1 RRsets verified", []string{".", "com.", "example.com."}},
+		{"zzz.example.com", TypeA, DNSSECBogus, "without proof that zzz.example.com. does not exist", []string{".", "com.", "example.com."}},
+		{"expired.example.com", TypeA, DNSSECBogus, "expired", []string{".", "com.", "example.com."}},
+		{"www.insecure.com", TypeA, DNSSECInsecure, "no DS records", []string{".", "com.", "insecure.com."}},
+		{"www.bad.com", TypeA, DNSSECBogus, "DS digest mismatch", []string{".", "com.", "bad.com."}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name+"/"+tt.qtype.String(), func(t *testing.T) {
+			result := validator.Validate(context.Background(), tt.name, tt.qtype)
+			assert.Equal(t, tt.status, result.Status, result.Reason)
+			assert.Contains(t, result.Reason, tt.reason)
+			var chain []string
+			for _, z := range result.Chain {
+				chain = append(chain, z.Zone)
+			}
+			assert.Equal(t, tt.chain, chain)
+		})
+	}
+
+	untrusted := &Validator{Querier: client, TrustAnchors: []RR{com.ds(t)}}
+	result := untrusted.Validate(context.Background(), "www.example.com", TypeA)
+	assert.Equal(t, DNSSECBogus, result.Status)
+}
+
+func TestNSECDenial(t *testing.T) {
+	nsec := func(name, next string, types ...Type) RR {
+		return RR{Name: name, Type: TypeNSEC, Class: ClassINET, Data: &NSEC{NextDomain: next, Types: types}}
+	}
+	apex := nsec("example.com.", "a.example.com.", TypeSOA, TypeNS, TypeRRSIG, TypeNSEC)
+	a := nsec("a.example.com.", "m.example.com.", TypeA, TypeRRSIG, TypeNSEC)
+	m := nsec("m.example.com.", "example.com.", TypeMX, TypeRRSIG, TypeNSEC)
+	// With a wildcard holding TXT records between the apex and a.
+	wildApex := nsec("example.com.", "*.example.com.", TypeSOA, TypeNS, TypeRRSIG, TypeNSEC)
+	wild := nsec("*.example.com.", "a.example.com.", TypeTXT, TypeRRSIG, TypeNSEC)
+
+	tests := []struct {
+		name     string
+		qname    string
+		qtype    Type
+		nxdomain bool
+		nsecs    []RR
+		err      string
+	}{
+		{"nodata", "a.example.com.", TypeTXT, false, []RR{a}, ""},
+		{"nodata type in bitmap", "a.example.com.", TypeA, false, []RR{a}, "lists A"},
+		{"nodata cname in bitmap", "c.example.com.", TypeA, false, []RR{nsec("c.example.com.", "m.example.com.", TypeCNAME)}, "lists CNAME"},
+		{"nodata at delegation", "d.example.com.", TypeA, false, []RR{nsec("d.example.com.", "m.example.com.", TypeNS)}, "parent side"},
+		{"nodata empty non-terminal", "c.example.com.", TypeA, false, []RR{nsec("a.example.com.", "b.c.example.com.", TypeA)}, ""},
+		{"nodata wildcard", "b.example.com.", TypeMX, false, []RR{a, wild}, ""},
+		{"nodata wildcard has type", "b.example.com.", TypeTXT, false, []RR{a, wild}, "lists TXT"},
+		{"nodata no match", "b.example.com.", TypeMX, false, []RR{a}, "no NSEC record matches"},
+		{"nxdomain", "b.example.com.", TypeA, true, []RR{apex, a}, ""},
+		{"nxdomain wrapping span", "z.example.com.", TypeA, true, []RR{apex, m}, ""},
+		{"nxdomain not covered", "z.example.com.", TypeA, true, []RR{apex, a}, "no NSEC record matches or covers"},
+		{"nxdomain name exists", "a.example.com.", TypeA, true, []RR{a}, "shows a.example.com. exists"},
+		{"nxdomain no wildcard proof", "b.example.com.", TypeA, true, []RR{a}, "wildcard *.example.com."},
+		{"nxdomain wildcard exists", "b.example.com.", TypeA, true, []RR{wildApex, wild, a}, "wildcard *.example.com. exists"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := nsecDenial(tt.nsecs, tt.qname, tt.qtype, tt.nxdomain)
+			if tt.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.err)
+			}
+		})
+	}
+}
+
+func TestNSEC3Denial(t *testing.T) {
+	params := &NSEC3{HashAlgorithm: 1}
+	hash := func(name string) []byte {
+		hashed, err := nsec3Hash(name, params)
+		assert.NoError(t, err)
+		return hashed
+	}
+	nsec3 := func(name, next string, flags uint8, types ...Type) RR {
+		return RR{
+			Name: base32Hex.EncodeToString(hash(name)) + ".example.com.", Type: TypeNSEC3, Class: ClassINET,
+			Data: &NSEC3{HashAlgorithm: 1, Flags: flags, NextHashed: hash(next), Types: types},
+		}
+	}
+	// Two names make a ring that covers every other hash.
+	ring := func(flags uint8) []RR {
+		return []RR{
+			nsec3("example.com.", "a.example.com.", flags, TypeSOA, TypeNS, TypeRRSIG),
+			nsec3("a.example.com.", "example.com.", flags, TypeA, TypeRRSIG),
+		}
+	}
+
+	tests := []struct {
+		name     string
+		qname    string
+		qtype    Type
+		nxdomain bool
+		nsec3s   []RR
+		optOut   bool
+		err      string
+	}{
+		{"nodata", "a.example.com.", TypeTXT, false, ring(0), false, ""},
+		{"nodata type in bitmap", "a.example.com.", TypeA, false, ring(0), false, "lists A"},
+		{"nodata no match", "b.example.com.", TypeA, false, ring(0), false, "no NSEC3 record matches"},
+		{"nodata DS opt-out", "b.example.com.", TypeDS, false, ring(nsec3OptOut), true, ""},
+		{"nodata opt-out only for DS", "b.example.com.", TypeA, false, ring(nsec3OptOut), false, "no NSEC3 record matches"},
+		{"nxdomain", "b.example.com.", TypeA, true, ring(0), false, ""},
+		{"nxdomain opt-out", "b.example.com.", TypeA, true, ring(nsec3OptOut), true, ""},
+		{"nxdomain name exists", "a.example.com.", TypeA, true, ring(0), false, "shows a.example.com. exists"},
+		{"nxdomain no closest encloser", "b.example.com.", TypeA, true, ring(0)[1:], false, "matches an ancestor"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			optOut, err := nsec3Denial(tt.nsec3s, tt.qname, tt.qtype, tt.nxdomain)
+			if tt.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.err)
+			}
+			assert.Equal(t, tt.optOut, optOut)
+		})
+	}
+}
+
+func TestVerifyRRSIGTampered(t *testing.T) {
+	for _, alg := range []uint8{AlgorithmECDSAP256SHA256, AlgorithmRSASHA256, AlgorithmED25519} {
+		s := newTestSigner(t, "example.com.", alg)
+		rr := RR{Name: "WWW.Example.com.", Type: TypeMX, Class: ClassINET, TTL: 300, Data: &MX{Pref: 10, Host: "Mail.Example.com."}}
+		sig := s.sign(t, rr).Data.(*RRSIG)
+
+		// Case and TTL do not change the canonical form.
+		lower := RR{Name: "www.example.com.", Type: TypeMX, Class: ClassINET, TTL: 10, Data: &MX{Pref: 10, Host: "mail.example.com."}}
+		assert.NoError(t, VerifyRRSIG([]RR{lower}, sig, s.key, time.Now()), "algorithm %d", alg)
+
+		rr.Data = &MX{Pref: 20, Host: "mail.example.com."}
+		assert.ErrorContains(t, VerifyRRSIG([]RR{rr}, sig, s.key, time.Now()), "signature by key", "algorithm %d", alg)
+		assert.ErrorContains(t, VerifyRRSIG([]RR{lower}, sig, s.key, time.Now().Add(-2*time.Hour)), "not valid before")
+	}
+}
+
+func TestKeyTag(t *testing.T) {
+	// The root zone's KSK-2017 and its DS record from the IANA trust anchors.
+	pub, err := base64.StdEncoding.DecodeString(
+		"AwEAAaz/tAm8yTn4Mfeh5eyI96WSVexTBAvkMgJzkKTOiW1vkIbzxeF3+/4RgWOq7HrxRixHlFlExOLAJr5emLvN7SWXgnLh4+B5xQlNVz8Og8kvArMtNROxVQuCaSnIDdD5LKyWbRd2n9WGe2R8PzgCmr3EgVLrjyBxWezF0jLHwVN8efS3rCj/EWgvIWgb9tarpVUDK/b58Da+sqqls3eNbuv7pr+eoZG+SrDK6nWeL3c6H5Apxz7LjVc1uTIdsIXxuOLYA4/ilBmSVIzuDWfdRUfhHdY6+cn8HFRm+2hM8AnXGXws9555KrUB5qihylGa8subX2Nn6UwNR1AkUTV74bU=")
+	assert.NoError(t, err)
+	key := &DNSKEY{Flags: 257, Protocol: 3, Algorithm: AlgorithmRSASHA256, PublicKey: pub}
+	assert.Equal(t, uint16(20326), key.KeyTag())
+
+	ds, err := key.ToDS(".", DigestSHA256)
+	assert.NoError(t, err)
+	assert.Equal(t, RootTrustAnchors[0].Data.String(), ds.String())
+}
+
+func TestTypeBitmap(t *testing.T) {
+	types := []Type{TypeNSEC, TypeA, TypeCAA, TypeRRSIG, TypeMX}
+	b := packTypeBitmap(nil, types)
+	got, err := unpackTypeBitmap(b)
+	assert.NoError(t, err)
+	assert.Equal(t, []Type{TypeA, TypeMX, TypeRRSIG, TypeNSEC, TypeCAA}, got)
+
+	_, err = unpackTypeBitmap([]byte{0, 5, 1})
+	assert.Error(t, err)
+}
+
+func TestNSEC3Hash(t *testing.T) {
+	// RFC 5155 appendix A: salt aabbccdd, 12 iterations.
+	params := &NSEC3{HashAlgorithm: 1, Iterations: 12, Salt: []byte{0xaa, 0xbb, 0xcc, 0xdd}}
+	hashed, err := nsec3Hash("example", params)
+	assert.NoError(t, err)
+	assert.Equal(t, "0P9MHAVEQVM6T7VBL5LOP2U3T2RP3TOM", base32Hex.EncodeToString(hashed))
+	hashed, err = nsec3Hash("a.example", params)
+	assert.NoError(t, err)
+	assert.Equal(t, "35MTHGPGCU1QG68FAB165KLNSNK3DPVL", base32Hex.EncodeToString(hashed))
+
+	assert.True(t, hashCovers([]byte{1}, []byte{5}, []byte{3}))
+	assert.True(t, hashCovers([]byte{9}, []byte{2}, []byte{1}))
+	assert.False(t, hashCovers([]byte{1}, []byte{5}, []byte{7}))
+}
+
+func TestDNSSECRecordsRoundTrip(t *testing.T) {
+	m := &Message{Answer: []RR{
+		{Name: "example.com.", Type: TypeRRSIG, Class: ClassINET, TTL: 300, Data: &RRSIG{TypeCovered: TypeA, Algorithm: 13, Labels: 2, OrigTTL: 300, Expiration: 1700000000, Inception: 1690000000, KeyTag: 12345, SignerName: "example.com.", Signature: []byte{1, 2, 3}}},
+		{Name: "example.com.", Type: TypeNSEC, Class: ClassINET, TTL: 300, Data: &NSEC{NextDomain: "a.example.com.", Types: []Type{TypeA, TypeNS, TypeSOA}}},
+		{Name: "example.com.", Type: TypeNSEC3, Class: ClassINET, TTL: 300, Data: &NSEC3{HashAlgorithm: 1, Flags: 1, Iterations: 0, NextHashed: []byte{0xde, 0xad}, Types: []Type{TypeA}}},
+	}}
+	out, err := m.Pack()
+	assert.NoError(t, err)
+	got := &Message{}
+	assert.NoError(t, got.Unpack(out))
+	assert.Equal(t, m.Answer, got.Answer)
+	assert.Equal(t, "A 13 2 300 20231114221320 20230722042640 12345 example.com. AQID", got.Answer[0].Data.String())
+	assert.Equal(t, "a.example.com. A NS SOA", got.Answer[1].Data.String())
+	assert.Equal(t, "1 1 0 - RQMG A", got.Answer[2].Data.String())
+}
+
+func TestRSAPublicKey(t *testing.T) {
+	_, err := rsaPublicKey([]byte{3, 1, 0})
+	assert.Error(t, err)
+	key, err := rsaPublicKey(append([]byte{0, 0, 1, 3}, binary.BigEndian.AppendUint16(nil, 0xC001)...))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, key.E)
+}
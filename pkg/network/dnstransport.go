@@ -0,0 +1,226 @@
+package network
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	dnsMessageMediaType = "application/dns-message"
+	dnsOverTLSPort      = "853"
+)
+
+// Transport defines an interface for carrying a packed DNS query to a
+// server and returning the packed response. String names the protocol.
+type Transport interface {
+	Exchange(ctx context.Context, server string, query []byte) ([]byte, error)
+	String() string
+}
+
+// UDPTransport sends queries as single datagrams (RFC 1035).
+type UDPTransport struct{}
+
+func (UDPTransport) String() string { return "udp" }
+
+// Exchange sends the query and waits for a datagram carrying its message ID,
+// ignoring stray datagrams.
+func (UDPTransport) Exchange(ctx context.Context, server string, query []byte) ([]byte, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	stop := watchConn(ctx, conn)
+	defer stop()
+
+	if _, err := conn.Write(query); err != nil {
+		return nil, connError(ctx, err)
+	}
+	id := binary.BigEndian.Uint16(query)
+	buf := make([]byte, 65535)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, connError(ctx, err)
+		}
+		if n < 12 || binary.BigEndian.Uint16(buf) != id {
+			// Stray or spoofed datagram; keep waiting for our answer.
+			continue
+		}
+		return append([]byte(nil), buf[:n]...), nil
+	}
+}
+
+// TCPTransport sends queries over TCP with two-octet length framing
+// (RFC 1035 section 4.2.2, RFC 7766).
+type TCPTransport struct{}
+
+func (TCPTransport) String() string { return "tcp" }
+
+// Exchange opens a TCP connection to server and sends the query on it.
+func (TCPTransport) Exchange(ctx context.Context, server string, query []byte) ([]byte, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return streamExchange(ctx, conn, query)
+}
+
+// TLSTransport sends queries over TLS with two-octet length framing
+// (DNS-over-TLS, RFC 7858).
+type TLSTransport struct {
+	// Config is used for the handshake. When ServerName is empty it is
+	// set from the server address.
+	Config *tls.Config
+}
+
+func (TLSTransport) String() string { return "tls" }
+
+// Exchange opens a TLS connection to server and sends the query on it.
+func (t TLSTransport) Exchange(ctx context.Context, server string, query []byte) ([]byte, error) {
+	config := &tls.Config{}
+	if t.Config != nil {
+		config = t.Config.Clone()
+	}
+	if config.ServerName == "" {
+		host, _, err := net.SplitHostPort(server)
+		if err != nil {
+			return nil, err
+		}
+		config.ServerName = host
+	}
+	d := tls.Dialer{Config: config}
+	conn, err := d.DialContext(ctx, "tcp", server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return streamExchange(ctx, conn, query)
+}
+
+// HTTPSTransport sends queries as HTTP requests (DNS-over-HTTPS, RFC 8484).
+// The server passed to Exchange is the URL of the resolver's endpoint.
+type HTTPSTransport struct {
+	// Client performs the requests, http.DefaultClient when nil.
+	Client *http.Client
+	// Method is http.MethodGet or http.MethodPost, POST when empty.
+	Method string
+}
+
+func (HTTPSTransport) String() string { return "https" }
+
+// Exchange sends the query with its ID set to zero, as RFC 8484 recommends
+// for cacheability, and restores the ID in the returned response.
+func (t HTTPSTransport) Exchange(ctx context.Context, server string, query []byte) ([]byte, error) {
+	id := binary.BigEndian.Uint16(query)
+	wire := append([]byte(nil), query...)
+	binary.BigEndian.PutUint16(wire, 0)
+
+	var req *http.Request
+	var err error
+	switch t.Method {
+	case http.MethodGet:
+		u, perr := url.Parse(server)
+		if perr != nil {
+			return nil, perr
+		}
+		q := u.Query()
+		q.Set("dns", base64.RawURLEncoding.EncodeToString(wire))
+		u.RawQuery = q.Encode()
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	case http.MethodPost, "":
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost, server, bytes.NewReader(wire))
+		if err == nil {
+			req.Header.Set("Content-Type", dnsMessageMediaType)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported DNS-over-HTTPS method: %s", t.Method)
+	}
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", dnsMessageMediaType)
+
+	client := t.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("DNS-over-HTTPS server returned %s", resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 65535))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) < 12 {
+		return nil, errors.New("DNS-over-HTTPS response too short")
+	}
+	binary.BigEndian.PutUint16(body, id)
+	return body, nil
+}
+
+// streamExchange writes a length-prefixed query on conn and reads the
+// length-prefixed response.
+func streamExchange(ctx context.Context, conn net.Conn, query []byte) ([]byte, error) {
+	stop := watchConn(ctx, conn)
+	defer stop()
+
+	out := binary.BigEndian.AppendUint16(make([]byte, 0, len(query)+2), uint16(len(query)))
+	out = append(out, query...)
+	if _, err := conn.Write(out); err != nil {
+		return nil, connError(ctx, err)
+	}
+	msg, err := readStreamMessage(conn)
+	if err != nil {
+		return nil, connError(ctx, err)
+	}
+	return msg, nil
+}
+
+// readStreamMessage reads one length-prefixed DNS message.
+func readStreamMessage(r io.Reader) ([]byte, error) {
+	var length [2]byte
+	if _, err := io.ReadFull(r, length[:]); err != nil {
+		return nil, err
+	}
+	msg := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// watchConn applies the context deadline to conn and unblocks pending I/O
+// when the context is cancelled. The returned function stops the watch.
+func watchConn(ctx context.Context, conn net.Conn) func() bool {
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	return context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+}
+
+// connError prefers the context error over the I/O error it caused.
+func connError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
@@ -0,0 +1,208 @@
+package network
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// serveTestDNSStream answers length-prefixed queries on connections
+// accepted from ln, one or more per connection.
+func serveTestDNSStream(t *testing.T, ln net.Listener, handler func(req *Message) *Message) string {
+	t.Helper()
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				for {
+					in, err := readStreamMessage(conn)
+					if err != nil {
+						return
+					}
+					req := &Message{}
+					if err := req.Unpack(in); err != nil {
+						return
+					}
+					resp := handler(req)
+					if resp == nil {
+						return
+					}
+					out, err := resp.Pack()
+					if err != nil {
+						return
+					}
+					conn.Write(binary.BigEndian.AppendUint16(nil, uint16(len(out))))
+					conn.Write(out)
+				}
+			}(conn)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+// testCertificate returns a self-signed certificate for 127.0.0.1 and a
+// pool that trusts it.
+func testCertificate(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "net-tools test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		DNSNames:     []string{"localhost"},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: cert}, pool
+}
+
+func TestTLSTransport(t *testing.T) {
+	cert, pool := testCertificate(t)
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := serveTestDNSStream(t, ln, testRecords.handle)
+
+	client := NewTLSClient(addr)
+	client.Transport = TLSTransport{Config: &tls.Config{RootCAs: pool}}
+	hosts, err := client.LookupHost("example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"93.184.216.34", "2606:2800:220:1::248"}, hosts)
+
+	untrusted := NewTLSClient(addr)
+	_, err = untrusted.LookupHost("example.com")
+	assert.Error(t, err)
+}
+
+func TestHTTPSTransport(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var wire []byte
+		var err error
+		switch r.Method {
+		case http.MethodGet:
+			wire, err = base64.RawURLEncoding.DecodeString(r.URL.Query().Get("dns"))
+		case http.MethodPost:
+			assert.Equal(t, dnsMessageMediaType, r.Header.Get("Content-Type"))
+			wire, err = io.ReadAll(r.Body)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		req := &Message{}
+		if err := req.Unpack(wire); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		assert.Equal(t, uint16(0), req.ID)
+		out, _ := testRecords.handle(req).Pack()
+		w.Header().Set("Content-Type", dnsMessageMediaType)
+		w.Write(out)
+	}))
+	defer srv.Close()
+
+	for _, method := range []string{http.MethodGet, http.MethodPost} {
+		t.Run(method, func(t *testing.T) {
+			client := NewHTTPSClient(srv.URL+"/dns-query", method)
+			client.Transport = HTTPSTransport{Client: srv.Client(), Method: method}
+
+			mxs, err := client.LookupMX("example.com")
+			assert.NoError(t, err)
+			assert.Len(t, mxs, 2)
+
+			resp, err := client.Query(context.Background(), "missing.example.com", TypeA)
+			assert.NoError(t, err)
+			assert.Equal(t, RCodeNameError, resp.RCode)
+		})
+	}
+}
+
+func TestHTTPSTransportHTTPError(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "nope", http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	client := NewHTTPSClient(srv.URL, http.MethodPost)
+	client.Transport = HTTPSTransport{Client: srv.Client()}
+	_, err := client.Query(context.Background(), "example.com", TypeA)
+	assert.ErrorContains(t, err, "502")
+}
+
+func TestTCPFallback(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", pc.LocalAddr().String())
+	if err != nil {
+		pc.Close()
+		t.Skipf("cannot listen on TCP at the UDP port: %v", err)
+	}
+
+	udpSize := make(chan uint16, 1)
+	addr := serveTestDNS(t, pc, func(req *Message) *Message {
+		udpSize <- req.EDNSUDPSize()
+		resp := replyTo(req)
+		resp.Truncated = true
+		return resp
+	})
+	serveTestDNSStream(t, ln, testRecords.handle)
+
+	client := NewDNSClient(addr)
+	resp, err := client.Query(context.Background(), "example.com", TypeA)
+	assert.NoError(t, err)
+	assert.Equal(t, uint16(DefaultUDPSize), <-udpSize)
+	assert.Equal(t, "tcp", resp.Transport)
+	assert.True(t, resp.TCPFallback)
+	assert.Len(t, resp.Answer, 1)
+
+	client.Transport = TCPTransport{}
+	resp, err = client.Query(context.Background(), "example.com", TypeMX)
+	assert.NoError(t, err)
+	assert.Equal(t, "tcp", resp.Transport)
+	assert.False(t, resp.TCPFallback)
+	assert.Len(t, resp.Answer, 2)
+}
+
+func TestNewTLSClient(t *testing.T) {
+	assert.Equal(t, "1.1.1.1:853", NewTLSClient("@1.1.1.1").Server)
+	assert.Equal(t, "[2606:4700::1111]:853", NewTLSClient("2606:4700::1111").Server)
+	assert.Equal(t, "dns.example:8853", NewTLSClient("dns.example:8853").Server)
+}
@@ -0,0 +1,237 @@
+package network
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// DefaultUDPSize is the EDNS(0) UDP payload size advertised by DNSClient,
+// the value recommended by DNS Flag Day 2020 to avoid IP fragmentation.
+const DefaultUDPSize = 1232
+
+// ednsDO is the DNSSEC OK flag in the OPT record's TTL (RFC 3225).
+const ednsDO = 0x8000
+
+// EDNS(0) option codes.
+const (
+	EDNSOptionNSID         uint16 = 3  // RFC 5001
+	EDNSOptionClientSubnet uint16 = 8  // RFC 7871
+	EDNSOptionCookie       uint16 = 10 // RFC 7873
+)
+
+// clientCookieLen is the length of a client cookie (RFC 7873 section 4).
+const clientCookieLen = 8
+
+// String decodes the option's data for display, falling back to hex for
+// options without a decoder.
+func (o EDNSOption) String() string {
+	switch o.Code {
+	case EDNSOptionNSID:
+		if len(o.Data) == 0 {
+			return "NSID"
+		}
+		return fmt.Sprintf("NSID: %s (%s)", hex.EncodeToString(o.Data), quoteString(string(o.Data)))
+	case EDNSOptionClientSubnet:
+		if subnet, err := unpackClientSubnet(o.Data); err == nil {
+			return "CLIENT-SUBNET: " + subnet.String()
+		}
+	case EDNSOptionCookie:
+		if len(o.Data) >= clientCookieLen {
+			s := "COOKIE: " + hex.EncodeToString(o.Data[:clientCookieLen])
+			if len(o.Data) > clientCookieLen {
+				s += " " + hex.EncodeToString(o.Data[clientCookieLen:])
+			}
+			return s
+		}
+	}
+	return fmt.Sprintf("%d:%s", o.Code, hex.EncodeToString(o.Data))
+}
+
+// NSIDOption returns an empty NSID option, which asks the server to
+// identify itself (RFC 5001).
+func NSIDOption() EDNSOption {
+	return EDNSOption{Code: EDNSOptionNSID}
+}
+
+// CookieOption returns a COOKIE option carrying a random client cookie
+// (RFC 7873).
+func CookieOption() EDNSOption {
+	cookie := make([]byte, clientCookieLen)
+	rand.Read(cookie)
+	return EDNSOption{Code: EDNSOptionCookie, Data: cookie}
+}
+
+// ClientSubnet is the data of an EDNS Client Subnet option (RFC 7871).
+type ClientSubnet struct {
+	// Family is 1 for IPv4 and 2 for IPv6.
+	Family       uint16
+	SourcePrefix uint8
+	// ScopePrefix is set by the server to the prefix length its answer
+	// applies to, and is zero in queries.
+	ScopePrefix uint8
+	Address     net.IP
+}
+
+// ParseClientSubnet parses a subnet such as "198.51.100.0/24". A bare
+// address is taken as a /32 or /128.
+func ParseClientSubnet(s string) (*ClientSubnet, error) {
+	if !strings.Contains(s, "/") {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid client subnet %q", s)
+		}
+		if ip.To4() != nil {
+			s += "/32"
+		} else {
+			s += "/128"
+		}
+	}
+	ip, ipnet, err := net.ParseCIDR(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid client subnet %q", s)
+	}
+	ones, _ := ipnet.Mask.Size()
+	subnet := &ClientSubnet{Family: 2, SourcePrefix: uint8(ones), Address: ipnet.IP}
+	if ip.To4() != nil {
+		subnet.Family, subnet.Address = 1, ipnet.IP.To4()
+	}
+	return subnet, nil
+}
+
+// Option packs the subnet into an option, truncating the address to the
+// octets the source prefix covers as RFC 7871 requires.
+func (s *ClientSubnet) Option() EDNSOption {
+	data := binary.BigEndian.AppendUint16(nil, s.Family)
+	data = append(data, s.SourcePrefix, s.ScopePrefix)
+	n := min((int(s.SourcePrefix)+7)/8, len(s.Address))
+	data = append(data, s.Address[:n]...)
+	return EDNSOption{Code: EDNSOptionClientSubnet, Data: data}
+}
+
+// String formats the subnet as address/source/scope like dig.
+func (s *ClientSubnet) String() string {
+	return fmt.Sprintf("%s/%d/%d", s.Address, s.SourcePrefix, s.ScopePrefix)
+}
+
+func unpackClientSubnet(data []byte) (*ClientSubnet, error) {
+	if len(data) < 4 {
+		return nil, errMsgTruncated
+	}
+	s := &ClientSubnet{Family: binary.BigEndian.Uint16(data), SourcePrefix: data[2], ScopePrefix: data[3]}
+	size := net.IPv4len
+	if s.Family == 2 {
+		size = net.IPv6len
+	} else if s.Family != 1 {
+		return nil, fmt.Errorf("dns: unknown client subnet family %d", s.Family)
+	}
+	addr := data[4:]
+	if len(addr) > size || int(s.SourcePrefix) > size*8 {
+		return nil, errors.New("dns: invalid client subnet")
+	}
+	s.Address = make(net.IP, size)
+	copy(s.Address, addr)
+	return s, nil
+}
+
+// EDNS is the decoded OPT record of a message.
+type EDNS struct {
+	UDPSize  uint16
+	Version  uint8
+	DNSSECOK bool
+	Options  []EDNSOption
+}
+
+// Option returns the first option with code, or nil.
+func (e *EDNS) Option(code uint16) *EDNSOption {
+	for i := range e.Options {
+		if e.Options[i].Code == code {
+			return &e.Options[i]
+		}
+	}
+	return nil
+}
+
+// NSID returns the server identifier the response carries, or "".
+func (e *EDNS) NSID() string {
+	if o := e.Option(EDNSOptionNSID); o != nil {
+		return string(o.Data)
+	}
+	return ""
+}
+
+// EDNS decodes the message's OPT record, or returns nil when the message
+// does not use EDNS(0).
+func (m *Message) EDNS() *EDNS {
+	opt := m.OPT()
+	if opt == nil {
+		return nil
+	}
+	e := &EDNS{UDPSize: uint16(opt.Class), Version: uint8(opt.TTL >> 16), DNSSECOK: opt.TTL&ednsDO != 0}
+	if data, ok := opt.Data.(*OPT); ok {
+		e.Options = data.Options
+	}
+	return e
+}
+
+// OPT returns the message's OPT pseudo record, or nil when the message
+// does not use EDNS(0).
+func (m *Message) OPT() *RR {
+	for i := range m.Additional {
+		if m.Additional[i].Type == TypeOPT {
+			return &m.Additional[i]
+		}
+	}
+	return nil
+}
+
+// SetEDNS adds an OPT record advertising udpSize, or updates the size of
+// an existing one, and returns the record.
+func (m *Message) SetEDNS(udpSize uint16) *RR {
+	if opt := m.OPT(); opt != nil {
+		opt.Class = Class(udpSize)
+		return opt
+	}
+	m.Additional = append(m.Additional, RR{Name: ".", Type: TypeOPT, Class: Class(udpSize), Data: &OPT{}})
+	return &m.Additional[len(m.Additional)-1]
+}
+
+// EDNSUDPSize returns the UDP payload size advertised in the OPT record,
+// or 0 without EDNS(0).
+func (m *Message) EDNSUDPSize() uint16 {
+	if opt := m.OPT(); opt != nil {
+		return uint16(opt.Class)
+	}
+	return 0
+}
+
+// DNSSECOK reports whether the message's OPT record has the DO bit set.
+func (m *Message) DNSSECOK() bool {
+	opt := m.OPT()
+	return opt != nil && opt.TTL&ednsDO != 0
+}
+
+// withEDNS returns a copy of query carrying an OPT record for udpSize and
+// options, with the DO bit when dnssec is set, leaving the caller's message
+// untouched. DNSSEC and options need EDNS(0), so DefaultUDPSize is used
+// when udpSize is zero.
+func withEDNS(query *Message, udpSize uint16, dnssec bool, options ...EDNSOption) *Message {
+	if (dnssec || len(options) > 0) && udpSize == 0 {
+		udpSize = DefaultUDPSize
+	}
+	if udpSize == 0 || query.OPT() != nil {
+		return query
+	}
+	q := *query
+	opt := RR{Name: ".", Type: TypeOPT, Class: Class(udpSize), Data: &OPT{Options: options}}
+	if dnssec {
+		opt.TTL |= ednsDO
+		q.CheckingDisabled = true
+	}
+	q.Additional = append(append([]RR(nil), query.Additional...), opt)
+	return &q
+}
@@ -0,0 +1,146 @@
+package network
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetEDNS(t *testing.T) {
+	m := NewQuery("example.com", TypeA)
+	assert.Nil(t, m.OPT())
+	assert.Equal(t, uint16(0), m.EDNSUDPSize())
+
+	m.SetEDNS(4096)
+	m.SetEDNS(1232)
+	assert.Len(t, m.Additional, 1)
+	assert.Equal(t, uint16(1232), m.EDNSUDPSize())
+}
+
+func TestWithEDNS(t *testing.T) {
+	m := NewQuery("example.com", TypeA)
+	assert.Same(t, m, withEDNS(m, 0, false))
+
+	q := withEDNS(m, DefaultUDPSize, false)
+	assert.Nil(t, m.OPT())
+	assert.Equal(t, uint16(DefaultUDPSize), q.EDNSUDPSize())
+	assert.False(t, q.DNSSECOK())
+	assert.Same(t, q, withEDNS(q, 4096, false))
+
+	q = withEDNS(m, 0, false, NSIDOption())
+	assert.Equal(t, uint16(DefaultUDPSize), q.EDNSUDPSize())
+	assert.Equal(t, []EDNSOption{{Code: EDNSOptionNSID}}, q.EDNS().Options)
+
+	q = withEDNS(m, 0, true)
+	assert.Equal(t, uint16(DefaultUDPSize), q.EDNSUDPSize())
+	assert.True(t, q.DNSSECOK())
+	assert.True(t, q.CheckingDisabled)
+	assert.False(t, m.CheckingDisabled)
+}
+
+func TestExtendedRCode(t *testing.T) {
+	m := NewQuery("example.com", TypeA)
+	m.Response = true
+	m.SetEDNS(DefaultUDPSize)
+	m.RCode = RCode(16) // BADVERS, needs the OPT record's extended bits
+
+	out, err := m.Pack()
+	assert.NoError(t, err)
+	got := &Message{}
+	assert.NoError(t, got.Unpack(out))
+	assert.Equal(t, RCode(16), got.RCode)
+	assert.Equal(t, uint16(DefaultUDPSize), got.EDNSUDPSize())
+}
+
+func TestParseClientSubnet(t *testing.T) {
+	tests := []struct {
+		in   string
+		want ClientSubnet
+		data []byte
+	}{
+		{"198.51.100.7/24", ClientSubnet{Family: 1, SourcePrefix: 24, Address: net.IP{198, 51, 100, 0}}, []byte{0, 1, 24, 0, 198, 51, 100}},
+		{"198.51.100.7", ClientSubnet{Family: 1, SourcePrefix: 32, Address: net.IP{198, 51, 100, 7}}, []byte{0, 1, 32, 0, 198, 51, 100, 7}},
+		{"0.0.0.0/0", ClientSubnet{Family: 1, Address: net.IP{0, 0, 0, 0}}, []byte{0, 1, 0, 0}},
+		{"2001:db8:1234::/36", ClientSubnet{Family: 2, SourcePrefix: 36, Address: net.ParseIP("2001:db8:1000::")}, []byte{0, 2, 36, 0, 0x20, 0x01, 0x0d, 0xb8, 0x10}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			subnet, err := ParseClientSubnet(tt.in)
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, tt.want, *subnet)
+			opt := subnet.Option()
+			assert.Equal(t, EDNSOptionClientSubnet, opt.Code)
+			assert.Equal(t, tt.data, opt.Data)
+
+			back, err := unpackClientSubnet(opt.Data)
+			assert.NoError(t, err)
+			assert.True(t, subnet.Address.Equal(back.Address))
+			assert.Equal(t, subnet.SourcePrefix, back.SourcePrefix)
+		})
+	}
+
+	for _, s := range []string{"", "example.com", "198.51.100.0/33"} {
+		_, err := ParseClientSubnet(s)
+		assert.Error(t, err, s)
+	}
+	_, err := unpackClientSubnet([]byte{0, 3, 0, 0})
+	assert.Error(t, err)
+	_, err = unpackClientSubnet([]byte{0, 1, 8, 0, 1, 2, 3, 4, 5})
+	assert.Error(t, err)
+}
+
+func TestEDNSOptionString(t *testing.T) {
+	assert.Equal(t, "NSID", NSIDOption().String())
+	assert.Equal(t, `NSID: 6e73312e616d73 ("ns1.ams")`, EDNSOption{Code: EDNSOptionNSID, Data: []byte("ns1.ams")}.String())
+	assert.Equal(t, "CLIENT-SUBNET: 198.51.100.0/24/20",
+		EDNSOption{Code: EDNSOptionClientSubnet, Data: []byte{0, 1, 24, 20, 198, 51, 100}}.String())
+	assert.Equal(t, "COOKIE: 0102030405060708", EDNSOption{Code: EDNSOptionCookie, Data: []byte{1, 2, 3, 4, 5, 6, 7, 8}}.String())
+	assert.Equal(t, "COOKIE: 0102030405060708 a1a2a3a4a5a6a7a8",
+		EDNSOption{Code: EDNSOptionCookie, Data: []byte{1, 2, 3, 4, 5, 6, 7, 8, 0xa1, 0xa2, 0xa3, 0xa4, 0xa5, 0xa6, 0xa7, 0xa8}}.String())
+	assert.Equal(t, "15:0001", EDNSOption{Code: 15, Data: []byte{0, 1}}.String())
+
+	cookie := CookieOption()
+	assert.Len(t, cookie.Data, clientCookieLen)
+	assert.NotEqual(t, cookie.Data, CookieOption().Data)
+}
+
+func TestDNSClientEDNSOptions(t *testing.T) {
+	got := make(chan []EDNSOption, 1)
+	addr := startTestDNSServer(t, func(req *Message) *Message {
+		got <- req.EDNS().Options
+		resp := testRecords.handle(req)
+		opt := resp.SetEDNS(4096)
+		opt.TTL = 1<<16 | ednsDO
+		subnet, _ := unpackClientSubnet(req.EDNS().Option(EDNSOptionClientSubnet).Data)
+		subnet.ScopePrefix = 16
+		opt.Data = &OPT{Options: []EDNSOption{{Code: EDNSOptionNSID, Data: []byte("ns1.ams")}, subnet.Option()}}
+		return resp
+	})
+	subnet, err := ParseClientSubnet("198.51.100.0/24")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewDNSClient(addr)
+	client.Options = []EDNSOption{NSIDOption(), subnet.Option()}
+
+	resp, err := client.Query(context.Background(), "example.com", TypeA)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, client.Options, <-got)
+	edns := resp.EDNS()
+	if !assert.NotNil(t, edns) {
+		return
+	}
+	assert.Equal(t, uint16(4096), edns.UDPSize)
+	assert.Equal(t, uint8(1), edns.Version)
+	assert.True(t, edns.DNSSECOK)
+	assert.Equal(t, "ns1.ams", edns.NSID())
+	assert.Equal(t, "CLIENT-SUBNET: 198.51.100.0/24/16", edns.Option(EDNSOptionClientSubnet).String())
+	assert.Nil(t, edns.Option(EDNSOptionCookie))
+	assert.Equal(t, `NSID: 6e73312e616d73 ("ns1.ams"); CLIENT-SUBNET: 198.51.100.0/24/16`, resp.OPT().Data.String())
+}
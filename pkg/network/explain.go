@@ -0,0 +1,93 @@
+package network
+
+import (
+	"errors"
+	"io/fs"
+	"strings"
+)
+
+const (
+	hostsPath    = "/etc/hosts"
+	nsswitchPath = "/etc/nsswitch.conf"
+)
+
+// Sources of a system resolver answer.
+const (
+	SourceFiles = "files"
+	SourceDNS   = "dns"
+)
+
+// ResolverExplanation describes how the system resolver would look up a
+// name, for understanding why an application sees a different answer from
+// the DNS.
+type ResolverExplanation struct {
+	Name string
+	Conf *ResolvConf
+	// Sources are the hosts database services of nsswitch.conf in order.
+	Sources []NSSwitchSource
+	// Candidates are the names queried over DNS, from Conf.Candidates.
+	Candidates []string
+	// HostsAddrs are the name's addresses in the hosts file.
+	HostsAddrs []string
+	// Source is SourceFiles when the hosts file answers before DNS is
+	// asked, SourceDNS when DNS is asked, or empty when neither is.
+	Source string
+}
+
+// ExplainLookup works out which source answers name given the resolver
+// configuration, the name service switch and the hosts file. Only the
+// files, dns and resolve services are understood; others, such as mdns or
+// myhostname, are passed over as if unavailable for the name.
+func ExplainLookup(name string, conf *ResolvConf, nss NSSwitch, hosts *HostsFile) *ResolverExplanation {
+	e := &ResolverExplanation{
+		Name:       name,
+		Conf:       conf,
+		Sources:    nss.Hosts(),
+		Candidates: conf.Candidates(name),
+		HostsAddrs: hosts.Lookup(name),
+	}
+	for _, source := range e.Sources {
+		service := strings.ToLower(source.Service)
+		switch {
+		case (service == "files" || service == "resolve") && len(e.HostsAddrs) > 0:
+			// systemd-resolved answers from the hosts file itself before
+			// asking DNS.
+			e.Source = SourceFiles
+			return e
+		case service == "dns" || service == "resolve":
+			e.Source = SourceDNS
+			return e
+		case service == "files" && source.returnsOn("NOTFOUND"):
+			return e
+		}
+	}
+	return e
+}
+
+// ExplainSystemLookup explains a lookup of name with the host's
+// /etc/resolv.conf, /etc/nsswitch.conf and /etc/hosts. Missing files are
+// treated as empty, as the system resolver does.
+func ExplainSystemLookup(name string) (*ResolverExplanation, error) {
+	conf, err := LoadResolvConf(resolvConfPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		conf, err = ParseResolvConf(strings.NewReader(""))
+	}
+	if err != nil {
+		return nil, err
+	}
+	nss, err := LoadNSSwitch(nsswitchPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		nss, err = NSSwitch{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	hosts, err := LoadHostsFile(hostsPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		hosts, err = &HostsFile{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ExplainLookup(name, conf, nss, hosts), nil
+}
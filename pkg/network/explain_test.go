@@ -0,0 +1,45 @@
+package network
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExplainLookup(t *testing.T) {
+	conf, err := LoadResolvConf("testdata/resolv.conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hosts, err := LoadHostsFile("testdata/hosts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	nss, err := LoadNSSwitch("testdata/nsswitch.conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := ExplainLookup("intranet", conf, nss, hosts)
+	assert.Equal(t, SourceFiles, e.Source)
+	assert.Equal(t, []string{"192.0.2.10"}, e.HostsAddrs)
+	assert.Equal(t, []string{"intranet.corp.example.com.", "intranet.example.com.", "intranet."}, e.Candidates)
+
+	e = ExplainLookup("www.example.com", conf, nss, hosts)
+	assert.Equal(t, SourceDNS, e.Source)
+	assert.Empty(t, e.HostsAddrs)
+	assert.Equal(t, "www.example.com.", e.Candidates[0])
+
+	sources := func(line string) NSSwitch {
+		nss, err := ParseNSSwitch(strings.NewReader("hosts: " + line))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return nss
+	}
+	assert.Equal(t, SourceDNS, ExplainLookup("intranet", conf, sources("dns files"), hosts).Source)
+	assert.Equal(t, SourceFiles, ExplainLookup("intranet", conf, sources("resolve [!UNAVAIL=return] dns"), hosts).Source)
+	assert.Equal(t, "", ExplainLookup("www", conf, sources("files [NOTFOUND=return] dns"), hosts).Source)
+	assert.Equal(t, "", ExplainLookup("www", conf, sources("files myhostname"), hosts).Source)
+}
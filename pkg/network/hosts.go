@@ -0,0 +1,155 @@
+package network
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"os"
+	"strings"
+)
+
+// HostsEntry is one line of a hosts file.
+type HostsEntry struct {
+	IP    string
+	Names []string
+}
+
+// HostsFile is a parsed hosts(5) file.
+type HostsFile struct {
+	Entries []HostsEntry
+}
+
+// LoadHostsFile reads the hosts file at path.
+func LoadHostsFile(path string) (*HostsFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ParseHostsFile(file)
+}
+
+// ParseHostsFile parses a hosts file, skipping lines whose address is not
+// valid as the system resolver does.
+func ParseHostsFile(r io.Reader) (*HostsFile, error) {
+	hosts := &HostsFile{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(line)
+		if len(fields) < 2 || net.ParseIP(fields[0]) == nil {
+			continue
+		}
+		hosts.Entries = append(hosts.Entries, HostsEntry{IP: fields[0], Names: fields[1:]})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return hosts, nil
+}
+
+// Lookup returns the addresses of name in file order, matching names and
+// aliases without regard to case or a trailing dot.
+func (h *HostsFile) Lookup(name string) []string {
+	name = strings.TrimSuffix(name, ".")
+	var addrs []string
+	for _, entry := range h.Entries {
+		for _, n := range entry.Names {
+			if strings.EqualFold(n, name) {
+				addrs = append(addrs, entry.IP)
+				break
+			}
+		}
+	}
+	return addrs
+}
+
+// NSSwitchSource is one service of an nsswitch.conf database line, with
+// the bracketed actions that follow it, such as dns with !UNAVAIL=return.
+type NSSwitchSource struct {
+	Service string
+	Actions []string
+}
+
+func (s NSSwitchSource) String() string {
+	if len(s.Actions) == 0 {
+		return s.Service
+	}
+	return s.Service + " [" + strings.Join(s.Actions, " ") + "]"
+}
+
+// returnsOn reports whether the source ends the lookup when its service
+// returns status, such as NOTFOUND.
+func (s NSSwitchSource) returnsOn(status string) bool {
+	for _, action := range s.Actions {
+		criterion, what, _ := strings.Cut(action, "=")
+		negate := strings.HasPrefix(criterion, "!")
+		if strings.EqualFold(what, "return") && strings.EqualFold(strings.TrimPrefix(criterion, "!"), status) != negate {
+			return true
+		}
+	}
+	return false
+}
+
+// NSSwitch maps each database of nsswitch.conf(5), such as hosts, to its
+// sources in lookup order.
+type NSSwitch map[string][]NSSwitchSource
+
+// defaultHostsSources is used without a hosts line, matching the Go
+// resolver's default of the hosts file before DNS.
+var defaultHostsSources = []NSSwitchSource{{Service: "files"}, {Service: "dns"}}
+
+// LoadNSSwitch reads the name service switch configuration at path.
+func LoadNSSwitch(path string) (NSSwitch, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ParseNSSwitch(file)
+}
+
+// ParseNSSwitch parses an nsswitch.conf file.
+func ParseNSSwitch(r io.Reader) (NSSwitch, error) {
+	nss := make(NSSwitch)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		database, services, ok := strings.Cut(line, ":")
+		database = strings.TrimSpace(database)
+		if !ok || database == "" {
+			continue
+		}
+		var sources []NSSwitchSource
+		inActions := false
+		for _, field := range strings.Fields(strings.NewReplacer("[", " [ ", "]", " ] ").Replace(services)) {
+			switch {
+			case field == "[":
+				inActions = true
+			case field == "]":
+				inActions = false
+			case inActions:
+				if len(sources) > 0 {
+					s := &sources[len(sources)-1]
+					s.Actions = append(s.Actions, field)
+				}
+			default:
+				sources = append(sources, NSSwitchSource{Service: field})
+			}
+		}
+		nss[database] = sources
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nss, nil
+}
+
+// Hosts returns the sources of the hosts database, defaulting to the hosts
+// file then DNS.
+func (n NSSwitch) Hosts() []NSSwitchSource {
+	if sources, ok := n["hosts"]; ok && len(sources) > 0 {
+		return sources
+	}
+	return defaultHostsSources
+}
@@ -0,0 +1,51 @@
+package network
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseHostsFile(t *testing.T) {
+	hosts, err := LoadHostsFile("testdata/hosts")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Len(t, hosts.Entries, 4)
+	assert.Equal(t, HostsEntry{IP: "192.0.2.10", Names: []string{"intranet.corp.example.com", "intranet"}}, hosts.Entries[2])
+
+	assert.Equal(t, []string{"127.0.0.1", "::1"}, hosts.Lookup("localhost"))
+	assert.Equal(t, []string{"192.0.2.10", "2001:db8::10"}, hosts.Lookup("INTRANET.corp.example.com."))
+	assert.Equal(t, []string{"192.0.2.10"}, hosts.Lookup("intranet"))
+	assert.Empty(t, hosts.Lookup("broken"))
+	assert.Empty(t, hosts.Lookup("office"))
+}
+
+func TestParseNSSwitch(t *testing.T) {
+	nss, err := LoadNSSwitch("testdata/nsswitch.conf")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []NSSwitchSource{
+		{Service: "files"},
+		{Service: "mdns4_minimal", Actions: []string{"NOTFOUND=return"}},
+		{Service: "dns"},
+		{Service: "myhostname"},
+	}, nss.Hosts())
+	assert.Equal(t, []NSSwitchSource{{Service: "files"}}, nss["networks"])
+	assert.Equal(t, "mdns4_minimal [NOTFOUND=return]", nss.Hosts()[1].String())
+
+	nss, err = ParseNSSwitch(strings.NewReader("hosts: dns[ !UNAVAIL=return  NOTFOUND=continue ]files\n"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	dns := nss.Hosts()[0]
+	assert.Equal(t, []string{"!UNAVAIL=return", "NOTFOUND=continue"}, dns.Actions)
+	assert.Equal(t, "files", nss.Hosts()[1].Service)
+	assert.True(t, dns.returnsOn("NOTFOUND"))
+	assert.False(t, dns.returnsOn("UNAVAIL"))
+	assert.False(t, NSSwitchSource{Service: "files"}.returnsOn("NOTFOUND"))
+
+	assert.Equal(t, defaultHostsSources, NSSwitch{}.Hosts())
+}
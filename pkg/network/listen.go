@@ -0,0 +1,225 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// maxUDPPayload is the largest payload of a UDP datagram over IPv4.
+const maxUDPPayload = 65507
+
+// ListenMode is what a NetcatServer does with the data it receives.
+type ListenMode int
+
+const (
+	// ListenPrint writes the data received to the server's Output.
+	ListenPrint ListenMode = iota
+	// ListenEcho sends the data received back to the peer.
+	ListenEcho
+	// ListenDiscard throws the data received away.
+	ListenDiscard
+	// ListenServeFile sends the server's File to each peer, as the reply
+	// to each datagram over UDP.
+	ListenServeFile
+)
+
+func (m ListenMode) String() string {
+	switch m {
+	case ListenEcho:
+		return "echo"
+	case ListenDiscard:
+		return "discard"
+	case ListenServeFile:
+		return "serve file"
+	}
+	return "print"
+}
+
+// ConnLog reports a TCP connection when it is accepted and again when it
+// closes, or a UDP datagram once it is handled.
+type ConnLog struct {
+	Network string
+	Peer    net.Addr
+	// Closed is false for the report of a newly accepted connection.
+	Closed   bool
+	Received int64
+	Sent     int64
+	Duration time.Duration
+	Err      error
+}
+
+// NetcatServer listens like netcat -l, for testing a firewall path from
+// the far side.
+type NetcatServer struct {
+	// Network is "tcp" or "udp".
+	Network string
+	Address string
+	Mode    ListenMode
+	// File is the path served in ListenServeFile mode, read afresh for
+	// each peer.
+	File string
+	// Output receives the data in ListenPrint mode; nil discards it.
+	Output io.Writer
+	// Log is called for each connection or datagram, never concurrently.
+	Log func(ConnLog)
+
+	mu sync.Mutex
+}
+
+// ListenAndServe binds Address on Network and serves until ctx is done.
+func (s *NetcatServer) ListenAndServe(ctx context.Context) error {
+	var lc net.ListenConfig
+	if s.Network == "udp" {
+		pc, err := lc.ListenPacket(ctx, "udp", s.Address)
+		if err != nil {
+			return err
+		}
+		return s.ServePacket(ctx, pc)
+	}
+	ln, err := lc.Listen(ctx, "tcp", s.Address)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ctx, ln)
+}
+
+// Serve accepts connections on ln and handles each one concurrently until
+// ctx is done, then closes ln and the open connections and returns nil.
+func (s *NetcatServer) Serve(ctx context.Context, ln net.Listener) error {
+	defer ln.Close()
+	stop := context.AfterFunc(ctx, func() { ln.Close() })
+	defer stop()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		s.log(ConnLog{Network: "tcp", Peer: conn.RemoteAddr()})
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.handle(ctx, conn)
+		}()
+	}
+}
+
+// handle serves one connection according to the mode and logs it when it
+// closes.
+func (s *NetcatServer) handle(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	entry := ConnLog{Network: "tcp", Peer: conn.RemoteAddr(), Closed: true}
+	start := time.Now()
+	switch s.Mode {
+	case ListenEcho:
+		entry.Received, entry.Err = io.Copy(conn, conn)
+		entry.Sent = entry.Received
+	case ListenServeFile:
+		entry.Sent, entry.Err = s.sendFile(conn)
+		if cw, ok := conn.(closeWriter); ok && entry.Err == nil {
+			cw.CloseWrite()
+			entry.Received, entry.Err = io.Copy(io.Discard, conn)
+		}
+	default:
+		entry.Received, entry.Err = io.Copy(s.output(), conn)
+	}
+	entry.Duration = time.Since(start)
+	if ctx.Err() != nil && entry.Err != nil {
+		entry.Err = ctx.Err()
+	}
+	s.log(entry)
+}
+
+func (s *NetcatServer) sendFile(w io.Writer) (int64, error) {
+	f, err := os.Open(s.File)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	return io.Copy(w, f)
+}
+
+// ServePacket handles each datagram arriving on pc until ctx is done, then
+// closes pc and returns nil. In ListenServeFile mode the file must fit in
+// one datagram.
+func (s *NetcatServer) ServePacket(ctx context.Context, pc net.PacketConn) error {
+	defer pc.Close()
+	stop := context.AfterFunc(ctx, func() { pc.Close() })
+	defer stop()
+
+	var reply []byte
+	if s.Mode == ListenServeFile {
+		data, err := os.ReadFile(s.File)
+		if err != nil {
+			return err
+		}
+		if len(data) > maxUDPPayload {
+			return fmt.Errorf("%s is %d bytes, too large for a UDP datagram", s.File, len(data))
+		}
+		reply = data
+	}
+
+	buf := make([]byte, 65535)
+	for {
+		n, peer, err := pc.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		entry := ConnLog{Network: "udp", Peer: peer, Closed: true, Received: int64(n)}
+		switch s.Mode {
+		case ListenEcho:
+			reply = buf[:n]
+		case ListenPrint:
+			_, entry.Err = s.output().Write(buf[:n])
+		}
+		if reply != nil {
+			var sent int
+			sent, entry.Err = pc.WriteTo(reply, peer)
+			entry.Sent = int64(sent)
+		}
+		s.log(entry)
+	}
+}
+
+func (s *NetcatServer) output() io.Writer {
+	if s.Output == nil {
+		return io.Discard
+	}
+	return lockedWriter{s}
+}
+
+// lockedWriter writes to the server's Output under its lock, so that
+// concurrent connections do not interleave within a write.
+type lockedWriter struct{ s *NetcatServer }
+
+func (w lockedWriter) Write(p []byte) (int, error) {
+	w.s.mu.Lock()
+	defer w.s.mu.Unlock()
+	return w.s.Output.Write(p)
+}
+
+func (s *NetcatServer) log(entry ConnLog) {
+	if s.Log == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Log(entry)
+}
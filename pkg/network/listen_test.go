@@ -0,0 +1,131 @@
+package network
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// startNetcatServer serves s on a loopback port until the test ends and
+// returns the address and a channel of its logs.
+func startNetcatServer(t *testing.T, s *NetcatServer) (string, <-chan ConnLog) {
+	t.Helper()
+	logs := make(chan ConnLog, 16)
+	s.Log = func(entry ConnLog) { logs <- entry }
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	var addr string
+	if s.Network == "udp" {
+		pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		addr = pc.LocalAddr().String()
+		go func() { done <- s.ServePacket(ctx, pc) }()
+	} else {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		addr = ln.Addr().String()
+		go func() { done <- s.Serve(ctx, ln) }()
+	}
+	t.Cleanup(func() {
+		cancel()
+		assert.NoError(t, <-done)
+	})
+	return addr, logs
+}
+
+// exchange sends data over a new TCP connection, half-closes it and
+// returns everything the server sent back.
+func exchange(t *testing.T, addr, data string) string {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.Write([]byte(data))
+	conn.(*net.TCPConn).CloseWrite()
+	reply, _ := io.ReadAll(conn)
+	return string(reply)
+}
+
+func TestNetcatServerTCP(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "index.html")
+	if err := os.WriteFile(file, []byte("<h1>it works</h1>\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var printed bytes.Buffer
+	tests := []struct {
+		server         *NetcatServer
+		reply          string
+		received, sent int64
+	}{
+		{&NetcatServer{Mode: ListenEcho}, "hello", 5, 5},
+		{&NetcatServer{Mode: ListenDiscard}, "", 5, 0},
+		{&NetcatServer{Mode: ListenServeFile, File: file}, "<h1>it works</h1>\n", 5, 18},
+		{&NetcatServer{Output: &printed}, "", 5, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.server.Mode.String(), func(t *testing.T) {
+			addr, logs := startNetcatServer(t, tt.server)
+			assert.Equal(t, tt.reply, exchange(t, addr, "hello"))
+
+			opened := <-logs
+			assert.False(t, opened.Closed)
+			assert.Equal(t, "tcp", opened.Network)
+			closed := <-logs
+			assert.True(t, closed.Closed)
+			assert.Equal(t, opened.Peer, closed.Peer)
+			assert.NoError(t, closed.Err)
+			assert.Equal(t, tt.received, closed.Received)
+			assert.Equal(t, tt.sent, closed.Sent)
+		})
+	}
+	assert.Equal(t, "hello", printed.String())
+}
+
+func TestNetcatServerUDP(t *testing.T) {
+	addr, logs := startNetcatServer(t, &NetcatServer{Network: "udp", Mode: ListenEcho})
+	host, port, _ := net.SplitHostPort(addr)
+	result, err := NetcatUDP(context.Background(), host, port, []byte("ping"), time.Second)
+	if assert.NoError(t, err) {
+		assert.Equal(t, PortOpen, result.State)
+		assert.Equal(t, []byte("ping"), result.Reply)
+	}
+	entry := <-logs
+	assert.Equal(t, ConnLog{Network: "udp", Peer: entry.Peer, Closed: true, Received: 4, Sent: 4}, entry)
+
+	addr, logs = startNetcatServer(t, &NetcatServer{Network: "udp", Mode: ListenDiscard})
+	host, port, _ = net.SplitHostPort(addr)
+	result, err = NetcatUDP(context.Background(), host, port, []byte("ping"), 100*time.Millisecond)
+	if assert.NoError(t, err) {
+		assert.Equal(t, PortOpenFiltered, result.State)
+	}
+	assert.Equal(t, int64(4), (<-logs).Received)
+}
+
+func TestNetcatServerUDPFileTooLarge(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "big")
+	if err := os.WriteFile(file, make([]byte, maxUDPPayload+1), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s := &NetcatServer{Network: "udp", Address: "127.0.0.1:0", Mode: ListenServeFile, File: file}
+	assert.ErrorContains(t, s.ListenAndServe(context.Background()), "too large for a UDP datagram")
+}
+
+func TestNetcatServerCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &NetcatServer{Address: "127.0.0.1:0", Mode: ListenDiscard}
+	time.AfterFunc(50*time.Millisecond, cancel)
+	assert.NoError(t, s.ListenAndServe(ctx))
+}
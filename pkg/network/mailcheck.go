@@ -0,0 +1,185 @@
+package network
+
+import (
+	"crypto/x509"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Severity ranks a mail authentication finding.
+type Severity int
+
+const (
+	SeverityInfo Severity = iota
+	SeverityWarning
+	SeverityError
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityInfo:
+		return "info"
+	case SeverityWarning:
+		return "warning"
+	}
+	return "error"
+}
+
+// MailFinding is a problem or notable setting in a mail record.
+type MailFinding struct {
+	Severity Severity
+	Message  string
+}
+
+func (f MailFinding) String() string {
+	return fmt.Sprintf("%s: %s", f.Severity, f.Message)
+}
+
+// findings collects MailFindings.
+type findings []MailFinding
+
+func (f *findings) add(severity Severity, format string, args ...interface{}) {
+	*f = append(*f, MailFinding{Severity: severity, Message: fmt.Sprintf(format, args...)})
+}
+
+// MailChecker fetches and lints the SPF, DMARC and DKIM records of a domain
+// and probes its mail exchangers.
+type MailChecker struct {
+	Lookup HostLookup
+	// Dialer connects to mail exchangers; nil uses NetDialer.
+	Dialer Dialer
+	// SMTPPorts are the ports probed on each mail exchanger; empty uses
+	// DefaultSMTPPorts.
+	SMTPPorts []int
+	// Timeout bounds each SMTP probe; zero uses DefaultSMTPTimeout.
+	Timeout time.Duration
+	// RootCAs verifies mail exchanger certificates; nil uses the system
+	// roots.
+	RootCAs *x509.CertPool
+	// HeloName is the name sent with EHLO; empty uses this host's name.
+	HeloName string
+}
+
+// MailReport holds the parsed records of a domain and their findings.
+type MailReport struct {
+	Domain string
+	SPF    *SPFResult
+	DMARC  *DMARCResult
+	DKIM   []*DKIMResult
+	// MX is set when the mail exchangers were probed.
+	MX *MXResult
+}
+
+// Check looks up the SPF and DMARC records of domain and the DKIM keys of
+// the given selectors.
+func (c *MailChecker) Check(domain string, selectors ...string) *MailReport {
+	domain = strings.TrimSuffix(domain, ".")
+	report := &MailReport{
+		Domain: domain,
+		SPF:    ExpandSPF(c.Lookup, domain),
+		DMARC:  c.dmarc(domain),
+	}
+	for _, selector := range selectors {
+		report.DKIM = append(report.DKIM, c.dkim(domain, selector))
+	}
+	return report
+}
+
+// Findings returns every finding of the report, the SPF include tree
+// flattened.
+func (r *MailReport) Findings() []MailFinding {
+	var all []MailFinding
+	all = append(all, r.SPF.AllFindings()...)
+	all = append(all, r.DMARC.Findings...)
+	for _, d := range r.DKIM {
+		all = append(all, d.Findings...)
+	}
+	if r.MX != nil {
+		all = append(all, r.MX.Findings...)
+	}
+	return all
+}
+
+// txtWithPrefix returns the TXT records of name that start with prefix,
+// case-insensitively. A name without TXT records yields no records and no
+// error.
+func txtWithPrefix(lookup HostLookup, name, prefix string) ([]string, error) {
+	txts, err := lookupTXTRecords(lookup, name)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var out []string
+	for _, txt := range txts {
+		if len(txt) >= len(prefix) && strings.EqualFold(txt[:len(prefix)], prefix) {
+			out = append(out, txt)
+		}
+	}
+	return out, nil
+}
+
+// tag is one name=value pair of a DKIM or DMARC tag list.
+type tag struct {
+	Name  string
+	Value string
+}
+
+// parseTagList splits a tag list (RFC 6376 section 3.2) of the form
+// "a=1; b=2". Whitespace around names and values is dropped.
+func parseTagList(s string) ([]tag, error) {
+	var tags []tag
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(s, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(part, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return tags, fmt.Errorf("malformed tag %q", part)
+		}
+		if seen[name] {
+			return tags, fmt.Errorf("duplicate tag %q", name)
+		}
+		seen[name] = true
+		tags = append(tags, tag{Name: name, Value: strings.TrimSpace(value)})
+	}
+	return tags, nil
+}
+
+// closestWord returns the candidate within two edits of word, for
+// suggesting fixes to typos, or "".
+func closestWord(word string, candidates []string) string {
+	best, bestDist := "", 3
+	for _, c := range candidates {
+		if d := editDistance(strings.ToLower(word), c); d < bestDist {
+			best, bestDist = c, d
+		}
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
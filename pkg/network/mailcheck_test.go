@@ -0,0 +1,99 @@
+package network
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// txtLookup serves TXT records from a map; missing names are NXDOMAIN.
+func txtLookup(records map[string][]string) MockHostLookup {
+	return MockHostLookup{
+		LookupTXTFunc: func(domain string) ([]string, error) {
+			txts, ok := records[domain]
+			if !ok {
+				return nil, &net.DNSError{Err: "no such host", Name: domain, IsNotFound: true}
+			}
+			return txts, nil
+		},
+	}
+}
+
+// hasFinding reports whether any finding of the given severity contains
+// substr.
+func hasFinding(fs []MailFinding, severity Severity, substr string) bool {
+	for _, f := range fs {
+		if f.Severity == severity && strings.Contains(f.Message, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestParseTagList(t *testing.T) {
+	tags, err := parseTagList(" v=DMARC1 ; p = reject;rua=mailto:a@example.com; ")
+	assert.NoError(t, err)
+	assert.Equal(t, []tag{{"v", "DMARC1"}, {"p", "reject"}, {"rua", "mailto:a@example.com"}}, tags)
+
+	_, err = parseTagList("v=DKIM1; p")
+	assert.ErrorContains(t, err, "malformed tag")
+
+	_, err = parseTagList("p=a; p=b")
+	assert.ErrorContains(t, err, "duplicate tag")
+}
+
+func TestClosestWord(t *testing.T) {
+	assert.Equal(t, 0, editDistance("reject", "reject"))
+	assert.Equal(t, 1, editDistance("rejct", "reject"))
+	assert.Equal(t, 3, editDistance("", "abc"))
+	assert.Equal(t, "reject", closestWord("REJCT", dmarcPolicies))
+	assert.Equal(t, "include", closestWord("inclde", spfMechanisms))
+	assert.Equal(t, "", closestWord("something", spfMechanisms))
+}
+
+func TestMailCheck(t *testing.T) {
+	lookup := txtLookup(map[string][]string{
+		"example.com":                 {"google-site-verification=abc", "v=spf1 include:_spf.example.net -all"},
+		"_spf.example.net":            {"v=spf1 ip4:192.0.2.0/24 ~all"},
+		"_dmarc.example.com":          {"v=DMARC1; p=reject; rua=mailto:dmarc@example.com"},
+		"mail._domainkey.example.com": {"v=DKIM1; k=ed25519; p=11qYAYKxCrfVS/7TyWQHOg7hcvPapiMlrwIaaPcHURo="},
+	})
+	checker := &MailChecker{Lookup: lookup}
+
+	report := checker.Check("example.com.", "mail", "missing")
+	assert.Equal(t, "example.com", report.Domain)
+	assert.Equal(t, "v=spf1 include:_spf.example.net -all", report.SPF.Record.Raw)
+	assert.Equal(t, 1, report.SPF.Lookups)
+	assert.Equal(t, "reject", report.DMARC.Record.Policy)
+	if assert.Len(t, report.DKIM, 2) {
+		assert.Equal(t, "mail._domainkey.example.com", report.DKIM[0].Domain)
+		assert.Equal(t, 256, report.DKIM[0].Record.KeyBits)
+		assert.Empty(t, report.DKIM[0].Findings)
+		assert.Nil(t, report.DKIM[1].Record)
+		assert.True(t, hasFinding(report.DKIM[1].Findings, SeverityError, "no DKIM key found for selector missing"))
+	}
+
+	all := report.Findings()
+	assert.True(t, hasFinding(all, SeverityInfo, "include:_spf.example.net: ~all soft-fails"))
+	assert.True(t, hasFinding(all, SeverityError, "no DKIM key found"))
+}
+
+func TestMailCheckNoRecords(t *testing.T) {
+	checker := &MailChecker{Lookup: txtLookup(map[string][]string{})}
+	report := checker.Check("example.org")
+	assert.Nil(t, report.SPF.Record)
+	assert.Nil(t, report.DMARC.Record)
+	all := report.Findings()
+	assert.True(t, hasFinding(all, SeverityError, "no SPF record found for example.org"))
+	assert.True(t, hasFinding(all, SeverityError, "no DMARC record found at _dmarc.example.org"))
+
+	failing := MockHostLookup{
+		LookupTXTFunc: func(domain string) ([]string, error) {
+			return nil, &DNSError{RCode: RCodeServerFailure}
+		},
+	}
+	report = (&MailChecker{Lookup: failing}).Check("example.org")
+	assert.True(t, hasFinding(report.Findings(), SeverityError, "status: SERVFAIL"))
+}
@@ -0,0 +1,335 @@
+package network
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/textproto"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultSMTPPorts are the ports probed on each mail exchanger: SMTP relay,
+// submission over implicit TLS and submission with STARTTLS.
+var DefaultSMTPPorts = []int{25, 465, 587}
+
+// DefaultSMTPTimeout bounds each SMTP probe when MailChecker.Timeout is zero.
+const DefaultSMTPTimeout = 10 * time.Second
+
+// smtpsPort speaks TLS from the start instead of upgrading with STARTTLS.
+const smtpsPort = 465
+
+// MXResult is the mail exchangers of a domain and what they answered.
+type MXResult struct {
+	Domain string
+	// Hosts are sorted by preference, most preferred first.
+	Hosts    []*MXHost
+	Findings []MailFinding
+}
+
+// MXHost is one mail exchanger.
+type MXHost struct {
+	Host string
+	Pref uint16
+	// Implicit is set when the domain has no MX records and mail goes to
+	// the domain's own addresses (RFC 5321 section 5.1).
+	Implicit bool
+	Addrs    []string
+	Err      error
+	// Probes holds one probe per port of the first address.
+	Probes []*SMTPProbe
+}
+
+// SMTPProbe is the result of connecting to one port of a mail exchanger.
+type SMTPProbe struct {
+	Address string
+	Port    int
+	// ImplicitTLS is set for ports where TLS starts before the banner.
+	ImplicitTLS bool
+	Banner      string
+	Extensions  []string
+	STARTTLS    bool
+	TLS         *TLSInfo
+	RTT         time.Duration
+	Err         error
+}
+
+// TLSInfo describes the TLS session and certificate a server presented.
+type TLSInfo struct {
+	Version     string
+	CipherSuite string
+	Subject     string
+	Issuer      string
+	DNSNames    []string
+	NotBefore   time.Time
+	NotAfter    time.Time
+	// VerifyErr is why the certificate is not trusted for the host name,
+	// or nil when it is.
+	VerifyErr error
+}
+
+// ProbeMX looks up the mail exchangers of domain, resolves each one and
+// probes the SMTP ports of its first address through c.Dialer. The probes
+// in flight are abandoned once ctx is done.
+func (c *MailChecker) ProbeMX(ctx context.Context, domain string) *MXResult {
+	domain = strings.TrimSuffix(domain, ".")
+	result := &MXResult{Domain: domain}
+	var f findings
+	defer func() { result.Findings = f }()
+
+	mxs, err := c.Lookup.LookupMX(domain)
+	if err != nil && !isNotFound(err) {
+		f.add(SeverityError, "MX lookup for %s failed, status: %s", domain, ErrorStatus(err))
+		return result
+	}
+	if len(mxs) == 1 && (mxs[0].Host == "." || mxs[0].Host == "") {
+		f.add(SeverityInfo, "%s publishes a null MX and accepts no mail", domain)
+		return result
+	}
+	for _, mx := range mxs {
+		result.Hosts = append(result.Hosts, &MXHost{Host: strings.TrimSuffix(mx.Host, "."), Pref: mx.Pref})
+	}
+	if len(result.Hosts) == 0 {
+		f.add(SeverityWarning, "%s has no MX records; mail is delivered to its own addresses", domain)
+		result.Hosts = []*MXHost{{Host: domain, Implicit: true}}
+	}
+	sort.SliceStable(result.Hosts, func(i, j int) bool { return result.Hosts[i].Pref < result.Hosts[j].Pref })
+
+	var wg sync.WaitGroup
+	for _, host := range result.Hosts {
+		wg.Add(1)
+		go func(host *MXHost) {
+			defer wg.Done()
+			c.probeHost(ctx, host)
+		}(host)
+	}
+	wg.Wait()
+
+	reachable := false
+	for _, host := range result.Hosts {
+		if host.Err != nil {
+			f.add(SeverityError, "%s does not resolve, status: %s", host.Host, ErrorStatus(host.Err))
+			continue
+		}
+		for _, p := range host.Probes {
+			switch {
+			case p.Err != nil:
+				if p.Port == 25 {
+					f.add(SeverityWarning, "%s port 25: %v", host.Host, p.Err)
+				}
+				continue
+			case p.Port == 25:
+				reachable = true
+				if !p.STARTTLS {
+					f.add(SeverityWarning, "%s port 25 does not offer STARTTLS; mail is sent in the clear", host.Host)
+				}
+			}
+			if p.TLS != nil && p.TLS.VerifyErr != nil {
+				f.add(SeverityWarning, "%s port %d certificate is not trusted: %v", host.Host, p.Port, p.TLS.VerifyErr)
+			}
+		}
+	}
+	if !reachable && containsPort(c.smtpPorts(), 25) {
+		f.add(SeverityError, "no mail exchanger of %s accepted a connection on port 25", domain)
+	}
+	return result
+}
+
+// probeHost resolves host and probes each SMTP port of its first address.
+func (c *MailChecker) probeHost(ctx context.Context, host *MXHost) {
+	addrs, err := c.Lookup.LookupHost(host.Host)
+	if err == nil && len(addrs) == 0 {
+		err = fmt.Errorf("lookup %s: no addresses", host.Host)
+	}
+	if err != nil {
+		host.Err = err
+		return
+	}
+	host.Addrs = addrs
+
+	ports := c.smtpPorts()
+	host.Probes = make([]*SMTPProbe, len(ports))
+	var wg sync.WaitGroup
+	for i, port := range ports {
+		wg.Add(1)
+		go func(i, port int) {
+			defer wg.Done()
+			host.Probes[i] = c.probeSMTP(ctx, host.Host, addrs[0], port)
+		}(i, port)
+	}
+	wg.Wait()
+}
+
+func (c *MailChecker) smtpPorts() []int {
+	if len(c.SMTPPorts) == 0 {
+		return DefaultSMTPPorts
+	}
+	return c.SMTPPorts
+}
+
+// ehloName returns the name the probe introduces itself with: HeloName,
+// or this host's name, since many servers reject or penalise "localhost".
+func (c *MailChecker) ehloName() string {
+	if c.HeloName != "" {
+		return c.HeloName
+	}
+	if name, err := os.Hostname(); err == nil && name != "" {
+		return name
+	}
+	return "localhost"
+}
+
+func (c *MailChecker) dialer() Dialer {
+	if c.Dialer == nil {
+		return NetDialer{}
+	}
+	return c.Dialer
+}
+
+// probeSMTP connects to addr on port, reads the banner, sends EHLO and, when
+// the server offers it, upgrades the connection with STARTTLS. host is the
+// name the certificate is checked against. Once ctx is done the connection
+// is closed and the probe fails with ctx.Err().
+func (c *MailChecker) probeSMTP(ctx context.Context, host, addr string, port int) (probe *SMTPProbe) {
+	probe = &SMTPProbe{Address: net.JoinHostPort(addr, strconv.Itoa(port)), Port: port, ImplicitTLS: port == smtpsPort}
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = DefaultSMTPTimeout
+	}
+	defer func() {
+		if probe.Err != nil && ctx.Err() != nil {
+			probe.Err = ctx.Err()
+		}
+	}()
+
+	start := time.Now()
+	conn, err := c.dialer().Dial(ctx, "tcp", probe.Address, timeout)
+	if err != nil {
+		probe.Err = fmt.Errorf("error connecting: %v", err)
+		return probe
+	}
+	probe.RTT = time.Since(start)
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	if probe.ImplicitTLS {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: host, InsecureSkipVerify: true})
+		if err := tlsConn.Handshake(); err != nil {
+			probe.Err = fmt.Errorf("TLS handshake: %v", err)
+			return probe
+		}
+		probe.TLS = c.tlsInfo(host, tlsConn.ConnectionState())
+		conn = tlsConn
+	}
+
+	text := textproto.NewConn(conn)
+	_, probe.Banner, err = text.ReadResponse(220)
+	if err != nil {
+		probe.Err = fmt.Errorf("banner: %v", err)
+		return probe
+	}
+	if probe.Extensions, err = ehlo(text, c.ehloName()); err != nil {
+		probe.Err = err
+		return probe
+	}
+	for _, ext := range probe.Extensions {
+		if strings.EqualFold(ext, "STARTTLS") {
+			probe.STARTTLS = true
+		}
+	}
+
+	if probe.STARTTLS && !probe.ImplicitTLS {
+		if _, _, err := smtpCmd(text, 220, "STARTTLS"); err != nil {
+			probe.Err = err
+			return probe
+		}
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: host, InsecureSkipVerify: true})
+		if err := tlsConn.Handshake(); err != nil {
+			probe.Err = fmt.Errorf("STARTTLS handshake: %v", err)
+			return probe
+		}
+		probe.TLS = c.tlsInfo(host, tlsConn.ConnectionState())
+		text = textproto.NewConn(tlsConn)
+	}
+	smtpCmd(text, 221, "QUIT")
+	return probe
+}
+
+// ehlo sends EHLO with name and returns the extension keywords the server
+// lists after its greeting line.
+func ehlo(text *textproto.Conn, name string) ([]string, error) {
+	_, msg, err := smtpCmd(text, 250, "EHLO %s", name)
+	if err != nil {
+		return nil, err
+	}
+	var exts []string
+	for _, line := range strings.Split(msg, "\n")[1:] {
+		if keyword, _, _ := strings.Cut(line, " "); keyword != "" {
+			exts = append(exts, strings.ToUpper(keyword))
+		}
+	}
+	return exts, nil
+}
+
+// smtpCmd sends a command and reads a reply with the expected code.
+func smtpCmd(text *textproto.Conn, expectCode int, format string, args ...interface{}) (int, string, error) {
+	id, err := text.Cmd(format, args...)
+	if err != nil {
+		return 0, "", err
+	}
+	text.StartResponse(id)
+	defer text.EndResponse(id)
+	code, msg, err := text.ReadResponse(expectCode)
+	if err != nil {
+		return code, msg, fmt.Errorf("%s: %v", strings.Fields(format)[0], err)
+	}
+	return code, msg, nil
+}
+
+// tlsInfo summarises a TLS session and checks the leaf certificate against
+// the host name. The handshake itself skips verification so that untrusted
+// certificates can still be reported.
+func (c *MailChecker) tlsInfo(host string, state tls.ConnectionState) *TLSInfo {
+	info := &TLSInfo{
+		Version:     tls.VersionName(state.Version),
+		CipherSuite: tls.CipherSuiteName(state.CipherSuite),
+	}
+	if len(state.PeerCertificates) == 0 {
+		info.VerifyErr = fmt.Errorf("no certificate presented")
+		return info
+	}
+	leaf := state.PeerCertificates[0]
+	info.Subject = leaf.Subject.String()
+	info.Issuer = leaf.Issuer.String()
+	info.DNSNames = leaf.DNSNames
+	info.NotBefore = leaf.NotBefore
+	info.NotAfter = leaf.NotAfter
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range state.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, info.VerifyErr = leaf.Verify(x509.VerifyOptions{
+		DNSName:       host,
+		Roots:         c.RootCAs,
+		Intermediates: intermediates,
+	})
+	return info
+}
+
+func containsPort(ports []int, port int) bool {
+	for _, p := range ports {
+		if p == port {
+			return true
+		}
+	}
+	return false
+}
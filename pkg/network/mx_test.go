@@ -0,0 +1,239 @@
+package network
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testSMTPServer configures a fake SMTP listener.
+type testSMTPServer struct {
+	// config enables STARTTLS, or implicit TLS when implicit is set.
+	config   *tls.Config
+	implicit bool
+	// ehlo receives the name of each EHLO command when set.
+	ehlo chan string
+}
+
+// start listens on localhost and answers each connection with a banner,
+// EHLO, STARTTLS and QUIT replies.
+func (s testSMTPServer) start(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.implicit {
+		ln = tls.NewListener(ln, s.config)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func (s testSMTPServer) serve(conn net.Conn) {
+	defer func() { conn.Close() }()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	r := bufio.NewReader(conn)
+	fmt.Fprint(conn, "220 mx.example.com ESMTP test\r\n")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		switch verb := strings.ToUpper(strings.Fields(line)[0]); verb {
+		case "EHLO":
+			if s.ehlo != nil {
+				s.ehlo <- strings.TrimSpace(line[len(verb):])
+			}
+			fmt.Fprint(conn, "250-mx.example.com greets you\r\n250-PIPELINING\r\n250-SIZE 10240000\r\n")
+			if s.config != nil && !s.implicit {
+				fmt.Fprint(conn, "250-STARTTLS\r\n")
+			}
+			fmt.Fprint(conn, "250 8BITMIME\r\n")
+		case "STARTTLS":
+			fmt.Fprint(conn, "220 2.0.0 ready\r\n")
+			tlsConn := tls.Server(conn, s.config)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn = tlsConn
+			r = bufio.NewReader(conn)
+		case "QUIT":
+			fmt.Fprint(conn, "221 bye\r\n")
+			return
+		default:
+			fmt.Fprint(conn, "502 unknown command\r\n")
+		}
+	}
+}
+
+// portDialer sends dials for each port to a fake server address; other
+// ports are refused.
+func portDialer(addrs map[string]string) MockDialer {
+	return MockDialer{
+		DialFunc: func(ctx context.Context, network, address string, timeout time.Duration) (net.Conn, error) {
+			_, port, _ := net.SplitHostPort(address)
+			target, ok := addrs[port]
+			if !ok {
+				return nil, errors.New("connection refused")
+			}
+			return NetDialer{}.Dial(ctx, network, target, timeout)
+		},
+	}
+}
+
+func TestProbeMX(t *testing.T) {
+	cert, pool := testCertificate(t)
+	config := &tls.Config{Certificates: []tls.Certificate{cert}}
+	ehloNames := make(chan string, 1)
+	dialer := portDialer(map[string]string{
+		"25":  testSMTPServer{config: config, ehlo: ehloNames}.start(t),
+		"465": testSMTPServer{config: config, implicit: true}.start(t),
+		"587": testSMTPServer{}.start(t),
+	})
+
+	lookup := MockHostLookup{
+		LookupMXFunc: func(domain string) ([]*net.MX, error) {
+			assert.Equal(t, "example.com", domain)
+			return []*net.MX{{Host: "mx2.example.com.", Pref: 20}, {Host: "localhost.", Pref: 10}}, nil
+		},
+		LookupHostFunc: func(domain string) ([]string, error) {
+			if domain == "mx2.example.com" {
+				return nil, &net.DNSError{Err: "no such host", Name: domain, IsNotFound: true}
+			}
+			return []string{"192.0.2.25"}, nil
+		},
+	}
+	checker := &MailChecker{Lookup: lookup, Dialer: dialer, RootCAs: pool, Timeout: 5 * time.Second, HeloName: "probe.example.net"}
+	result := checker.ProbeMX(context.Background(), "example.com.")
+
+	if !assert.Len(t, result.Hosts, 2) {
+		return
+	}
+	assert.Equal(t, "mx2.example.com", result.Hosts[1].Host)
+	assert.Error(t, result.Hosts[1].Err)
+
+	mx := result.Hosts[0]
+	assert.Equal(t, "localhost", mx.Host)
+	assert.Equal(t, uint16(10), mx.Pref)
+	assert.Equal(t, []string{"192.0.2.25"}, mx.Addrs)
+	if !assert.Len(t, mx.Probes, 3) {
+		return
+	}
+
+	smtp := mx.Probes[0]
+	assert.NoError(t, smtp.Err)
+	assert.Equal(t, "probe.example.net", <-ehloNames)
+	assert.Equal(t, "192.0.2.25:25", smtp.Address)
+	assert.Equal(t, "mx.example.com ESMTP test", smtp.Banner)
+	assert.Equal(t, []string{"PIPELINING", "SIZE", "STARTTLS", "8BITMIME"}, smtp.Extensions)
+	assert.True(t, smtp.STARTTLS)
+	if assert.NotNil(t, smtp.TLS) {
+		assert.NoError(t, smtp.TLS.VerifyErr)
+		assert.Equal(t, "CN=net-tools test", smtp.TLS.Subject)
+		assert.Equal(t, []string{"localhost"}, smtp.TLS.DNSNames)
+		assert.Contains(t, smtp.TLS.Version, "TLS")
+	}
+
+	smtps := mx.Probes[1]
+	assert.NoError(t, smtps.Err)
+	assert.True(t, smtps.ImplicitTLS)
+	assert.False(t, smtps.STARTTLS)
+	assert.Equal(t, "mx.example.com ESMTP test", smtps.Banner)
+	if assert.NotNil(t, smtps.TLS) {
+		assert.NoError(t, smtps.TLS.VerifyErr)
+	}
+
+	submission := mx.Probes[2]
+	assert.NoError(t, submission.Err)
+	assert.False(t, submission.STARTTLS)
+	assert.Nil(t, submission.TLS)
+
+	assert.True(t, hasFinding(result.Findings, SeverityError, "mx2.example.com does not resolve, status: NXDOMAIN"))
+	assert.False(t, hasFinding(result.Findings, SeverityError, "accepted a connection on port 25"))
+}
+
+func TestProbeMXFindings(t *testing.T) {
+	cert, _ := testCertificate(t)
+	dialer := portDialer(map[string]string{
+		"25":  testSMTPServer{}.start(t),
+		"465": testSMTPServer{config: &tls.Config{Certificates: []tls.Certificate{cert}}, implicit: true}.start(t),
+	})
+	lookup := MockHostLookup{
+		LookupMXFunc: func(domain string) ([]*net.MX, error) {
+			return nil, &net.DNSError{Err: "no such host", Name: domain, IsNotFound: true}
+		},
+		LookupHostFunc: func(domain string) ([]string, error) { return []string{"192.0.2.25"}, nil },
+	}
+	checker := &MailChecker{Lookup: lookup, Dialer: dialer, SMTPPorts: []int{25, 465}}
+	result := checker.ProbeMX(context.Background(), "example.com")
+
+	if assert.Len(t, result.Hosts, 1) {
+		assert.True(t, result.Hosts[0].Implicit)
+		assert.Equal(t, "example.com", result.Hosts[0].Host)
+	}
+	assert.True(t, hasFinding(result.Findings, SeverityWarning, "has no MX records"))
+	assert.True(t, hasFinding(result.Findings, SeverityWarning, "port 25 does not offer STARTTLS"))
+	assert.True(t, hasFinding(result.Findings, SeverityWarning, "port 465 certificate is not trusted"))
+
+	checker = &MailChecker{Lookup: lookup, Dialer: portDialer(nil), SMTPPorts: []int{25}}
+	result = checker.ProbeMX(context.Background(), "example.com")
+	assert.True(t, hasFinding(result.Findings, SeverityError, "no mail exchanger of example.com accepted a connection on port 25"))
+
+	lookup.LookupMXFunc = func(domain string) ([]*net.MX, error) { return []*net.MX{{Host: ".", Pref: 0}}, nil }
+	result = (&MailChecker{Lookup: lookup}).ProbeMX(context.Background(), "example.com")
+	assert.Empty(t, result.Hosts)
+	assert.True(t, hasFinding(result.Findings, SeverityInfo, "null MX"))
+}
+
+func TestProbeMXCancel(t *testing.T) {
+	// The dialer only returns once the context is cancelled, as a dial to a
+	// mail exchanger whose packets are dropped would.
+	dialer := MockDialer{DialFunc: func(ctx context.Context, network, address string, timeout time.Duration) (net.Conn, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}}
+	lookup := MockHostLookup{
+		LookupMXFunc:   func(domain string) ([]*net.MX, error) { return []*net.MX{{Host: "mx.example.com.", Pref: 10}}, nil },
+		LookupHostFunc: func(domain string) ([]string, error) { return []string{"192.0.2.25"}, nil },
+	}
+	checker := &MailChecker{Lookup: lookup, Dialer: dialer, Timeout: time.Minute}
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	done := make(chan *MXResult, 1)
+	go func() { done <- checker.ProbeMX(ctx, "example.com") }()
+
+	select {
+	case result := <-done:
+		for _, p := range result.Hosts[0].Probes {
+			assert.ErrorIs(t, p.Err, context.Canceled)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("probes were not cancelled")
+	}
+}
+
+func TestEHLOName(t *testing.T) {
+	assert.Equal(t, "mail.example.net", (&MailChecker{HeloName: "mail.example.net"}).ehloName())
+	if hostname, err := os.Hostname(); err == nil {
+		assert.Equal(t, hostname, (&MailChecker{}).ehloName())
+	}
+}
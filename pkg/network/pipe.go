@@ -0,0 +1,73 @@
+package network
+
+import (
+	"context"
+	"io"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+// PipeStats counts the bytes Pipe moved in each direction.
+type PipeStats struct {
+	Sent     int64
+	Received int64
+}
+
+// closeWriter is implemented by connections that support half-close, such
+// as *net.TCPConn and *net.UnixConn.
+type closeWriter interface {
+	CloseWrite() error
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n atomic.Int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n.Add(int64(n))
+	return n, err
+}
+
+// NetcatInteractive connects to host and port over TCP, giving up on the
+// connection after timeout, DefaultDialTimeout when zero, and pipes in to
+// the connection and the connection to out, like netcat.
+func NetcatInteractive(ctx context.Context, host, port string, timeout time.Duration, in io.Reader, out io.Writer) (PipeStats, error) {
+	return netcatPipe(ctx, NetDialer{}, host, port, timeout, in, out)
+}
+
+func netcatPipe(ctx context.Context, dialer Dialer, host, port string, timeout time.Duration, in io.Reader, out io.Writer) (PipeStats, error) {
+	conn, err := dialer.Dial(ctx, "tcp", net.JoinHostPort(host, port), dialTimeout(timeout))
+	if err != nil {
+		return PipeStats{}, err
+	}
+	return Pipe(ctx, conn, in, out)
+}
+
+// Pipe copies in to conn and conn to out until the peer closes the
+// connection or ctx is done, then closes conn. When in reaches EOF the
+// write side of conn is shut down, if it supports half-close, so the peer
+// sees the end of the input while its replies still arrive.
+func Pipe(ctx context.Context, conn net.Conn, in io.Reader, out io.Writer) (PipeStats, error) {
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	sent := &countingWriter{w: conn}
+	go func() {
+		if _, err := io.Copy(sent, in); err == nil {
+			if cw, ok := conn.(closeWriter); ok {
+				cw.CloseWrite()
+			}
+		}
+	}()
+
+	received, err := io.Copy(out, conn)
+	if ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	return PipeStats{Sent: sent.n.Load(), Received: received}, err
+}
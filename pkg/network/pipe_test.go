@@ -0,0 +1,90 @@
+package network
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// startTCPServer accepts one connection on a loopback port and hands it to
+// serve, returning the address.
+func startTCPServer(t *testing.T, serve func(conn net.Conn)) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		serve(conn)
+	}()
+	return ln.Addr().String()
+}
+
+func TestNetcatInteractive(t *testing.T) {
+	// The server only answers once it sees the end of the input, which
+	// needs the client to half-close the connection.
+	addr := startTCPServer(t, func(conn net.Conn) {
+		data, _ := io.ReadAll(conn)
+		fmt.Fprintf(conn, "+OK %d bytes: %s", len(data), strings.ToUpper(string(data)))
+	})
+	host, port, _ := net.SplitHostPort(addr)
+
+	var out bytes.Buffer
+	stats, err := NetcatInteractive(context.Background(), host, port, time.Second, strings.NewReader("ping\r\n"), &out)
+	assert.NoError(t, err)
+	assert.Equal(t, "+OK 6 bytes: PING\r\n", out.String())
+	assert.Equal(t, PipeStats{Sent: 6, Received: int64(out.Len())}, stats)
+}
+
+func TestPipePeerClose(t *testing.T) {
+	// The peer closing ends the pipe even while the input stays open.
+	addr := startTCPServer(t, func(conn net.Conn) {
+		conn.Write([]byte("220 ready\r\n"))
+	})
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	in, _ := io.Pipe()
+	var out bytes.Buffer
+	stats, err := Pipe(context.Background(), conn, in, &out)
+	assert.NoError(t, err)
+	assert.Equal(t, "220 ready\r\n", out.String())
+	assert.Equal(t, int64(11), stats.Received)
+}
+
+func TestPipeCancel(t *testing.T) {
+	addr := startTCPServer(t, func(conn net.Conn) {
+		io.Copy(io.Discard, conn)
+	})
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	in, _ := io.Pipe()
+	_, err = Pipe(ctx, conn, in, io.Discard)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestNetcatInteractiveDialError(t *testing.T) {
+	dialer := MockDialer{DialFunc: func(ctx context.Context, network, address string, timeout time.Duration) (net.Conn, error) {
+		return nil, fmt.Errorf("connection refused")
+	}}
+	_, err := netcatPipe(context.Background(), dialer, "localhost", "6379", time.Second, strings.NewReader(""), io.Discard)
+	assert.ErrorContains(t, err, "refused")
+}
@@ -1,27 +1,45 @@
 package network
 
 import (
+	"context"
 	"fmt"
 	"net"
+	"time"
 )
 
+// DefaultDialTimeout bounds a connection attempt of Netcat, its UDP and
+// interactive variants and PortScanner when they are given a zero timeout.
+const DefaultDialTimeout = 5 * time.Second
+
+// dialTimeout returns timeout, or DefaultDialTimeout when it is zero.
+func dialTimeout(timeout time.Duration) time.Duration {
+	if timeout == 0 {
+		return DefaultDialTimeout
+	}
+	return timeout
+}
+
 // Dialer is an interface that defines the Dial method.
 type Dialer interface {
-	Dial(network, address string) (net.Conn, error)
+	Dial(ctx context.Context, network, address string, timeout time.Duration) (net.Conn, error)
 }
 
 // NetDialer is a concrete implementation of Dialer using the net package.
 type NetDialer struct{}
 
-// Dial connects to the address on the named network.
-func (d NetDialer) Dial(network, address string) (net.Conn, error) {
-	return net.Dial(network, address)
+// Dial connects to the address on the named network, giving up when ctx is
+// done or after timeout. A zero timeout leaves only the operating system's
+// limit.
+func (d NetDialer) Dial(ctx context.Context, network, address string, timeout time.Duration) (net.Conn, error) {
+	dialer := net.Dialer{Timeout: timeout}
+	return dialer.DialContext(ctx, network, address)
 }
 
-// Netcat connects to the specified host and port using the provided Dialer.
-func Netcat(host, port string) error {
+// Netcat connects to the specified host and port, giving up when ctx is
+// done or after timeout, DefaultDialTimeout when zero.
+func Netcat(ctx context.Context, host, port string, timeout time.Duration) error {
 	d := NetDialer{}
-	err := netcatDialer(d, host, port)
+	err := netcatDialer(ctx, d, host, port, timeout)
 	if err != nil {
 		return err
 	}
@@ -29,10 +47,10 @@ func Netcat(host, port string) error {
 	return nil
 }
 
-func netcatDialer(dialer Dialer, host, port string) error {
-	conn, err := dialer.Dial("tcp", net.JoinHostPort(host, port))
+func netcatDialer(ctx context.Context, dialer Dialer, host, port string, timeout time.Duration) error {
+	conn, err := dialer.Dial(ctx, "tcp", net.JoinHostPort(host, port), dialTimeout(timeout))
 	if err != nil {
-		return fmt.Errorf("error connecting: %v", err)
+		return fmt.Errorf("error connecting: %w", err)
 	}
 	defer conn.Close()
 
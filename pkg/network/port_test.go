@@ -1,6 +1,7 @@
 package network
 
 import (
+	"context"
 	"errors"
 	"net"
 	"testing"
@@ -11,11 +12,11 @@ import (
 
 // MockDialer is a mock implementation of the Dialer interface.
 type MockDialer struct {
-	DialFunc func(network, address string) (net.Conn, error)
+	DialFunc func(ctx context.Context, network, address string, timeout time.Duration) (net.Conn, error)
 }
 
-func (m MockDialer) Dial(network, address string) (net.Conn, error) {
-	return m.DialFunc(network, address)
+func (m MockDialer) Dial(ctx context.Context, network, address string, timeout time.Duration) (net.Conn, error) {
+	return m.DialFunc(ctx, network, address, timeout)
 }
 
 // MockConn is a mock implementation of the net.Conn interface.
@@ -35,16 +36,17 @@ func TestNetcat(t *testing.T) {
 		name      string
 		host      string
 		port      string
-		dialFunc  func(network, address string) (net.Conn, error)
+		dialFunc  func(ctx context.Context, network, address string, timeout time.Duration) (net.Conn, error)
 		expectErr bool
 	}{
 		{
 			name: "successful connection",
 			host: "localhost",
 			port: "8080",
-			dialFunc: func(network, address string) (net.Conn, error) {
+			dialFunc: func(ctx context.Context, network, address string, timeout time.Duration) (net.Conn, error) {
 				assert.Equal(t, "tcp", network)
 				assert.Equal(t, "localhost:8080", address)
+				assert.Equal(t, time.Second, timeout)
 				return MockConn{}, nil
 			},
 			expectErr: false,
@@ -53,7 +55,7 @@ func TestNetcat(t *testing.T) {
 			name: "connection error",
 			host: "localhost",
 			port: "8080",
-			dialFunc: func(network, address string) (net.Conn, error) {
+			dialFunc: func(ctx context.Context, network, address string, timeout time.Duration) (net.Conn, error) {
 				return nil, errors.New("connection refused")
 			},
 			expectErr: true,
@@ -66,7 +68,7 @@ func TestNetcat(t *testing.T) {
 				DialFunc: tt.dialFunc,
 			}
 
-			err := netcatDialer(mockDialer, tt.host, tt.port)
+			err := netcatDialer(context.Background(), mockDialer, tt.host, tt.port, time.Second)
 			if tt.expectErr {
 				assert.Error(t, err)
 			} else {
@@ -75,3 +77,34 @@ func TestNetcat(t *testing.T) {
 		})
 	}
 }
+
+func TestNetcatDefaultTimeout(t *testing.T) {
+	var timeouts []time.Duration
+	dialer := MockDialer{DialFunc: func(ctx context.Context, network, address string, timeout time.Duration) (net.Conn, error) {
+		timeouts = append(timeouts, timeout)
+		return MockConn{}, nil
+	}}
+	assert.NoError(t, netcatDialer(context.Background(), dialer, "localhost", "80", 0))
+	(&PortScanner{Dialer: dialer, Workers: 1}).Scan(context.Background(), "localhost", []int{80})
+	assert.Equal(t, []time.Duration{DefaultDialTimeout, DefaultDialTimeout}, timeouts)
+}
+
+func TestNetcatCancel(t *testing.T) {
+	// The dialer only returns once the context is cancelled, as a dial to a
+	// port whose packets are dropped would.
+	dialer := MockDialer{DialFunc: func(ctx context.Context, network, address string, timeout time.Duration) (net.Conn, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}}
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	done := make(chan error, 1)
+	go func() { done <- netcatDialer(ctx, dialer, "192.0.2.1", "9", time.Minute) }()
+
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, context.Canceled)
+	case <-time.After(5 * time.Second):
+		t.Fatal("dial was not cancelled")
+	}
+}
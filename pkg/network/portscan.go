@@ -0,0 +1,169 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// DefaultScanWorkers is the number of ports PortScanner probes at once.
+const DefaultScanWorkers = 100
+
+// PortState is the outcome of probing a port.
+type PortState int
+
+const (
+	// PortOpen accepted a connection.
+	PortOpen PortState = iota
+	// PortClosed refused the connection.
+	PortClosed
+	// PortFiltered did not answer, or answered with an error other than a
+	// refusal, as when a firewall drops the packets.
+	PortFiltered
+	// PortOpenFiltered is a UDP port that did not reply, which may be open
+	// to a service that ignored the probe or filtered.
+	PortOpenFiltered
+)
+
+func (s PortState) String() string {
+	switch s {
+	case PortOpen:
+		return "open"
+	case PortClosed:
+		return "closed"
+	case PortOpenFiltered:
+		return "open|filtered"
+	}
+	return "filtered"
+}
+
+// PortResult is the outcome of probing one port.
+type PortResult struct {
+	Port  int
+	State PortState
+	// Err is the dial error of a closed or filtered port.
+	Err error
+	RTT time.Duration
+}
+
+// ParsePorts parses a comma separated list of ports and ranges, such as
+// "20-25,80,443", returning the ports sorted without duplicates.
+func ParsePorts(s string) ([]int, error) {
+	seen := make(map[int]bool)
+	var ports []int
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		first, last, isRange := strings.Cut(item, "-")
+		lo, err := parsePort(first)
+		if err != nil {
+			return nil, err
+		}
+		hi := lo
+		if isRange {
+			if hi, err = parsePort(last); err != nil {
+				return nil, err
+			}
+			if hi < lo {
+				return nil, fmt.Errorf("invalid port range %s", item)
+			}
+		}
+		for port := lo; port <= hi; port++ {
+			if !seen[port] {
+				seen[port] = true
+				ports = append(ports, port)
+			}
+		}
+	}
+	sort.Ints(ports)
+	return ports, nil
+}
+
+func parsePort(s string) (int, error) {
+	port, err := strconv.Atoi(s)
+	if err != nil || port < 1 || port > 65535 {
+		return 0, fmt.Errorf("invalid port %q", s)
+	}
+	return port, nil
+}
+
+// PortScanner probes TCP ports concurrently.
+type PortScanner struct {
+	// Dialer connects to the ports; nil uses NetDialer.
+	Dialer Dialer
+	// Workers is the number of probes in flight, DefaultScanWorkers when
+	// zero.
+	Workers int
+	// Timeout bounds each probe, DefaultDialTimeout when zero.
+	Timeout time.Duration
+}
+
+// Scan probes each port of host, at most Workers at a time, and returns
+// the results in the order of ports. Once ctx is done the dials in flight
+// are abandoned and the ports not yet probed are left filtered with
+// ctx.Err().
+func (s *PortScanner) Scan(ctx context.Context, host string, ports []int) []PortResult {
+	workers := s.Workers
+	if workers <= 0 {
+		workers = DefaultScanWorkers
+	}
+	timeout := dialTimeout(s.Timeout)
+	dialer := s.Dialer
+	if dialer == nil {
+		dialer = NetDialer{}
+	}
+
+	results := make([]PortResult, len(ports))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(workers, len(ports)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				probePort(ctx, dialer, host, timeout, &results[i])
+			}
+		}()
+	}
+	for i, port := range ports {
+		results[i].Port = port
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	return results
+}
+
+// probePort dials the result's port and records its state, or ctx.Err()
+// once ctx is done.
+func probePort(ctx context.Context, dialer Dialer, host string, timeout time.Duration, result *PortResult) {
+	if err := ctx.Err(); err != nil {
+		result.State, result.Err = PortFiltered, err
+		return
+	}
+	start := time.Now()
+	conn, err := dialer.Dial(ctx, "tcp", net.JoinHostPort(host, strconv.Itoa(result.Port)), timeout)
+	result.RTT = time.Since(start)
+	if err == nil {
+		conn.Close()
+	}
+	result.State, result.Err = portState(err), err
+}
+
+// portState classifies a dial error: a refusal means the host answered for
+// a closed port, anything else that nothing answered.
+func portState(err error) PortState {
+	switch {
+	case err == nil:
+		return PortOpen
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return PortClosed
+	}
+	return PortFiltered
+}
@@ -0,0 +1,120 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"runtime"
+	"strconv"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePorts(t *testing.T) {
+	ports, err := ParsePorts("443,20-25, 80,22,8000-8002")
+	assert.NoError(t, err)
+	assert.Equal(t, []int{20, 21, 22, 23, 24, 25, 80, 443, 8000, 8001, 8002}, ports)
+
+	ports, err = ParsePorts("65535")
+	assert.NoError(t, err)
+	assert.Equal(t, []int{65535}, ports)
+
+	for _, s := range []string{"", "0", "65536", "http", "25-20", "20-", "1,,2", "-5"} {
+		_, err := ParsePorts(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestPortScanner(t *testing.T) {
+	var inFlight, peak, peakGoroutines atomic.Int32
+	goroutines := runtime.NumGoroutine()
+	dialer := MockDialer{DialFunc: func(ctx context.Context, network, address string, timeout time.Duration) (net.Conn, error) {
+		if g := int32(runtime.NumGoroutine()); g > peakGoroutines.Load() {
+			peakGoroutines.Store(g)
+		}
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+		}
+		time.Sleep(5 * time.Millisecond)
+
+		assert.Equal(t, "tcp", network)
+		assert.Equal(t, DefaultDialTimeout, timeout)
+		host, port, _ := net.SplitHostPort(address)
+		assert.Equal(t, "192.0.2.1", host)
+		switch port {
+		case "22", "443":
+			return MockConn{}, nil
+		case "80":
+			return nil, &net.OpError{Op: "dial", Net: network, Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}
+		case "81":
+			return nil, &net.OpError{Op: "dial", Net: network, Err: os.NewSyscallError("connect", syscall.EHOSTUNREACH)}
+		}
+		return nil, errors.New("i/o timeout")
+	}}
+
+	var ports []int
+	for port := 1; port <= 500; port++ {
+		ports = append(ports, port)
+	}
+	scanner := &PortScanner{Dialer: dialer, Workers: 20}
+	results := scanner.Scan(context.Background(), "192.0.2.1", ports)
+	if !assert.Len(t, results, 500) {
+		return
+	}
+	assert.LessOrEqual(t, peak.Load(), int32(20))
+	// A fixed pool of workers, not a goroutine per port.
+	assert.LessOrEqual(t, int(peakGoroutines.Load()), goroutines+20)
+	states := make(map[PortState][]int)
+	for i, r := range results {
+		assert.Equal(t, ports[i], r.Port)
+		states[r.State] = append(states[r.State], r.Port)
+	}
+	assert.Equal(t, []int{22, 443}, states[PortOpen])
+	assert.Equal(t, []int{80}, states[PortClosed])
+	assert.Len(t, states[PortFiltered], 497)
+	assert.Error(t, results[80].Err)
+}
+
+func TestPortScannerLoopback(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	open := ln.Addr().(*net.TCPAddr).Port
+	// A port that was just released is refused.
+	closedLn, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := closedLn.Addr().(*net.TCPAddr).Port
+	closedLn.Close()
+	defer ln.Close()
+
+	results := (&PortScanner{Timeout: time.Second}).Scan(context.Background(), "127.0.0.1", []int{open, closed})
+	assert.Equal(t, PortOpen, results[0].State, strconv.Itoa(open))
+	assert.Equal(t, PortClosed, results[1].State, strconv.Itoa(closed))
+}
+
+func TestPortScannerCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var dials atomic.Int32
+	dialer := MockDialer{DialFunc: func(ctx context.Context, network, address string, timeout time.Duration) (net.Conn, error) {
+		if dials.Add(1) == 2 {
+			cancel()
+		}
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}}
+	results := (&PortScanner{Dialer: dialer, Workers: 2}).Scan(ctx, "192.0.2.1", []int{1, 2, 3, 4, 5, 6})
+	assert.Equal(t, int32(2), dials.Load())
+	for _, r := range results {
+		assert.Equal(t, PortFiltered, r.State)
+		assert.ErrorIs(t, r.Err, context.Canceled)
+	}
+}
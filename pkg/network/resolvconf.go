@@ -0,0 +1,111 @@
+package network
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxNameservers is the number of nameservers the system resolver uses;
+// glibc ignores any listed after the third.
+const MaxNameservers = 3
+
+// ResolvConf is the stub resolver configuration read from resolv.conf(5).
+type ResolvConf struct {
+	Nameservers []string
+	// Search is the search list, from the last search or domain line.
+	Search   []string
+	Ndots    int
+	Timeout  time.Duration
+	Attempts int
+	Rotate   bool
+	// Options holds every option given, including those not decoded above.
+	Options []string
+}
+
+// LoadResolvConf reads the resolver configuration at path.
+func LoadResolvConf(path string) (*ResolvConf, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ParseResolvConf(file)
+}
+
+// ParseResolvConf parses a resolv.conf file. Like the system resolver it
+// ignores lines and option values it does not understand, and applies the
+// default ndots, timeout and attempts when they are not set.
+func ParseResolvConf(r io.Reader) (*ResolvConf, error) {
+	conf := &ResolvConf{Ndots: 1, Timeout: 5 * time.Second, Attempts: 2}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexAny(line, "#;"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[0] {
+		case "nameserver":
+			conf.Nameservers = append(conf.Nameservers, fields[1])
+		case "domain":
+			conf.Search = []string{Fqdn(fields[1])}
+		case "search":
+			conf.Search = nil
+			for _, domain := range fields[1:] {
+				conf.Search = append(conf.Search, Fqdn(domain))
+			}
+		case "options":
+			for _, option := range fields[1:] {
+				conf.option(option)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return conf, nil
+}
+
+// option applies one word of an options line, capping the values at the
+// limits glibc enforces.
+func (c *ResolvConf) option(option string) {
+	c.Options = append(c.Options, option)
+	name, value, _ := strings.Cut(option, ":")
+	n, err := strconv.Atoi(value)
+	switch {
+	case name == "rotate":
+		c.Rotate = true
+	case err != nil || n < 0:
+	case name == "ndots":
+		c.Ndots = min(n, 15)
+	case name == "timeout":
+		c.Timeout = time.Duration(min(n, 30)) * time.Second
+	case name == "attempts":
+		c.Attempts = min(n, 5)
+	}
+}
+
+// Candidates returns the fully qualified names the system resolver would
+// query for name, in order. A name ending in a dot is queried as is. A
+// name with at least ndots dots is tried as is before the search list is
+// applied, and any other name only after.
+func (c *ResolvConf) Candidates(name string) []string {
+	if strings.HasSuffix(name, ".") {
+		return []string{name}
+	}
+	var candidates []string
+	for _, domain := range c.Search {
+		candidates = append(candidates, name+"."+domain)
+	}
+	if strings.Count(name, ".") >= c.Ndots {
+		return append([]string{name + "."}, candidates...)
+	}
+	return append(candidates, name+".")
+}
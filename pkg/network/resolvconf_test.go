@@ -0,0 +1,58 @@
+package network
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseResolvConf(t *testing.T) {
+	conf, err := LoadResolvConf("testdata/resolv.conf")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []string{"10.0.0.2", "10.0.0.3", "2001:db8::53", "10.0.0.5"}, conf.Nameservers)
+	assert.Equal(t, []string{"corp.example.com.", "example.com."}, conf.Search)
+	assert.Equal(t, 2, conf.Ndots)
+	assert.Equal(t, 3*time.Second, conf.Timeout)
+	assert.Equal(t, 5, conf.Attempts)
+	assert.True(t, conf.Rotate)
+	assert.Equal(t, []string{"ndots:2", "timeout:3", "attempts:9", "rotate", "edns0", "ndots:x"}, conf.Options)
+}
+
+func TestParseResolvConfDefaults(t *testing.T) {
+	conf, err := ParseResolvConf(strings.NewReader("search a.example\ndomain b.example\n"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Empty(t, conf.Nameservers)
+	assert.Equal(t, []string{"b.example."}, conf.Search)
+	assert.Equal(t, 1, conf.Ndots)
+	assert.Equal(t, 5*time.Second, conf.Timeout)
+	assert.Equal(t, 2, conf.Attempts)
+	assert.False(t, conf.Rotate)
+
+	_, err = LoadResolvConf("testdata/missing.conf")
+	assert.Error(t, err)
+}
+
+func TestResolvConfCandidates(t *testing.T) {
+	conf := &ResolvConf{Search: []string{"corp.example.com.", "example.com."}, Ndots: 2}
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{"www", []string{"www.corp.example.com.", "www.example.com.", "www."}},
+		{"www.eu", []string{"www.eu.corp.example.com.", "www.eu.example.com.", "www.eu."}},
+		{"www.eu.example", []string{"www.eu.example.", "www.eu.example.corp.example.com.", "www.eu.example.example.com."}},
+		{"www.example.com.", []string{"www.example.com."}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, conf.Candidates(tt.name))
+		})
+	}
+	assert.Equal(t, []string{"www."}, (&ResolvConf{Ndots: 1}).Candidates("www"))
+}
@@ -0,0 +1,372 @@
+package network
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// SPFLookupLimit is the number of DNS querying terms an SPF evaluation may
+// use before receivers return permerror (RFC 7208 section 4.6.4).
+const SPFLookupLimit = 10
+
+// maxSPFDepth stops the expansion of include chains that never end.
+const maxSPFDepth = 12
+
+var (
+	spfMechanisms = []string{"all", "include", "a", "mx", "ptr", "ip4", "ip6", "exists"}
+	spfModifiers  = []string{"redirect", "exp"}
+)
+
+// SPFTerm is one mechanism or modifier of an SPF record.
+type SPFTerm struct {
+	// Qualifier is '+', '-', '~' or '?' for mechanisms and 0 for modifiers.
+	Qualifier byte
+	// Name is the lowercased mechanism or modifier name.
+	Name string
+	// Value is the text after the ':' or '=', or the CIDR length of a bare
+	// a or mx mechanism such as "/24".
+	Value string
+}
+
+// Modifier reports whether the term is a name=value modifier.
+func (t SPFTerm) Modifier() bool { return t.Qualifier == 0 }
+
+func (t SPFTerm) String() string {
+	switch {
+	case t.Modifier():
+		return t.Name + "=" + t.Value
+	case t.Value == "", strings.HasPrefix(t.Value, "/"):
+		return string(t.Qualifier) + t.Name + t.Value
+	}
+	return string(t.Qualifier) + t.Name + ":" + t.Value
+}
+
+// lookups reports whether evaluating the term queries the DNS.
+func (t SPFTerm) lookups() bool {
+	switch t.Name {
+	case "include", "a", "mx", "ptr", "exists", "redirect":
+		return true
+	}
+	return false
+}
+
+// target returns the domain an include or redirect term points to.
+func (t SPFTerm) target() string {
+	if t.Name == "include" || t.Name == "redirect" {
+		return t.Value
+	}
+	return ""
+}
+
+// SPFRecord is a parsed SPF record.
+type SPFRecord struct {
+	Raw   string
+	Terms []SPFTerm
+}
+
+// All returns the all mechanism, or nil when the record has none.
+func (r *SPFRecord) All() *SPFTerm {
+	for i := range r.Terms {
+		if r.Terms[i].Name == "all" && !r.Terms[i].Modifier() {
+			return &r.Terms[i]
+		}
+	}
+	return nil
+}
+
+// ParseSPF parses an SPF record, reporting syntax errors and risky settings.
+// Terms that cannot be parsed are left out of the record.
+func ParseSPF(txt string) (*SPFRecord, []MailFinding) {
+	var f findings
+	record := &SPFRecord{Raw: txt}
+	fields := strings.Fields(txt)
+	if len(fields) == 0 || !strings.EqualFold(fields[0], "v=spf1") {
+		f.add(SeverityError, "record does not start with v=spf1")
+		return record, f
+	}
+
+	modifiers := make(map[string]bool)
+	for _, field := range fields[1:] {
+		term, err := parseSPFTerm(field)
+		if err != nil {
+			f.add(SeverityError, "%v", err)
+			continue
+		}
+		if term.Modifier() {
+			if modifiers[term.Name] {
+				f.add(SeverityError, "%s= appears more than once", term.Name)
+			}
+			modifiers[term.Name] = true
+		}
+		record.Terms = append(record.Terms, term)
+	}
+
+	all := record.All()
+	afterAll := false
+	for _, term := range record.Terms {
+		if afterAll && !term.Modifier() {
+			f.add(SeverityWarning, "%s comes after all and is never evaluated", term)
+		}
+		afterAll = afterAll || term.Name == "all" && !term.Modifier()
+		switch {
+		case term.Name == "ptr":
+			f.add(SeverityWarning, "the ptr mechanism is slow and deprecated (RFC 7208 section 5.5)")
+		case term.Name == "ip4" || term.Name == "ip6":
+			if _, ipnet, err := net.ParseCIDR(term.Value); err == nil {
+				ones, bits := ipnet.Mask.Size()
+				if bits == 32 && ones < 16 || bits == 128 && ones < 32 {
+					f.add(SeverityWarning, "%s authorises a very large address range", term)
+				}
+			}
+		case strings.Contains(term.Value, "%"):
+			f.add(SeverityInfo, "%s uses macros, which are not expanded here", term)
+		}
+	}
+
+	switch {
+	case all == nil && !modifiers["redirect"]:
+		f.add(SeverityWarning, "no all mechanism or redirect; unmatched senders get the neutral result")
+	case all != nil && modifiers["redirect"]:
+		f.add(SeverityWarning, "redirect= is ignored because the record has an all mechanism")
+	}
+	if all != nil {
+		switch all.Qualifier {
+		case '+':
+			f.add(SeverityError, "+all authorises every host on the internet to send as this domain")
+		case '?':
+			f.add(SeverityWarning, "?all gives unmatched senders the neutral result, which protects nothing")
+		case '~':
+			f.add(SeverityInfo, "~all soft-fails unmatched senders; -all is stricter")
+		}
+	}
+	return record, f
+}
+
+// parseSPFTerm parses one space separated field of an SPF record.
+func parseSPFTerm(field string) (SPFTerm, error) {
+	if name, value, ok := strings.Cut(field, "="); ok && isSPFName(name) {
+		name = strings.ToLower(name)
+		term := SPFTerm{Name: name, Value: value}
+		switch {
+		case name == "redirect" && value == "":
+			return term, fmt.Errorf("redirect= needs a domain")
+		case name != "redirect" && name != "exp":
+			if suggestion := closestWord(name, spfModifiers); suggestion != "" {
+				return term, fmt.Errorf("unknown modifier %q, did you mean %s=?", name, suggestion)
+			}
+		}
+		return term, nil
+	}
+
+	term := SPFTerm{Qualifier: '+'}
+	if strings.ContainsRune("+-~?", rune(field[0])) {
+		term.Qualifier, field = field[0], field[1:]
+	}
+	name, value := field, ""
+	if i := strings.IndexAny(field, ":/"); i >= 0 {
+		name, value = field[:i], field[i:]
+		value = strings.TrimPrefix(value, ":")
+	}
+	term.Name, term.Value = strings.ToLower(name), value
+
+	switch term.Name {
+	case "all":
+		if value != "" {
+			return term, fmt.Errorf("all takes no argument: %s", field)
+		}
+	case "include", "exists":
+		if value == "" {
+			return term, fmt.Errorf("%s needs a domain", term.Name)
+		}
+	case "a", "mx", "ptr":
+	case "ip4", "ip6":
+		if err := checkSPFNetwork(term.Name, value); err != nil {
+			return term, err
+		}
+	default:
+		if suggestion := closestWord(term.Name, spfMechanisms); suggestion != "" {
+			return term, fmt.Errorf("unknown mechanism %q, did you mean %s?", name, suggestion)
+		}
+		return term, fmt.Errorf("unknown mechanism %q", name)
+	}
+	if (term.Name == "a" || term.Name == "mx") && strings.Contains(value, "/") {
+		if err := checkDualCIDR(value[strings.Index(value, "/"):]); err != nil {
+			return term, fmt.Errorf("%s: %v", field, err)
+		}
+	}
+	return term, nil
+}
+
+// isSPFName reports whether s is a valid modifier name.
+func isSPFName(s string) bool {
+	if s == "" || !('a' <= s[0]|0x20 && s[0]|0x20 <= 'z') {
+		return false
+	}
+	for i := 1; i < len(s); i++ {
+		c := s[i] | 0x20
+		if !('a' <= c && c <= 'z' || isDigit(s[i]) || s[i] == '-' || s[i] == '_' || s[i] == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+// checkSPFNetwork validates the address of an ip4 or ip6 mechanism.
+func checkSPFNetwork(mech, value string) error {
+	addr, prefix, hasPrefix := strings.Cut(value, "/")
+	ip := net.ParseIP(addr)
+	isV4 := ip != nil && ip.To4() != nil && !strings.Contains(addr, ":")
+	if ip == nil || (mech == "ip4") != isV4 {
+		return fmt.Errorf("%s:%s is not a valid %s address", mech, value, mech)
+	}
+	if hasPrefix {
+		bits := 32
+		if mech == "ip6" {
+			bits = 128
+		}
+		n, err := strconv.Atoi(prefix)
+		if err != nil || n < 0 || n > bits {
+			return fmt.Errorf("%s:%s has an invalid prefix length", mech, value)
+		}
+	}
+	return nil
+}
+
+// checkDualCIDR validates the "/24", "/24//64" or "//64" suffix of an a or
+// mx mechanism.
+func checkDualCIDR(s string) error {
+	var v4, v6 string
+	if rest, ok := strings.CutPrefix(s, "//"); ok {
+		v6 = rest
+	} else {
+		v4, v6, _ = strings.Cut(strings.TrimPrefix(s, "/"), "//")
+	}
+	for _, c := range []struct {
+		s   string
+		max int
+	}{{v4, 32}, {v6, 128}} {
+		if c.s == "" {
+			continue
+		}
+		if n, err := strconv.Atoi(c.s); err != nil || n < 0 || n > c.max {
+			return fmt.Errorf("invalid CIDR length %q", c.s)
+		}
+	}
+	return nil
+}
+
+// SPFResult is an SPF record with its include and redirect targets
+// expanded.
+type SPFResult struct {
+	Domain string
+	// Via is "include" or "redirect" for expanded targets, empty at the top.
+	Via    string
+	Record *SPFRecord
+	// Includes holds the expanded include and redirect targets in order.
+	Includes []*SPFResult
+	// Lookups counts the DNS querying terms of this record and of every
+	// record it includes.
+	Lookups  int
+	Findings []MailFinding
+}
+
+// AllFindings returns the findings of the record and its includes, the
+// latter prefixed with the included domain.
+func (r *SPFResult) AllFindings() []MailFinding {
+	all := append([]MailFinding(nil), r.Findings...)
+	for _, inc := range r.Includes {
+		for _, f := range inc.AllFindings() {
+			f.Message = fmt.Sprintf("%s:%s: %s", inc.Via, inc.Domain, f.Message)
+			all = append(all, f)
+		}
+	}
+	return all
+}
+
+// ExpandSPF fetches the SPF record of domain and, recursively, of every
+// include and redirect target. The DNS querying terms are counted across
+// the whole expansion, and once they pass SPFLookupLimit nothing more is
+// queried, as receivers give up with permerror at that point.
+func ExpandSPF(lookup HostLookup, domain string) *SPFResult {
+	e := &spfExpander{lookup: lookup, visiting: map[string]bool{}}
+	result := e.expand(domain, "", 0)
+	if e.exceededAt != "" {
+		var f findings
+		f.add(SeverityError, "SPF evaluation passes the limit of %d DNS lookups at %s; receivers will return permerror, and the rest of the record was not expanded", SPFLookupLimit, e.exceededAt)
+		result.Findings = append(f, result.Findings...)
+	}
+	return result
+}
+
+// spfExpander holds the state shared by every record of one expansion.
+type spfExpander struct {
+	lookup   HostLookup
+	visiting map[string]bool
+	// lookups counts the DNS querying terms evaluated so far.
+	lookups int
+	// exceededAt names the term that passed SPFLookupLimit, if one did.
+	exceededAt string
+}
+
+func (e *spfExpander) expand(domain, via string, depth int) *SPFResult {
+	result := &SPFResult{Domain: domain, Via: via}
+	var f findings
+	defer func() { result.Findings = append(f, result.Findings...) }()
+
+	records, err := txtWithPrefix(e.lookup, domain, "v=spf1")
+	var spf []string
+	for _, r := range records {
+		if len(r) == len("v=spf1") || r[len("v=spf1")] == ' ' {
+			spf = append(spf, r)
+		}
+	}
+	switch {
+	case err != nil:
+		f.add(SeverityError, "TXT lookup for %s failed, status: %s", domain, ErrorStatus(err))
+		return result
+	case len(spf) == 0 && via == "":
+		f.add(SeverityError, "no SPF record found for %s", domain)
+		return result
+	case len(spf) == 0:
+		f.add(SeverityError, "%s has no SPF record; receivers will return permerror", domain)
+		return result
+	case len(spf) > 1:
+		f.add(SeverityError, "%s has %d SPF records; receivers will return permerror", domain, len(spf))
+	}
+
+	result.Record, result.Findings = ParseSPF(spf[0])
+	key := strings.ToLower(strings.TrimSuffix(domain, "."))
+	e.visiting[key] = true
+	defer delete(e.visiting, key)
+
+	hasAll := result.Record.All() != nil
+	for _, term := range result.Record.Terms {
+		if !term.lookups() || term.Name == "redirect" && hasAll {
+			continue
+		}
+		result.Lookups++
+		e.lookups++
+		if e.lookups > SPFLookupLimit {
+			e.exceededAt = fmt.Sprintf("%s in %s", term, domain)
+			return result
+		}
+		target := term.target()
+		switch {
+		case target == "" || strings.Contains(target, "%"):
+		case e.visiting[strings.ToLower(strings.TrimSuffix(target, "."))]:
+			f.add(SeverityError, "%s:%s loops back to a record already being evaluated", term.Name, target)
+		case depth >= maxSPFDepth:
+			f.add(SeverityError, "includes nest more than %d deep", maxSPFDepth)
+		default:
+			inc := e.expand(target, term.Name, depth+1)
+			result.Includes = append(result.Includes, inc)
+			result.Lookups += inc.Lookups
+		}
+		if e.exceededAt != "" {
+			return result
+		}
+	}
+	return result
+}
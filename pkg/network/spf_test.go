@@ -0,0 +1,138 @@
+package network
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSPF(t *testing.T) {
+	record, fs := ParseSPF("v=spf1 a mx/24 ip4:192.0.2.0/24 ip6:2001:db8::/32 include:_spf.example.net -all")
+	assert.Empty(t, fs)
+	var terms []string
+	for _, term := range record.Terms {
+		terms = append(terms, term.String())
+	}
+	assert.Equal(t, []string{"+a", "+mx/24", "+ip4:192.0.2.0/24", "+ip6:2001:db8::/32", "+include:_spf.example.net", "-all"}, terms)
+	assert.Equal(t, byte('-'), record.All().Qualifier)
+
+	record, fs = ParseSPF("v=spf1 redirect=_spf.example.net")
+	assert.Empty(t, fs)
+	assert.True(t, record.Terms[0].Modifier())
+	assert.Nil(t, record.All())
+
+	tests := []struct {
+		txt      string
+		severity Severity
+		message  string
+	}{
+		{"v=spf2 -all", SeverityError, "does not start with v=spf1"},
+		{"v=spf1 +all", SeverityError, "+all authorises every host"},
+		{"v=spf1 ?all", SeverityWarning, "neutral result"},
+		{"v=spf1 ~all", SeverityInfo, "soft-fails"},
+		{"v=spf1 mx", SeverityWarning, "no all mechanism or redirect"},
+		{"v=spf1 inclde:_spf.example.net -all", SeverityError, `unknown mechanism "inclde", did you mean include?`},
+		{"v=spf1 foo -all", SeverityError, `unknown mechanism "foo"`},
+		{"v=spf1 redirct=_spf.example.net", SeverityError, "did you mean redirect=?"},
+		{"v=spf1 ip4:192.0.2.300 -all", SeverityError, "not a valid ip4 address"},
+		{"v=spf1 ip4:2001:db8::1 -all", SeverityError, "not a valid ip4 address"},
+		{"v=spf1 ip4:192.0.2.0/33 -all", SeverityError, "invalid prefix length"},
+		{"v=spf1 a/24//129 -all", SeverityError, `invalid CIDR length "129"`},
+		{"v=spf1 ip4:10.0.0.0/8 -all", SeverityWarning, "very large address range"},
+		{"v=spf1 ptr -all", SeverityWarning, "ptr mechanism is slow and deprecated"},
+		{"v=spf1 -all mx", SeverityWarning, "+mx comes after all"},
+		{"v=spf1 redirect=a.example redirect=b.example", SeverityError, "redirect= appears more than once"},
+		{"v=spf1 mx -all redirect=a.example", SeverityWarning, "redirect= is ignored"},
+		{"v=spf1 exists:%{i}.spf.example.net -all", SeverityInfo, "uses macros"},
+		{"v=spf1 all:example.net", SeverityError, "all takes no argument"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.txt, func(t *testing.T) {
+			_, fs := ParseSPF(tt.txt)
+			assert.True(t, hasFinding(fs, tt.severity, tt.message), "findings: %v", fs)
+		})
+	}
+}
+
+func TestExpandSPF(t *testing.T) {
+	lookup := txtLookup(map[string][]string{
+		"example.com":         {"v=spf1 mx include:_spf.example.net include:_spf.example.org -all"},
+		"_spf.example.net":    {"v=spf1 a include:_nested.example.net ~all"},
+		"_nested.example.net": {"v=spf1 ip4:192.0.2.0/24 -all"},
+		"_spf.example.org":    {"v=spf1 redirect=_spf.example.net"},
+	})
+
+	result := ExpandSPF(lookup, "example.com")
+	assert.Empty(t, result.Findings)
+	// mx, two includes, then a and include in each copy of _spf.example.net
+	// plus the redirect.
+	assert.Equal(t, 8, result.Lookups)
+	if assert.Len(t, result.Includes, 2) {
+		inc := result.Includes[0]
+		assert.Equal(t, "_spf.example.net", inc.Domain)
+		assert.Equal(t, "include", inc.Via)
+		assert.Equal(t, 2, inc.Lookups)
+		assert.Equal(t, "_nested.example.net", inc.Includes[0].Domain)
+
+		org := result.Includes[1]
+		assert.Equal(t, 3, org.Lookups)
+		assert.Equal(t, "redirect", org.Includes[0].Via)
+	}
+}
+
+func TestExpandSPFLookupLimit(t *testing.T) {
+	records := map[string][]string{"example.com": {"v=spf1 include:a0.example.net include:a1.example.net include:a2.example.net -all"}}
+	for i := 0; i < 3; i++ {
+		records[fmt.Sprintf("a%d.example.net", i)] = []string{"v=spf1 a mx exists:x.example.net -all"}
+	}
+	result := ExpandSPF(txtLookup(records), "example.com")
+	// The three includes and the terms of a0 and a1 make nine lookups, so
+	// the mx of a2 is the eleventh.
+	assert.Equal(t, 11, result.Lookups)
+	assert.True(t, hasFinding(result.Findings, SeverityError, "passes the limit of 10 DNS lookups at +mx in a2.example.net"))
+}
+
+func TestExpandSPFFanOut(t *testing.T) {
+	// Every level includes the next one ten times, which would take 10^5
+	// queries to expand in full.
+	records := make(map[string][]string)
+	for level := 0; level < 5; level++ {
+		var terms []string
+		for i := 0; i < 10; i++ {
+			terms = append(terms, fmt.Sprintf("include:l%d-%d.example.net", level+1, i))
+		}
+		for i := 0; i < 10; i++ {
+			records[fmt.Sprintf("l%d-%d.example.net", level, i)] = []string{"v=spf1 " + strings.Join(terms, " ") + " -all"}
+		}
+	}
+	var queries int
+	lookup := txtLookup(records)
+	counting := lookup
+	counting.LookupTXTFunc = func(domain string) ([]string, error) {
+		queries++
+		return lookup.LookupTXTFunc(domain)
+	}
+
+	result := ExpandSPF(counting, "l0-0.example.net")
+	assert.Equal(t, SPFLookupLimit+1, result.Lookups)
+	assert.LessOrEqual(t, queries, SPFLookupLimit+1)
+	assert.True(t, hasFinding(result.Findings, SeverityError, "passes the limit of 10 DNS lookups"))
+}
+
+func TestExpandSPFErrors(t *testing.T) {
+	lookup := txtLookup(map[string][]string{
+		"example.com":      {"v=spf1 include:loop.example.net include:missing.example.net -all"},
+		"loop.example.net": {"v=spf1 include:example.com -all"},
+		"dup.example.com":  {"v=spf1 -all", "v=spf1 mx -all", "v=spf10 is not spf"},
+	})
+
+	result := ExpandSPF(lookup, "example.com")
+	fs := result.AllFindings()
+	assert.True(t, hasFinding(fs, SeverityError, "include:loop.example.net: include:example.com loops back"))
+	assert.True(t, hasFinding(fs, SeverityError, "include:missing.example.net: missing.example.net has no SPF record"))
+
+	result = ExpandSPF(lookup, "dup.example.com")
+	assert.True(t, hasFinding(result.Findings, SeverityError, "has 2 SPF records"))
+}
@@ -0,0 +1,244 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// RootServers are the IPv4 addresses of the a-m.root-servers.net root hints.
+var RootServers = []string{
+	"198.41.0.4",
+	"170.247.170.2",
+	"192.33.4.12",
+	"199.7.91.13",
+	"192.203.230.10",
+	"192.5.5.241",
+	"192.112.36.4",
+	"198.97.190.53",
+	"192.36.148.17",
+	"192.58.128.30",
+	"193.0.14.129",
+	"199.7.83.42",
+	"202.12.27.33",
+}
+
+const (
+	defaultTraceHops = 16
+	maxGluelessDepth = 3
+)
+
+// TraceHop records one step of an iterative resolution: the server that
+// was asked, how long it took and the referral or answer it returned.
+type TraceHop struct {
+	// Zone is the zone the queried server is authoritative for.
+	Zone       string
+	Server     string
+	ServerName string
+	RTT        time.Duration
+	RCode      RCode
+	// Referral holds the NS records delegating to the next zone and Glue
+	// the addresses for them from the additional section.
+	Referral []RR
+	Glue     []RR
+	Answer   []RR
+	Err      error
+}
+
+// TraceResult is the outcome of an iterative resolution from the root.
+type TraceResult struct {
+	Name   string
+	Type   Type
+	Hops   []TraceHop
+	Answer []RR
+	Err    error
+}
+
+// Tracer resolves names iteratively, starting at the root servers and
+// following NS referrals with their glue like dig +trace.
+type Tracer struct {
+	// Roots are the addresses queried first. RootServers is used when empty.
+	Roots []string
+	// Port is the port used for every nameserver, 53 when empty.
+	Port    string
+	Timeout time.Duration
+	// MaxHops bounds the number of referrals followed.
+	MaxHops int
+}
+
+// Trace resolves name and qtype from the root down and records every hop.
+func (t *Tracer) Trace(ctx context.Context, name string, qtype Type) *TraceResult {
+	return t.trace(ctx, Fqdn(name), qtype, 0)
+}
+
+func (t *Tracer) trace(ctx context.Context, name string, qtype Type, depth int) *TraceResult {
+	result := &TraceResult{Name: name, Type: qtype}
+	roots := t.Roots
+	if len(roots) == 0 {
+		roots = RootServers
+	}
+	servers := make([]nameserver, len(roots))
+	for i, root := range roots {
+		servers[i] = nameserver{addr: t.serverAddr(root)}
+	}
+	maxHops := t.MaxHops
+	if maxHops <= 0 {
+		maxHops = defaultTraceHops
+	}
+
+	zone := "."
+	for len(result.Hops) < maxHops {
+		hop, resp := t.queryZone(ctx, zone, servers, name, qtype)
+		result.Hops = append(result.Hops, hop)
+		if hop.Err != nil {
+			result.Err = hop.Err
+			return result
+		}
+		if err := rcodeError(resp, name, qtype); err != nil {
+			result.Err = err
+			return result
+		}
+		if len(resp.Answer) > 0 {
+			result.Answer = resp.Answer
+			return result
+		}
+		if len(hop.Referral) == 0 {
+			// Authoritative NODATA: the name exists without this type.
+			return result
+		}
+
+		next := hop.Referral[0].Name
+		servers = t.referralServers(ctx, hop.Referral, resp.Additional, depth)
+		if len(servers) == 0 {
+			result.Err = fmt.Errorf("no addresses for the %s nameservers", next)
+			return result
+		}
+		zone = next
+	}
+	result.Err = fmt.Errorf("trace for %s exceeded %d hops", name, maxHops)
+	return result
+}
+
+type nameserver struct {
+	name string
+	addr string
+}
+
+// queryZone asks the servers for a zone in turn until one responds.
+func (t *Tracer) queryZone(ctx context.Context, zone string, servers []nameserver, name string, qtype Type) (TraceHop, *Response) {
+	hop := TraceHop{Zone: zone}
+	for _, ns := range servers {
+		query := NewQuery(name, qtype)
+		query.RecursionDesired = false
+		client := &DNSClient{Server: ns.addr, Timeout: t.Timeout}
+		resp, err := client.Exchange(ctx, query)
+		hop.Server, hop.ServerName = ns.addr, ns.name
+		if err != nil {
+			hop.Err = err
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+		hop.Err = nil
+		hop.RTT = resp.RTT
+		hop.RCode = resp.RCode
+		hop.Answer = resp.Answer
+		hop.Referral, hop.Glue = referral(zone, name, resp)
+		return hop, resp
+	}
+	if hop.Err == nil {
+		hop.Err = errors.New("no nameservers to query")
+	}
+	return hop, nil
+}
+
+// referral extracts the NS records that delegate name to a zone below the
+// current one, together with any glue addresses for them.
+func referral(zone, name string, resp *Response) ([]RR, []RR) {
+	var nsRRs, glue []RR
+	for _, rr := range resp.Authority {
+		if rr.Type != TypeNS || !isSubdomain(name, rr.Name) || !isSubdomain(rr.Name, zone) || strings.EqualFold(rr.Name, zone) {
+			continue
+		}
+		nsRRs = append(nsRRs, rr)
+	}
+	for _, rr := range resp.Additional {
+		if rr.Type != TypeA && rr.Type != TypeAAAA {
+			continue
+		}
+		for _, ns := range nsRRs {
+			if strings.EqualFold(rr.Name, ns.Data.(*NS).Host) {
+				glue = append(glue, rr)
+				break
+			}
+		}
+	}
+	return nsRRs, glue
+}
+
+// referralServers returns the addresses to query next, using glue where
+// present and resolving glueless nameservers with a separate trace.
+func (t *Tracer) referralServers(ctx context.Context, nsRRs, additional []RR, depth int) []nameserver {
+	var servers []nameserver
+	var glueless []string
+	for _, rr := range nsRRs {
+		host := rr.Data.(*NS).Host
+		found := false
+		for _, add := range additional {
+			if !strings.EqualFold(add.Name, host) {
+				continue
+			}
+			switch data := add.Data.(type) {
+			case *A:
+				servers = append(servers, nameserver{name: host, addr: t.serverAddr(data.IP.String())})
+				found = true
+			case *AAAA:
+				servers = append(servers, nameserver{name: host, addr: t.serverAddr(data.IP.String())})
+				found = true
+			}
+		}
+		if !found {
+			glueless = append(glueless, host)
+		}
+	}
+	if len(servers) > 0 || depth >= maxGluelessDepth {
+		return servers
+	}
+
+	for _, host := range glueless {
+		res := t.trace(ctx, host, TypeA, depth+1)
+		for _, rr := range res.Answer {
+			if a, ok := rr.Data.(*A); ok {
+				servers = append(servers, nameserver{name: host, addr: t.serverAddr(a.IP.String())})
+			}
+		}
+		if len(servers) > 0 {
+			break
+		}
+	}
+	return servers
+}
+
+func (t *Tracer) serverAddr(host string) string {
+	if _, _, err := net.SplitHostPort(host); err == nil {
+		return host
+	}
+	port := t.Port
+	if port == "" {
+		port = dnsPort
+	}
+	return net.JoinHostPort(host, port)
+}
+
+// isSubdomain reports whether child is parent or a name below it.
+func isSubdomain(child, parent string) bool {
+	child, parent = strings.ToLower(Fqdn(child)), strings.ToLower(Fqdn(parent))
+	if parent == "." {
+		return true
+	}
+	return child == parent || strings.HasSuffix(child, "."+parent)
+}
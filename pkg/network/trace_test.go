@@ -0,0 +1,123 @@
+package network
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// startTestHierarchy runs fake root, com. and example.com./glueless.com.
+// servers on 127.0.0.1-3 sharing one port and returns that port.
+func startTestHierarchy(t *testing.T) string {
+	t.Helper()
+	root, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, port, _ := net.SplitHostPort(root.LocalAddr().String())
+	tld, err := net.ListenPacket("udp", net.JoinHostPort("127.0.0.2", port))
+	if err != nil {
+		root.Close()
+		t.Skipf("cannot bind a second loopback address: %v", err)
+	}
+	auth, err := net.ListenPacket("udp", net.JoinHostPort("127.0.0.3", port))
+	if err != nil {
+		root.Close()
+		tld.Close()
+		t.Skipf("cannot bind a third loopback address: %v", err)
+	}
+
+	delegate := func(zone, host, glue string) func(req *Message) *Message {
+		return func(req *Message) *Message {
+			resp := replyTo(req)
+			resp.RecursionAvailable = false
+			if !isSubdomain(req.Question[0].Name, zone) {
+				resp.RCode = RCodeNameError
+				return resp
+			}
+			resp.Authority = []RR{{Name: zone, Type: TypeNS, Class: ClassINET, TTL: 172800, Data: &NS{Host: host}}}
+			if glue != "" {
+				resp.Additional = []RR{{Name: host, Type: TypeA, Class: ClassINET, TTL: 172800, Data: &A{IP: net.ParseIP(glue).To4()}}}
+			}
+			return resp
+		}
+	}
+
+	serveTestDNS(t, root, func(req *Message) *Message {
+		return delegate("com.", "a.gtld.test.", "127.0.0.2")(req)
+	})
+	serveTestDNS(t, tld, func(req *Message) *Message {
+		if isSubdomain(req.Question[0].Name, "glueless.com.") {
+			return delegate("glueless.com.", "ns.example.com.", "")(req)
+		}
+		return delegate("example.com.", "ns.example.com.", "127.0.0.3")(req)
+	})
+	zone := testZone{
+		{Name: "www.example.com.", Type: TypeA, Class: ClassINET, TTL: 300, Data: &A{IP: net.IPv4(192, 0, 2, 1).To4()}},
+		{Name: "ns.example.com.", Type: TypeA, Class: ClassINET, TTL: 300, Data: &A{IP: net.IPv4(127, 0, 0, 3).To4()}},
+		{Name: "www.glueless.com.", Type: TypeA, Class: ClassINET, TTL: 300, Data: &A{IP: net.IPv4(192, 0, 2, 2).To4()}},
+	}
+	serveTestDNS(t, auth, func(req *Message) *Message {
+		resp := zone.handle(req)
+		resp.Authoritative = true
+		return resp
+	})
+	return port
+}
+
+func TestTrace(t *testing.T) {
+	port := startTestHierarchy(t)
+	tracer := &Tracer{Roots: []string{"127.0.0.1"}, Port: port, Timeout: time.Second}
+
+	result := tracer.Trace(context.Background(), "www.example.com", TypeA)
+	assert.NoError(t, result.Err)
+	assert.Len(t, result.Hops, 3)
+	assert.Equal(t, ".", result.Hops[0].Zone)
+	assert.Equal(t, "com.", result.Hops[1].Zone)
+	assert.Equal(t, "a.gtld.test.", result.Hops[1].ServerName)
+	assert.Equal(t, "example.com.", result.Hops[2].Zone)
+	assert.Equal(t, net.JoinHostPort("127.0.0.3", port), result.Hops[2].Server)
+	assert.Len(t, result.Hops[0].Glue, 1)
+	assert.Len(t, result.Answer, 1)
+	assert.Equal(t, "192.0.2.1", result.Answer[0].Data.(*A).IP.String())
+}
+
+func TestTraceGlueless(t *testing.T) {
+	port := startTestHierarchy(t)
+	tracer := &Tracer{Roots: []string{"127.0.0.1"}, Port: port, Timeout: time.Second}
+
+	result := tracer.Trace(context.Background(), "www.glueless.com", TypeA)
+	assert.NoError(t, result.Err)
+	assert.Len(t, result.Hops, 3)
+	assert.Empty(t, result.Hops[1].Glue)
+	assert.Equal(t, "192.0.2.2", result.Answer[0].Data.(*A).IP.String())
+}
+
+func TestTraceNXDomain(t *testing.T) {
+	port := startTestHierarchy(t)
+	tracer := &Tracer{Roots: []string{"127.0.0.1"}, Port: port, Timeout: time.Second}
+
+	result := tracer.Trace(context.Background(), "missing.example.com", TypeA)
+	assert.Equal(t, "NXDOMAIN", ErrorStatus(result.Err))
+	assert.Len(t, result.Hops, 3)
+}
+
+func TestTraceUnreachableRoot(t *testing.T) {
+	addr := startTestDNSServer(t, func(req *Message) *Message { return nil })
+	tracer := &Tracer{Roots: []string{addr}, Timeout: 100 * time.Millisecond}
+
+	result := tracer.Trace(context.Background(), "www.example.com", TypeA)
+	assert.Error(t, result.Err)
+	assert.Len(t, result.Hops, 1)
+}
+
+func TestIsSubdomain(t *testing.T) {
+	assert.True(t, isSubdomain("www.example.com.", "example.com."))
+	assert.True(t, isSubdomain("Example.COM", "example.com."))
+	assert.True(t, isSubdomain("example.com.", "."))
+	assert.False(t, isSubdomain("badexample.com.", "example.com."))
+	assert.False(t, isSubdomain("example.com.", "www.example.com."))
+}
@@ -0,0 +1,99 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"net"
+	"syscall"
+	"time"
+)
+
+// UDPProbeResult is the outcome of probing a UDP port.
+type UDPProbeResult struct {
+	// State is PortOpen when a reply came back, PortClosed when the host
+	// answered with an ICMP port unreachable, or PortOpenFiltered when
+	// nothing came back, which UDP cannot tell apart from a dropped packet.
+	State PortState
+	Reply []byte
+	RTT   time.Duration
+}
+
+// snmpGetSysDescr is an SNMPv1 get-request for sysDescr.0 with the
+// community "public".
+var snmpGetSysDescr = []byte{
+	0x30, 0x26, 0x02, 0x01, 0x00, 0x04, 0x06, 'p', 'u', 'b', 'l', 'i', 'c',
+	0xa0, 0x19, 0x02, 0x01, 0x01, 0x02, 0x01, 0x00, 0x02, 0x01, 0x00,
+	0x30, 0x0e, 0x30, 0x0c, 0x06, 0x08, 0x2b, 0x06, 0x01, 0x02, 0x01, 0x01, 0x01, 0x00, 0x05, 0x00,
+}
+
+// DefaultUDPPayload returns a datagram that the service usually found on
+// port answers, or an empty one for ports without a known service. Services
+// such as syslog, StatsD and WireGuard never reply, so they get an empty
+// datagram too: anything else would be logged as a message or counted as a
+// metric, and they can only be told to be closed, never open.
+func DefaultUDPPayload(port int) []byte {
+	switch port {
+	case 53, 5353:
+		query, _ := NewQuery(".", TypeNS).Pack()
+		return query
+	case 123:
+		// An NTPv3 client request.
+		ntp := make([]byte, 48)
+		ntp[0] = 0x1b
+		return ntp
+	case 161:
+		return snmpGetSysDescr
+	case 1900:
+		return []byte("M-SEARCH * HTTP/1.1\r\nHOST: 239.255.255.250:1900\r\nMAN: \"ssdp:discover\"\r\nMX: 1\r\nST: ssdp:all\r\n\r\n")
+	}
+	return nil
+}
+
+// NetcatUDP sends payload to the UDP port of host and waits up to timeout,
+// DefaultDialTimeout when zero, for a reply, giving up early when ctx is
+// done.
+func NetcatUDP(ctx context.Context, host, port string, payload []byte, timeout time.Duration) (*UDPProbeResult, error) {
+	return probeUDP(ctx, NetDialer{}, host, port, payload, timeout)
+}
+
+func probeUDP(ctx context.Context, dialer Dialer, host, port string, payload []byte, timeout time.Duration) (*UDPProbeResult, error) {
+	timeout = dialTimeout(timeout)
+	conn, err := dialer.Dial(ctx, "udp", net.JoinHostPort(host, port), timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	result := &UDPProbeResult{State: PortOpenFiltered}
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	start := time.Now()
+	if _, err := conn.Write(payload); err != nil {
+		return udpProbeError(ctx, result, err)
+	}
+	buf := make([]byte, 65535)
+	n, err := conn.Read(buf)
+	result.RTT = time.Since(start)
+	if err != nil {
+		return udpProbeError(ctx, result, err)
+	}
+	result.State, result.Reply = PortOpen, buf[:n]
+	return result, nil
+}
+
+// udpProbeError classifies a failed write or read: a refusal is the ICMP
+// port unreachable of a closed port and a timeout is silence.
+func udpProbeError(ctx context.Context, result *UDPProbeResult, err error) (*UDPProbeResult, error) {
+	var netErr net.Error
+	switch {
+	case ctx.Err() != nil:
+		return nil, ctx.Err()
+	case errors.Is(err, syscall.ECONNREFUSED):
+		result.State = PortClosed
+	case errors.As(err, &netErr) && netErr.Timeout():
+	default:
+		return nil, err
+	}
+	return result, nil
+}
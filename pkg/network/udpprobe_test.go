@@ -0,0 +1,101 @@
+package network
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// startUDPListener runs a loopback UDP listener that answers each datagram
+// with reply(datagram), or stays silent when reply returns nil.
+func startUDPListener(t *testing.T, reply func([]byte) []byte) string {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { pc.Close() })
+	go func() {
+		buf := make([]byte, 65535)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if out := reply(buf[:n]); out != nil {
+				pc.WriteTo(out, addr)
+			}
+		}
+	}()
+	return pc.LocalAddr().String()
+}
+
+func TestNetcatUDP(t *testing.T) {
+	echo := startUDPListener(t, func(b []byte) []byte { return append([]byte(nil), b...) })
+	host, port, _ := net.SplitHostPort(echo)
+	result, err := NetcatUDP(context.Background(), host, port, []byte("ping"), time.Second)
+	if assert.NoError(t, err) {
+		assert.Equal(t, PortOpen, result.State)
+		assert.Equal(t, []byte("ping"), result.Reply)
+	}
+
+	silent := startUDPListener(t, func([]byte) []byte { return nil })
+	host, port, _ = net.SplitHostPort(silent)
+	result, err = NetcatUDP(context.Background(), host, port, nil, 100*time.Millisecond)
+	if assert.NoError(t, err) {
+		assert.Equal(t, PortOpenFiltered, result.State)
+		assert.Empty(t, result.Reply)
+	}
+
+	// Nothing listens on a port just released, so the kernel answers with
+	// an ICMP port unreachable.
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	host, port, _ = net.SplitHostPort(pc.LocalAddr().String())
+	pc.Close()
+	result, err = NetcatUDP(context.Background(), host, port, []byte("ping"), time.Second)
+	if assert.NoError(t, err) {
+		assert.Equal(t, PortClosed, result.State)
+	}
+}
+
+func TestNetcatUDPCancel(t *testing.T) {
+	silent := startUDPListener(t, func([]byte) []byte { return nil })
+	host, port, _ := net.SplitHostPort(silent)
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	_, err := NetcatUDP(ctx, host, port, nil, time.Minute)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Less(t, time.Since(start), 10*time.Second)
+}
+
+func TestDefaultUDPPayload(t *testing.T) {
+	query := &Message{}
+	if assert.NoError(t, query.Unpack(DefaultUDPPayload(53))) {
+		assert.Equal(t, TypeNS, query.Question[0].Type)
+	}
+	assert.Len(t, DefaultUDPPayload(123), 48)
+	assert.Equal(t, byte(0x1b), DefaultUDPPayload(123)[0])
+	assert.Equal(t, len(snmpGetSysDescr)-2, int(snmpGetSysDescr[1]))
+	assert.Contains(t, string(DefaultUDPPayload(1900)), "ssdp:discover")
+	assert.Nil(t, DefaultUDPPayload(51820))
+	assert.Nil(t, DefaultUDPPayload(514))
+	assert.Nil(t, DefaultUDPPayload(8125))
+	assert.Equal(t, "open|filtered", PortOpenFiltered.String())
+}
+
+func TestProbeUDPDialError(t *testing.T) {
+	dialer := MockDialer{DialFunc: func(ctx context.Context, network, address string, timeout time.Duration) (net.Conn, error) {
+		assert.Equal(t, "udp", network)
+		assert.Equal(t, "192.0.2.1:514", address)
+		return nil, &net.DNSError{Err: "no such host", Name: "bogus"}
+	}}
+	_, err := probeUDP(context.Background(), dialer, "192.0.2.1", "514", nil, time.Second)
+	assert.Error(t, err)
+}
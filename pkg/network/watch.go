@@ -0,0 +1,161 @@
+package network
+
+import (
+	"context"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultWatchInterval is how long Watch waits between rounds of queries.
+const DefaultWatchInterval = 5 * time.Second
+
+// Watch re-queries a name against several resolvers until every one of them
+// answers with the expected values, for waiting on a record change to
+// propagate.
+type Watch struct {
+	Name string
+	// Type is the record type queried. When zero it is A, or AAAA when
+	// every expected value is an IPv6 address.
+	Type Type
+	// Expect holds the values the answer must consist of, in any order.
+	// Names match without regard to case or a trailing dot, and TXT
+	// records match on their unquoted text.
+	Expect []string
+	// Interval is the wait between rounds, DefaultWatchInterval when zero.
+	Interval time.Duration
+}
+
+// WatchResolver is a resolver to watch and the label to report it by.
+type WatchResolver struct {
+	Server  string
+	Querier Querier
+}
+
+// WatchStatus is the latest answer of one resolver.
+type WatchStatus struct {
+	Server string
+	// Values are the answer's values of the watched type, sorted.
+	Values []string
+	TTL    uint32
+	Err    error
+	Match  bool
+	// MatchedAt is when the resolver started matching, zero while it does
+	// not.
+	MatchedAt time.Time
+}
+
+// Run queries every resolver once per interval, calling update with the
+// round number and the statuses in the order of resolvers after each round.
+// It returns true as soon as all resolvers match in the same round, and
+// false when ctx is done first.
+func (w *Watch) Run(ctx context.Context, resolvers []WatchResolver, update func(round int, statuses []*WatchStatus)) bool {
+	qtype := w.qtype()
+	interval := w.Interval
+	if interval == 0 {
+		interval = DefaultWatchInterval
+	}
+	expect := make([]string, len(w.Expect))
+	for i, v := range w.Expect {
+		expect[i] = watchValue(qtype, v)
+	}
+	sort.Strings(expect)
+
+	statuses := make([]*WatchStatus, len(resolvers))
+	for i, r := range resolvers {
+		statuses[i] = &WatchStatus{Server: r.Server}
+	}
+	for round := 1; ; round++ {
+		var wg sync.WaitGroup
+		for i, r := range resolvers {
+			wg.Add(1)
+			go func(status *WatchStatus, r WatchResolver) {
+				defer wg.Done()
+				w.query(ctx, r.Querier, qtype, expect, status)
+			}(statuses[i], r)
+		}
+		wg.Wait()
+		if ctx.Err() != nil {
+			return false
+		}
+		update(round, statuses)
+
+		all := true
+		for _, s := range statuses {
+			all = all && s.Match
+		}
+		if all {
+			return true
+		}
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(interval):
+		}
+	}
+}
+
+func (w *Watch) qtype() Type {
+	if w.Type != 0 {
+		return w.Type
+	}
+	if len(w.Expect) == 0 {
+		return TypeA
+	}
+	for _, v := range w.Expect {
+		if ip := net.ParseIP(v); ip == nil || ip.To4() != nil {
+			return TypeA
+		}
+	}
+	return TypeAAAA
+}
+
+// query refreshes status with one answer from querier.
+func (w *Watch) query(ctx context.Context, querier Querier, qtype Type, expect []string, status *WatchStatus) {
+	status.Values, status.TTL = nil, 0
+	resp, err := querier.Query(ctx, w.Name, qtype)
+	var rrs []RR
+	if err == nil {
+		rrs, err = answerRecords(resp, w.Name, qtype)
+	}
+	status.Err = err
+	for i, rr := range rrs {
+		status.Values = append(status.Values, watchValue(qtype, rrValue(rr)))
+		if i == 0 || rr.TTL < status.TTL {
+			status.TTL = rr.TTL
+		}
+	}
+	sort.Strings(status.Values)
+
+	match := err == nil && len(status.Values) > 0 && strings.Join(status.Values, "\n") == strings.Join(expect, "\n")
+	switch {
+	case match && !status.Match:
+		status.MatchedAt = time.Now()
+	case !match:
+		status.MatchedAt = time.Time{}
+	}
+	status.Match = match
+}
+
+// rrValue returns the text of a record that Watch compares, which for TXT
+// records is the unquoted text.
+func rrValue(rr RR) string {
+	if txt, ok := rr.Data.(*TXT); ok {
+		return strings.Join(txt.Strings, "")
+	}
+	return rr.Data.String()
+}
+
+// watchValue normalises a value so that equivalent spellings compare equal.
+func watchValue(qtype Type, v string) string {
+	if qtype == TypeTXT {
+		return v
+	}
+	if ip := net.ParseIP(v); ip != nil {
+		return ip.String()
+	}
+	return strings.TrimSuffix(strings.ToLower(v), ".")
+}
@@ -0,0 +1,87 @@
+package network
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// flippingResolver answers with old until it has been queried flipAfter
+// times, then with new.
+func flippingResolver(flipAfter int32, old, new string) func(req *Message) *Message {
+	var queries atomic.Int32
+	return func(req *Message) *Message {
+		ip := old
+		if queries.Add(1) > flipAfter {
+			ip = new
+		}
+		resp := replyTo(req)
+		resp.Answer = []RR{{Name: req.Question[0].Name, Type: TypeA, Class: ClassINET, TTL: 60, Data: &A{IP: net.ParseIP(ip).To4()}}}
+		return resp
+	}
+}
+
+func TestWatch(t *testing.T) {
+	early := NewDNSClient(startTestDNSServer(t, flippingResolver(0, "192.0.2.1", "203.0.113.5")))
+	late := NewDNSClient(startTestDNSServer(t, flippingResolver(2, "192.0.2.1", "203.0.113.5")))
+
+	watch := &Watch{Name: "example.com", Expect: []string{"203.0.113.5"}, Interval: 10 * time.Millisecond}
+	var rounds []int
+	var lateMatched []bool
+	ok := watch.Run(context.Background(), []WatchResolver{{"early", early}, {"late", late}}, func(round int, statuses []*WatchStatus) {
+		rounds = append(rounds, round)
+		assert.True(t, statuses[0].Match)
+		assert.False(t, statuses[0].MatchedAt.IsZero())
+		assert.Equal(t, uint32(60), statuses[0].TTL)
+		lateMatched = append(lateMatched, statuses[1].Match)
+		if !statuses[1].Match {
+			assert.Equal(t, []string{"192.0.2.1"}, statuses[1].Values)
+		}
+	})
+	assert.True(t, ok)
+	assert.Equal(t, []int{1, 2, 3}, rounds)
+	assert.Equal(t, []bool{false, false, true}, lateMatched)
+}
+
+func TestWatchDeadline(t *testing.T) {
+	stale := NewDNSClient(startTestDNSServer(t, flippingResolver(1000, "192.0.2.1", "203.0.113.5")))
+	missing := NewDNSClient(startTestDNSServer(t, func(req *Message) *Message {
+		resp := replyTo(req)
+		resp.RCode = RCodeNameError
+		return resp
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	watch := &Watch{Name: "example.com", Expect: []string{"203.0.113.5"}, Interval: 10 * time.Millisecond}
+	// The statuses are updated in place, and the deadline can cut the last
+	// round short, so copy what each update reports.
+	var rounds int
+	var staleMatch bool
+	var missingStatus string
+	ok := watch.Run(ctx, []WatchResolver{{"stale", stale}, {"missing", missing}}, func(round int, statuses []*WatchStatus) {
+		rounds = round
+		staleMatch = staleMatch || statuses[0].Match
+		missingStatus = ErrorStatus(statuses[1].Err)
+	})
+	assert.False(t, ok)
+	assert.Positive(t, rounds)
+	assert.False(t, staleMatch)
+	assert.Equal(t, "NXDOMAIN", missingStatus)
+}
+
+func TestWatchValues(t *testing.T) {
+	assert.Equal(t, TypeA, (&Watch{Expect: []string{"203.0.113.5"}}).qtype())
+	assert.Equal(t, TypeAAAA, (&Watch{Expect: []string{"2001:db8::1"}}).qtype())
+	assert.Equal(t, TypeA, (&Watch{}).qtype())
+	assert.Equal(t, TypeMX, (&Watch{Type: TypeMX}).qtype())
+
+	assert.Equal(t, "2001:db8::1", watchValue(TypeAAAA, "2001:DB8:0::1"))
+	assert.Equal(t, "10 mx.example.com", watchValue(TypeMX, "10 MX.example.com."))
+	assert.Equal(t, "Hello.", watchValue(TypeTXT, "Hello."))
+	assert.Equal(t, "v=spf1 -all", rrValue(RR{Type: TypeTXT, Data: &TXT{Strings: []string{"v=spf1 ", "-all"}}}))
+}
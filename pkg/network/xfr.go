@@ -0,0 +1,246 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ZoneTransfer is the outcome of an AXFR or IXFR request.
+type ZoneTransfer struct {
+	Zone   string
+	Server string
+	// Type is TypeAXFR or TypeIXFR, as requested.
+	Type Type
+	// Serial is the zone's serial after the transfer.
+	Serial uint32
+	// Records holds the whole zone, SOA first, for an AXFR or for an IXFR
+	// the server answered with a full transfer.
+	Records []RR
+	// Incremental is set when an IXFR was answered with differences. Deltas
+	// is empty when the requested serial is already current.
+	Incremental bool
+	Deltas      []ZoneDelta
+	// Messages is the number of DNS messages the transfer took.
+	Messages int
+	Duration time.Duration
+}
+
+// ZoneDelta is one step of an incremental transfer (RFC 1995).
+type ZoneDelta struct {
+	FromSerial uint32
+	ToSerial   uint32
+	Deleted    []RR
+	Added      []RR
+}
+
+// Transfer requests zone from the client's server with AXFR, or with IXFR
+// when qtype is TypeIXFR, serial being the version already held. Zone
+// transfers always run over TCP. The client's Timeout bounds the wait for
+// each message rather than the whole transfer.
+func (c *DNSClient) Transfer(ctx context.Context, zone string, qtype Type, serial uint32) (*ZoneTransfer, error) {
+	if qtype != TypeAXFR && qtype != TypeIXFR {
+		return nil, fmt.Errorf("unsupported zone transfer type: %s", qtype)
+	}
+	zone = Fqdn(zone)
+	query := NewQuery(zone, qtype)
+	query.RecursionDesired = false
+	if qtype == TypeIXFR {
+		query.Authority = []RR{{Name: zone, Type: TypeSOA, Class: ClassINET, Data: &SOA{MName: ".", RName: ".", Serial: serial}}}
+	}
+	out, err := query.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	var d net.Dialer
+	dialCtx, cancel := context.WithTimeout(ctx, c.timeout())
+	conn, err := d.DialContext(dialCtx, "tcp", c.Server)
+	cancel()
+	if err != nil {
+		return nil, transportError(fmt.Errorf("tcp: %w", err), zone, qtype, c.Server, c.timeout())
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	conn.SetDeadline(time.Now().Add(c.timeout()))
+	framed := append([]byte{byte(len(out) >> 8), byte(len(out))}, out...)
+	if _, err := conn.Write(framed); err != nil {
+		return nil, transportError(fmt.Errorf("tcp: %w", connError(ctx, err)), zone, qtype, c.Server, c.timeout())
+	}
+
+	xfr := &ZoneTransfer{Zone: zone, Server: c.Server, Type: qtype}
+	var rrs []RR
+	for done := false; !done; {
+		conn.SetDeadline(time.Now().Add(c.timeout()))
+		if ctx.Err() != nil {
+			return nil, transportError(ctx.Err(), zone, qtype, c.Server, c.timeout())
+		}
+		in, err := readStreamMessage(conn)
+		if err != nil {
+			return nil, transportError(fmt.Errorf("tcp: %w", connError(ctx, err)), zone, qtype, c.Server, c.timeout())
+		}
+		msg := &Message{}
+		if err := msg.Unpack(in); err != nil {
+			return nil, transportError(fmt.Errorf("tcp: malformed response: %v", err), zone, qtype, c.Server, c.timeout())
+		}
+		if msg.ID != query.ID || !msg.Response {
+			return nil, transportError(errors.New("tcp: response does not match query"), zone, qtype, c.Server, c.timeout())
+		}
+		if err := rcodeError(&Response{Message: msg, Server: c.Server}, zone, qtype); err != nil {
+			return nil, err
+		}
+		xfr.Messages++
+		for _, rr := range msg.Answer {
+			rrs = append(rrs, rr)
+			if done, err = transferComplete(rrs, qtype, serial); done || err != nil {
+				break
+			}
+		}
+		if err != nil {
+			return nil, transportError(err, zone, qtype, c.Server, c.timeout())
+		}
+		if len(msg.Answer) == 0 {
+			return nil, transportError(errors.New("server returned an empty transfer message"), zone, qtype, c.Server, c.timeout())
+		}
+	}
+	xfr.Duration = time.Since(start)
+	if err := xfr.assemble(rrs, serial); err != nil {
+		return nil, transportError(err, zone, qtype, c.Server, c.timeout())
+	}
+	return xfr, nil
+}
+
+// transferComplete reports whether rrs, the answer records received so
+// far, form a whole transfer. A transfer starts with the zone's SOA and ends
+// when that SOA is repeated; an IXFR reply holding just the SOA means the
+// requested serial is current.
+func transferComplete(rrs []RR, qtype Type, serial uint32) (bool, error) {
+	first, ok := rrs[0].Data.(*SOA)
+	if !ok {
+		return false, errors.New("zone transfer does not start with an SOA record")
+	}
+	if len(rrs) == 1 {
+		return qtype == TypeIXFR && serialCompare(first.Serial, serial) <= 0, nil
+	}
+	last, ok := rrs[len(rrs)-1].Data.(*SOA)
+	if !ok || last.Serial != first.Serial {
+		return false, nil
+	}
+	if qtype == TypeAXFR || len(rrs) == 2 {
+		return true, nil
+	}
+	if _, incremental := rrs[1].Data.(*SOA); !incremental {
+		return true, nil
+	}
+	// In an incremental reply the new serial also opens the additions of
+	// the last delta, so the end is the SOA that follows them: count the
+	// SOAs after the first, which pair up as (from, to) then the final one.
+	soas := 0
+	for _, rr := range rrs[1:] {
+		if rr.Type == TypeSOA {
+			soas++
+		}
+	}
+	return soas%2 == 1, nil
+}
+
+// assemble fills in the records or deltas from the complete answer stream.
+func (x *ZoneTransfer) assemble(rrs []RR, serial uint32) error {
+	soa := rrs[0].Data.(*SOA)
+	x.Serial = soa.Serial
+	if len(rrs) == 1 {
+		x.Incremental = true
+		return nil
+	}
+	if _, incremental := rrs[1].Data.(*SOA); x.Type == TypeIXFR && incremental && len(rrs) > 2 {
+		x.Incremental = true
+		var delta *ZoneDelta
+		adding := false
+		for _, rr := range rrs[1 : len(rrs)-1] {
+			s, isSOA := rr.Data.(*SOA)
+			switch {
+			case isSOA && !adding:
+				if delta != nil {
+					delta.ToSerial = s.Serial
+					adding = true
+					continue
+				}
+				x.Deltas = append(x.Deltas, ZoneDelta{FromSerial: s.Serial})
+				delta = &x.Deltas[len(x.Deltas)-1]
+			case isSOA:
+				x.Deltas = append(x.Deltas, ZoneDelta{FromSerial: s.Serial})
+				delta = &x.Deltas[len(x.Deltas)-1]
+				adding = false
+			case adding:
+				delta.Added = append(delta.Added, rr)
+			default:
+				delta.Deleted = append(delta.Deleted, rr)
+			}
+		}
+		if len(x.Deltas) == 0 || x.Deltas[0].FromSerial != serial {
+			return fmt.Errorf("incremental transfer does not start at serial %d", serial)
+		}
+		return nil
+	}
+	x.Records = rrs[:len(rrs)-1]
+	return nil
+}
+
+// serialCompare compares two SOA serials using RFC 1982 sequence space
+// arithmetic, returning -1, 0 or 1.
+func serialCompare(a, b uint32) int {
+	switch {
+	case a == b:
+		return 0
+	case int32(a-b) < 0:
+		return -1
+	}
+	return 1
+}
+
+// SortRecords sorts rrs into DNSSEC canonical name order (RFC 4034 section
+// 6.1), then by type and record data, keeping the zone's SOA first. The
+// result is stable across servers and suited to diffing.
+func SortRecords(rrs []RR) {
+	sort.SliceStable(rrs, func(i, j int) bool {
+		a, b := rrs[i], rrs[j]
+		if c := compareNames(a.Name, b.Name); c != 0 {
+			return c < 0
+		}
+		if (a.Type == TypeSOA) != (b.Type == TypeSOA) {
+			return a.Type == TypeSOA
+		}
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		return a.Data.String() < b.Data.String()
+	})
+}
+
+// compareNames orders domain names canonically: label by label from the
+// root, case-insensitively.
+func compareNames(a, b string) int {
+	la, lb := nameLabels(a), nameLabels(b)
+	for i := 1; i <= len(la) && i <= len(lb); i++ {
+		if c := strings.Compare(la[len(la)-i], lb[len(lb)-i]); c != 0 {
+			return c
+		}
+	}
+	return len(la) - len(lb)
+}
+
+// nameLabels splits a name into lowercased labels, ignoring the root.
+func nameLabels(name string) []string {
+	name = strings.TrimSuffix(strings.ToLower(name), ".")
+	if name == "" {
+		return nil
+	}
+	return strings.Split(name, ".")
+}
@@ -0,0 +1,196 @@
+package network
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// startTestXFRServer answers each zone transfer request with the messages
+// returned by handler, one TCP write per message.
+func startTestXFRServer(t *testing.T, handler func(req *Message) []*Message) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				in, err := readStreamMessage(conn)
+				if err != nil {
+					return
+				}
+				req := &Message{}
+				if err := req.Unpack(in); err != nil {
+					return
+				}
+				for _, resp := range handler(req) {
+					out, err := resp.Pack()
+					if err != nil {
+						return
+					}
+					conn.Write(append(binary.BigEndian.AppendUint16(nil, uint16(len(out))), out...))
+				}
+			}(conn)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func xfrSOA(serial uint32) RR {
+	return RR{Name: "example.internal.", Type: TypeSOA, Class: ClassINET, TTL: 3600, Data: &SOA{MName: "ns1.example.internal.", RName: "admin.example.internal.", Serial: serial}}
+}
+
+func xfrA(name, ip string) RR {
+	return RR{Name: name, Type: TypeA, Class: ClassINET, TTL: 300, Data: &A{IP: net.ParseIP(ip).To4()}}
+}
+
+// xfrReply splits rrs over messages of at most per records each.
+func xfrReply(req *Message, per int, rrs ...RR) []*Message {
+	var msgs []*Message
+	for len(rrs) > 0 {
+		n := min(per, len(rrs))
+		resp := replyTo(req)
+		resp.Answer = rrs[:n]
+		msgs = append(msgs, resp)
+		rrs = rrs[n:]
+	}
+	return msgs
+}
+
+func TestTransferAXFR(t *testing.T) {
+	ns := RR{Name: "example.internal.", Type: TypeNS, Class: ClassINET, TTL: 3600, Data: &NS{Host: "ns1.example.internal."}}
+	addr := startTestXFRServer(t, func(req *Message) []*Message {
+		assert.Equal(t, TypeAXFR, req.Question[0].Type)
+		return xfrReply(req, 2,
+			xfrSOA(7),
+			xfrA("www.example.internal.", "192.0.2.2"),
+			ns,
+			xfrA("db.example.internal.", "192.0.2.3"),
+			xfrA("www.example.internal.", "192.0.2.1"),
+			xfrSOA(7))
+	})
+
+	client := NewDNSClient(addr)
+	xfr, err := client.Transfer(context.Background(), "example.internal", TypeAXFR, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(7), xfr.Serial)
+	assert.Equal(t, 3, xfr.Messages)
+	assert.False(t, xfr.Incremental)
+	assert.Len(t, xfr.Records, 5)
+
+	SortRecords(xfr.Records)
+	var got []string
+	for _, rr := range xfr.Records {
+		got = append(got, rr.Name+" "+rr.Type.String()+" "+rr.Data.String())
+	}
+	assert.Equal(t, []string{
+		"example.internal. SOA ns1.example.internal. admin.example.internal. 7 0 0 0 0",
+		"example.internal. NS ns1.example.internal.",
+		"db.example.internal. A 192.0.2.3",
+		"www.example.internal. A 192.0.2.1",
+		"www.example.internal. A 192.0.2.2",
+	}, got)
+}
+
+func TestTransferIXFR(t *testing.T) {
+	addr := startTestXFRServer(t, func(req *Message) []*Message {
+		assert.Equal(t, TypeIXFR, req.Question[0].Type)
+		have := req.Authority[0].Data.(*SOA).Serial
+		switch have {
+		case 10:
+			return xfrReply(req, 3,
+				xfrSOA(12),
+				xfrSOA(10), xfrA("old.example.internal.", "192.0.2.9"),
+				xfrSOA(11), xfrA("new.example.internal.", "192.0.2.10"),
+				xfrSOA(11),
+				xfrSOA(12), xfrA("newer.example.internal.", "192.0.2.11"),
+				xfrSOA(12))
+		case 12:
+			return xfrReply(req, 1, xfrSOA(12))
+		}
+		return xfrReply(req, 10, xfrSOA(12), xfrA("www.example.internal.", "192.0.2.1"), xfrSOA(12))
+	})
+	client := NewDNSClient(addr)
+
+	xfr, err := client.Transfer(context.Background(), "example.internal", TypeIXFR, 10)
+	assert.NoError(t, err)
+	assert.True(t, xfr.Incremental)
+	assert.Equal(t, uint32(12), xfr.Serial)
+	if assert.Len(t, xfr.Deltas, 2) {
+		assert.Equal(t, ZoneDelta{FromSerial: 10, ToSerial: 11,
+			Deleted: []RR{xfrA("old.example.internal.", "192.0.2.9")},
+			Added:   []RR{xfrA("new.example.internal.", "192.0.2.10")}}, xfr.Deltas[0])
+		assert.Equal(t, uint32(11), xfr.Deltas[1].FromSerial)
+		assert.Equal(t, uint32(12), xfr.Deltas[1].ToSerial)
+		assert.Empty(t, xfr.Deltas[1].Deleted)
+		assert.Len(t, xfr.Deltas[1].Added, 1)
+	}
+
+	xfr, err = client.Transfer(context.Background(), "example.internal", TypeIXFR, 12)
+	assert.NoError(t, err)
+	assert.True(t, xfr.Incremental)
+	assert.Empty(t, xfr.Deltas)
+
+	xfr, err = client.Transfer(context.Background(), "example.internal", TypeIXFR, 1)
+	assert.NoError(t, err)
+	assert.False(t, xfr.Incremental)
+	assert.Len(t, xfr.Records, 2)
+}
+
+func TestTransferRefused(t *testing.T) {
+	addr := startTestXFRServer(t, func(req *Message) []*Message {
+		resp := replyTo(req)
+		resp.RCode = RCodeRefused
+		return []*Message{resp}
+	})
+
+	client := NewDNSClient(addr)
+	_, err := client.Transfer(context.Background(), "example.internal", TypeAXFR, 0)
+	assert.Equal(t, "REFUSED", ErrorStatus(err))
+}
+
+func TestTransferIncomplete(t *testing.T) {
+	addr := startTestXFRServer(t, func(req *Message) []*Message {
+		return xfrReply(req, 1, xfrSOA(1), xfrA("www.example.internal.", "192.0.2.1"))
+	})
+
+	client := NewDNSClient(addr)
+	client.Timeout = time.Second
+	_, err := client.Transfer(context.Background(), "example.internal", TypeAXFR, 0)
+	assert.Error(t, err)
+
+	_, err = client.Transfer(context.Background(), "example.internal", TypeA, 0)
+	assert.ErrorContains(t, err, "unsupported zone transfer type")
+}
+
+func TestSerialCompare(t *testing.T) {
+	assert.Equal(t, 0, serialCompare(5, 5))
+	assert.Equal(t, -1, serialCompare(4, 5))
+	assert.Equal(t, 1, serialCompare(1, 0xFFFFFFFF))
+}
+
+func TestSortRecords(t *testing.T) {
+	names := []string{"z.example.", "example.", "a.example.", "b.a.example.", "Z.a.example.", "zABC.a.EXAMPLE."}
+	rrs := make([]RR, len(names))
+	for i, n := range names {
+		rrs[i] = RR{Name: n, Type: TypeA, Data: &A{IP: net.IPv4(192, 0, 2, 1)}}
+	}
+	SortRecords(rrs)
+	var got []string
+	for _, rr := range rrs {
+		got = append(got, rr.Name)
+	}
+	assert.Equal(t, []string{"example.", "a.example.", "b.a.example.", "Z.a.example.", "zABC.a.EXAMPLE.", "z.example."}, got)
+}
@@ -0,0 +1,571 @@
+package network
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// ZoneFile is a parsed RFC 1035 master file.
+type ZoneFile struct {
+	// Origin is the zone apex: the owner of the SOA record, or the initial
+	// origin when the file has none.
+	Origin  string
+	Records []RR
+}
+
+// SOA returns the zone's SOA record data, or nil when the file has none.
+func (z *ZoneFile) SOA() *SOA {
+	for _, rr := range z.Records {
+		if soa, ok := rr.Data.(*SOA); ok {
+			return soa
+		}
+	}
+	return nil
+}
+
+// ParseZoneFile reads a master file (RFC 1035 section 5). origin is the
+// $ORIGIN in effect until the file sets one and may be empty when every
+// name is absolute. $ORIGIN and $TTL directives, @, relative names, omitted
+// owners, TTLs and classes, parenthesised multi-line records, comments and
+// the RFC 3597 \# form of rdata are understood; $INCLUDE is not.
+func ParseZoneFile(r io.Reader, origin string) (*ZoneFile, error) {
+	entries, err := zoneEntries(r)
+	if err != nil {
+		return nil, err
+	}
+	p := &zoneParser{origin: origin}
+	if origin != "" {
+		p.origin = Fqdn(origin)
+	}
+	zone := &ZoneFile{Origin: p.origin}
+	for _, e := range entries {
+		rr, ok, err := p.entry(e)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", e.line, err)
+		}
+		if !ok {
+			continue
+		}
+		if rr.Type == TypeSOA && zone.SOA() == nil {
+			zone.Origin = rr.Name
+		}
+		zone.Records = append(zone.Records, rr)
+	}
+	return zone, nil
+}
+
+// zoneToken is one field of a master file entry. Quoted tokens hold the
+// text between the quotes, escapes still undecoded.
+type zoneToken struct {
+	text   string
+	quoted bool
+}
+
+// zoneEntry is one logical line of a master file.
+type zoneEntry struct {
+	line int
+	// blankOwner is set when the line starts with white space, so the
+	// record belongs to the previous owner.
+	blankOwner bool
+	tokens     []zoneToken
+}
+
+// zoneEntries splits a master file into entries, joining the lines of a
+// parenthesised record and dropping comments.
+func zoneEntries(r io.Reader) ([]zoneEntry, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var entries []zoneEntry
+	var cur zoneEntry
+	line, depth, startOfLine := 1, 0, true
+	add := func(t zoneToken) {
+		if len(cur.tokens) == 0 {
+			cur.line = line
+		}
+		cur.tokens = append(cur.tokens, t)
+	}
+
+	for i := 0; i < len(data); {
+		c := data[i]
+		if c == '\n' {
+			line++
+			i++
+			if depth == 0 {
+				if len(cur.tokens) > 0 {
+					entries = append(entries, cur)
+				}
+				cur, startOfLine = zoneEntry{}, true
+			}
+			continue
+		}
+		if startOfLine && (c == ' ' || c == '\t') {
+			cur.blankOwner = true
+		}
+		startOfLine = false
+
+		switch c {
+		case ' ', '\t', '\r':
+			i++
+		case ';':
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+		case '(':
+			depth++
+			i++
+		case ')':
+			if depth == 0 {
+				return nil, fmt.Errorf("line %d: unbalanced )", line)
+			}
+			depth--
+			i++
+		case '"':
+			j := i + 1
+			for ; j < len(data) && data[j] != '"'; j++ {
+				if data[j] == '\\' {
+					j++
+				}
+				if j < len(data) && data[j] == '\n' {
+					return nil, fmt.Errorf("line %d: unterminated string", line)
+				}
+			}
+			if j >= len(data) {
+				return nil, fmt.Errorf("line %d: unterminated string", line)
+			}
+			add(zoneToken{text: string(data[i+1 : j]), quoted: true})
+			i = j + 1
+		default:
+			j := i
+			for ; j < len(data) && !strings.ContainsRune(" \t\r\n;()\"", rune(data[j])); j++ {
+				if data[j] == '\\' && j+1 < len(data) {
+					j++
+				}
+			}
+			add(zoneToken{text: string(data[i:j])})
+			i = j
+		}
+	}
+	if depth > 0 {
+		return nil, fmt.Errorf("line %d: unbalanced (", line)
+	}
+	if len(cur.tokens) > 0 {
+		entries = append(entries, cur)
+	}
+	return entries, nil
+}
+
+// zoneParser holds the state carried from one entry to the next.
+type zoneParser struct {
+	origin     string
+	lastOwner  string
+	defaultTTL uint32
+	hasDefault bool
+	lastTTL    uint32
+	hasLast    bool
+}
+
+// entry applies a directive or parses a record. ok is false for
+// directives.
+func (p *zoneParser) entry(e zoneEntry) (rr RR, ok bool, err error) {
+	toks := e.tokens
+	if !e.blankOwner && strings.HasPrefix(toks[0].text, "$") && !toks[0].quoted {
+		return rr, false, p.directive(toks)
+	}
+
+	if e.blankOwner {
+		if p.lastOwner == "" {
+			return rr, false, errors.New("record has no owner name")
+		}
+		rr.Name = p.lastOwner
+	} else {
+		if rr.Name, err = p.name(toks[0].text); err != nil {
+			return rr, false, err
+		}
+		toks = toks[1:]
+	}
+	p.lastOwner = rr.Name
+
+	// The TTL and class may each be omitted and come in either order.
+	rr.Class = ClassINET
+	hasTTL := false
+	for i := 0; i < 2 && len(toks) > 0 && !toks[0].quoted; i++ {
+		if t := toks[0].text; isDigit(t[0]) && !hasTTL {
+			if rr.TTL, err = parseZoneTTL(t); err != nil {
+				return rr, false, err
+			}
+			hasTTL = true
+		} else if class, ok := parseClass(t); ok {
+			rr.Class = class
+		} else {
+			break
+		}
+		toks = toks[1:]
+	}
+
+	if len(toks) == 0 || toks[0].quoted {
+		return rr, false, errors.New("missing record type")
+	}
+	if rr.Type, err = ParseType(toks[0].text); err != nil {
+		return rr, false, err
+	}
+	if rr.Data, err = p.rdata(rr.Type, toks[1:]); err != nil {
+		return rr, false, fmt.Errorf("%s %s: %v", rr.Name, rr.Type, err)
+	}
+
+	switch {
+	case hasTTL:
+		p.lastTTL, p.hasLast = rr.TTL, true
+	case p.hasDefault:
+		rr.TTL = p.defaultTTL
+	case p.hasLast:
+		rr.TTL = p.lastTTL
+	case rr.Type == TypeSOA:
+		rr.TTL = rr.Data.(*SOA).Minimum
+		p.lastTTL, p.hasLast = rr.TTL, true
+	default:
+		return rr, false, errors.New("no TTL given and no $TTL set")
+	}
+	return rr, true, nil
+}
+
+func (p *zoneParser) directive(toks []zoneToken) error {
+	if len(toks) < 2 {
+		return fmt.Errorf("%s needs an argument", toks[0].text)
+	}
+	switch strings.ToUpper(toks[0].text) {
+	case "$ORIGIN":
+		origin, err := p.name(toks[1].text)
+		if err != nil {
+			return err
+		}
+		p.origin = origin
+	case "$TTL":
+		ttl, err := parseZoneTTL(toks[1].text)
+		if err != nil {
+			return err
+		}
+		p.defaultTTL, p.hasDefault = ttl, true
+	default:
+		return fmt.Errorf("unsupported directive %s", toks[0].text)
+	}
+	return nil
+}
+
+// name makes a name absolute by appending the current origin.
+func (p *zoneParser) name(s string) (string, error) {
+	switch {
+	case s == "@":
+		if p.origin == "" {
+			return "", errors.New("@ used with no $ORIGIN")
+		}
+		return p.origin, nil
+	case Fqdn(s) == s:
+		return s, nil
+	case p.origin == "":
+		return "", fmt.Errorf("relative name %s with no $ORIGIN", s)
+	case p.origin == ".":
+		return s + ".", nil
+	}
+	return s + "." + p.origin, nil
+}
+
+// rdata parses the presentation format data of a record.
+func (p *zoneParser) rdata(typ Type, toks []zoneToken) (RData, error) {
+	if len(toks) > 0 && toks[0].text == `\#` && !toks[0].quoted {
+		return genericRData(typ, toks[1:])
+	}
+	fields := make([]string, len(toks))
+	for i, t := range toks {
+		fields[i] = t.text
+	}
+	need := func(n int) error {
+		if len(fields) < n {
+			return fmt.Errorf("needs %d fields, got %d", n, len(fields))
+		}
+		return nil
+	}
+	exactly := func(n int) error {
+		if len(fields) != n {
+			return fmt.Errorf("needs %d fields, got %d", n, len(fields))
+		}
+		return nil
+	}
+
+	switch typ {
+	case TypeA, TypeAAAA:
+		if err := exactly(1); err != nil {
+			return nil, err
+		}
+		ip := net.ParseIP(fields[0])
+		if typ == TypeA {
+			if ip == nil || ip.To4() == nil || strings.Contains(fields[0], ":") {
+				return nil, fmt.Errorf("invalid IPv4 address %q", fields[0])
+			}
+			return &A{IP: ip.To4()}, nil
+		}
+		if ip == nil || !strings.Contains(fields[0], ":") {
+			return nil, fmt.Errorf("invalid IPv6 address %q", fields[0])
+		}
+		return &AAAA{IP: ip}, nil
+	case TypeNS, TypeCNAME, TypePTR:
+		if err := exactly(1); err != nil {
+			return nil, err
+		}
+		host, err := p.name(fields[0])
+		if err != nil {
+			return nil, err
+		}
+		switch typ {
+		case TypeNS:
+			return &NS{Host: host}, nil
+		case TypeCNAME:
+			return &CNAME{Target: host}, nil
+		}
+		return &PTR{Host: host}, nil
+	case TypeMX:
+		if err := exactly(2); err != nil {
+			return nil, err
+		}
+		pref, err := parseUint(fields[0], 16)
+		if err != nil {
+			return nil, err
+		}
+		host, err := p.name(fields[1])
+		if err != nil {
+			return nil, err
+		}
+		return &MX{Pref: uint16(pref), Host: host}, nil
+	case TypeTXT:
+		if err := need(1); err != nil {
+			return nil, err
+		}
+		txt := &TXT{}
+		for _, f := range fields {
+			s, err := unescapeString(f)
+			if err != nil {
+				return nil, err
+			}
+			txt.Strings = append(txt.Strings, s)
+		}
+		return txt, nil
+	case TypeSOA:
+		if err := exactly(7); err != nil {
+			return nil, err
+		}
+		soa := &SOA{}
+		var err error
+		if soa.MName, err = p.name(fields[0]); err != nil {
+			return nil, err
+		}
+		if soa.RName, err = p.name(fields[1]); err != nil {
+			return nil, err
+		}
+		serial, err := parseUint(fields[2], 32)
+		if err != nil {
+			return nil, err
+		}
+		soa.Serial = uint32(serial)
+		for i, v := range []*uint32{&soa.Refresh, &soa.Retry, &soa.Expire, &soa.Minimum} {
+			if *v, err = parseZoneTTL(fields[3+i]); err != nil {
+				return nil, err
+			}
+		}
+		return soa, nil
+	case TypeSRV:
+		if err := exactly(4); err != nil {
+			return nil, err
+		}
+		var nums [3]uint64
+		for i := range nums {
+			n, err := parseUint(fields[i], 16)
+			if err != nil {
+				return nil, err
+			}
+			nums[i] = n
+		}
+		target, err := p.name(fields[3])
+		if err != nil {
+			return nil, err
+		}
+		return &SRV{Priority: uint16(nums[0]), Weight: uint16(nums[1]), Port: uint16(nums[2]), Target: target}, nil
+	case TypeCAA:
+		if err := exactly(3); err != nil {
+			return nil, err
+		}
+		flags, err := parseUint(fields[0], 8)
+		if err != nil {
+			return nil, err
+		}
+		value, err := unescapeString(fields[2])
+		if err != nil {
+			return nil, err
+		}
+		return &CAA{Flags: uint8(flags), Tag: fields[1], Value: value}, nil
+	case TypeDS:
+		if err := need(4); err != nil {
+			return nil, err
+		}
+		var nums [3]uint64
+		for i, bits := range []int{16, 8, 8} {
+			n, err := parseUint(fields[i], bits)
+			if err != nil {
+				return nil, err
+			}
+			nums[i] = n
+		}
+		digest, err := hex.DecodeString(strings.Join(fields[3:], ""))
+		if err != nil {
+			return nil, fmt.Errorf("invalid digest: %v", err)
+		}
+		return &DS{KeyTag: uint16(nums[0]), Algorithm: uint8(nums[1]), DigestType: uint8(nums[2]), Digest: digest}, nil
+	case TypeDNSKEY:
+		if err := need(4); err != nil {
+			return nil, err
+		}
+		var nums [3]uint64
+		for i, bits := range []int{16, 8, 8} {
+			n, err := parseUint(fields[i], bits)
+			if err != nil {
+				return nil, err
+			}
+			nums[i] = n
+		}
+		key, err := base64.StdEncoding.DecodeString(strings.Join(fields[3:], ""))
+		if err != nil {
+			return nil, fmt.Errorf("invalid public key: %v", err)
+		}
+		return &DNSKEY{Flags: uint16(nums[0]), Protocol: uint8(nums[1]), Algorithm: uint8(nums[2]), PublicKey: key}, nil
+	}
+	return nil, fmt.Errorf("unsupported record type, use the \\# form")
+}
+
+// genericRData decodes the RFC 3597 "\# length hex" form of rdata.
+func genericRData(typ Type, toks []zoneToken) (RData, error) {
+	if len(toks) == 0 {
+		return nil, errors.New(`\# needs a length`)
+	}
+	length, err := parseUint(toks[0].text, 16)
+	if err != nil {
+		return nil, err
+	}
+	var hexData strings.Builder
+	for _, t := range toks[1:] {
+		hexData.WriteString(t.text)
+	}
+	data, err := hex.DecodeString(hexData.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid \\# data: %v", err)
+	}
+	if len(data) != int(length) {
+		return nil, fmt.Errorf("\\# length %d does not match %d octets of data", length, len(data))
+	}
+	return unpackRData(data, 0, len(data), typ)
+}
+
+// parseClass parses a class mnemonic or the generic CLASSnnn form.
+func parseClass(s string) (Class, bool) {
+	upper := strings.ToUpper(s)
+	switch upper {
+	case "IN":
+		return ClassINET, true
+	case "CH":
+		return ClassCHAOS, true
+	}
+	if n, ok := strings.CutPrefix(upper, "CLASS"); ok {
+		if v, err := strconv.ParseUint(n, 10, 16); err == nil {
+			return Class(v), true
+		}
+	}
+	return 0, false
+}
+
+// parseZoneTTL parses a TTL in seconds or in BIND's unit form such as
+// "1h30m" or "2D".
+func parseZoneTTL(s string) (uint32, error) {
+	if n, err := strconv.ParseUint(s, 10, 32); err == nil {
+		return uint32(n), nil
+	}
+	var total, n uint64
+	digits := false
+	if s == "" {
+		return 0, errors.New("empty TTL")
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if isDigit(c) {
+			n = n*10 + uint64(c-'0')
+			digits = true
+			continue
+		}
+		var unit uint64
+		switch c | 0x20 {
+		case 's':
+			unit = 1
+		case 'm':
+			unit = 60
+		case 'h':
+			unit = 3600
+		case 'd':
+			unit = 86400
+		case 'w':
+			unit = 604800
+		}
+		if unit == 0 || !digits {
+			return 0, fmt.Errorf("invalid TTL %q", s)
+		}
+		total += n * unit
+		n, digits = 0, false
+	}
+	if digits || total > 1<<31-1 {
+		return 0, fmt.Errorf("invalid TTL %q", s)
+	}
+	return uint32(total), nil
+}
+
+func parseUint(s string, bits int) (uint64, error) {
+	n, err := strconv.ParseUint(s, 10, bits)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %d-bit number %q", bits, s)
+	}
+	return n, nil
+}
+
+// unescapeString decodes the \X and \DDD escapes of a character string.
+func unescapeString(s string) (string, error) {
+	if !strings.Contains(s, `\`) {
+		return s, nil
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			sb.WriteByte(s[i])
+			continue
+		}
+		if i+1 >= len(s) {
+			return "", fmt.Errorf("trailing backslash in %q", s)
+		}
+		if isDigit(s[i+1]) {
+			if i+4 > len(s) {
+				return "", fmt.Errorf("short \\DDD escape in %q", s)
+			}
+			n, err := strconv.ParseUint(s[i+1:i+4], 10, 8)
+			if err != nil {
+				return "", fmt.Errorf("invalid \\DDD escape in %q", s)
+			}
+			sb.WriteByte(byte(n))
+			i += 3
+			continue
+		}
+		sb.WriteByte(s[i+1])
+		i++
+	}
+	return sb.String(), nil
+}
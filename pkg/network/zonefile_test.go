@@ -0,0 +1,108 @@
+package network
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseZoneFile(t *testing.T) {
+	f, err := os.Open("testdata/db.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zone, err := ParseZoneFile(f, "")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "example.com.", zone.Origin)
+	assert.Equal(t, &SOA{MName: "ns1.example.com.", RName: "hostmaster.example.com.", Serial: 2024010101,
+		Refresh: 7200, Retry: 900, Expire: 1209600, Minimum: 300}, zone.SOA())
+
+	var got []string
+	for _, rr := range zone.Records {
+		got = append(got, rr.String())
+	}
+	assert.Equal(t, []string{
+		"example.com.\t3600\tIN\tSOA\tns1.example.com. hostmaster.example.com. 2024010101 7200 900 1209600 300",
+		"example.com.\t3600\tIN\tNS\tns1.example.com.",
+		"example.com.\t3600\tIN\tNS\tns2.example.net.",
+		"example.com.\t3600\tIN\tMX\t10 mail.example.com.",
+		"example.com.\t3600\tIN\tMX\t20 mail.example.net.",
+		"example.com.\t3600\tIN\tTXT\t\"v=spf1 mx -all\"",
+		"example.com.\t300\tIN\tCAA\t0 issue \"letsencrypt.org\"",
+		"ns1.example.com.\t3600\tIN\tA\t192.0.2.53",
+		"mail.example.com.\t600\tIN\tA\t192.0.2.25",
+		"www.example.com.\t3600\tIN\tCNAME\tweb.example.com.",
+		"web.example.com.\t3600\tIN\tA\t192.0.2.80",
+		"web.example.com.\t3600\tIN\tAAAA\t2001:db8::80",
+		"_sip._tcp.example.com.\t3600\tIN\tSRV\t10 60 5060 sip.example.com.",
+		"sip.example.com.\t3600\tIN\tA\t192.0.2.60",
+		"long.example.com.\t3600\tIN\tTXT\t\"first part; not a comment\" \"second \\\"quoted\\\" part\"",
+		"host.sub.example.com.\t3600\tIN\tA\t192.0.2.100",
+		"unknown.sub.example.com.\t3600\tIN\tTYPE65534\t\\# 4 0a000001",
+	}, got)
+}
+
+func TestParseZoneFileTTLs(t *testing.T) {
+	// Without $TTL the SOA minimum applies until a TTL is given, which
+	// then carries over to later records.
+	zone, err := ParseZoneFile(strings.NewReader(`
+@ IN SOA ns1 admin 1 3600 600 86400 120
+www A 192.0.2.1
+mail 1h30m A 192.0.2.2
+ftp A 192.0.2.3
+`), "example.org")
+	if !assert.NoError(t, err) {
+		return
+	}
+	var ttls []uint32
+	for _, rr := range zone.Records {
+		ttls = append(ttls, rr.TTL)
+	}
+	assert.Equal(t, []uint32{120, 120, 5400, 5400}, ttls)
+	assert.Equal(t, "example.org.", zone.Origin)
+	assert.Equal(t, "ftp.example.org.", zone.Records[3].Name)
+}
+
+func TestParseZoneFileErrors(t *testing.T) {
+	tests := []struct {
+		zone string
+		err  string
+	}{
+		{"www 300 A 192.0.2.1", "line 1: relative name www with no $ORIGIN"},
+		{"www.example.com. A 192.0.2.1", "line 1: no TTL given and no $TTL set"},
+		{"$TTL 300\n  A 192.0.2.1", "line 2: record has no owner name"},
+		{"$TTL 300\nwww.example.com. A 2001:db8::1", "line 2: www.example.com. A: invalid IPv4 address"},
+		{"$TTL 300\nwww.example.com. MX mail.example.com.", "needs 2 fields, got 1"},
+		{"$TTL 300\nwww.example.com. BOGUS x", "unknown record type: BOGUS"},
+		{"$TTL 300\nwww.example.com. IN", "line 2: missing record type"},
+		{"$TTL 300\nwww.example.com. TXT \"unterminated\n", "line 2: unterminated string"},
+		{"$TTL 300\nwww.example.com. TXT ( \"a\"\n", "unbalanced ("},
+		{"$TTL 1x", "line 1: invalid TTL \"1x\""},
+		{"$INCLUDE other.zone", "unsupported directive $INCLUDE"},
+		{"$TTL 300\nx.example.com. NSEC y.example.com. A", "unsupported record type"},
+		{"$TTL 300\nx.example.com. A \\# 4 0a0000", "\\# length 4 does not match"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.err, func(t *testing.T) {
+			_, err := ParseZoneFile(strings.NewReader(tt.zone), "")
+			assert.ErrorContains(t, err, tt.err)
+		})
+	}
+}
+
+func TestParseZoneTTL(t *testing.T) {
+	for s, want := range map[string]uint32{"0": 0, "300": 300, "1h": 3600, "1H30M": 5400, "2d": 172800, "1w1s": 604801} {
+		got, err := parseZoneTTL(s)
+		assert.NoError(t, err, s)
+		assert.Equal(t, want, got, s)
+	}
+	for _, s := range []string{"", "h", "1h2", "1y", "99999999999"} {
+		_, err := parseZoneTTL(s)
+		assert.Error(t, err, s)
+	}
+}
@@ -0,0 +1,201 @@
+package network
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+// DriftKind classifies a difference between a zone file and what the
+// authoritative servers answer.
+type DriftKind int
+
+const (
+	// DriftMissing is a record in the zone file that a server does not
+	// serve.
+	DriftMissing DriftKind = iota
+	// DriftExtra is a record a server serves that is not in the zone file.
+	DriftExtra
+	// DriftTTL is a record served with a different TTL.
+	DriftTTL
+)
+
+func (k DriftKind) String() string {
+	switch k {
+	case DriftMissing:
+		return "missing"
+	case DriftExtra:
+		return "extra"
+	}
+	return "ttl"
+}
+
+// ZoneDrift is one record that differs.
+type ZoneDrift struct {
+	Kind DriftKind
+	// Record is the zone file record, or the served one for DriftExtra.
+	Record RR
+	// LiveTTL is the TTL the server answered with, for DriftTTL.
+	LiveTTL uint32
+}
+
+// ZoneServerDrift holds the differences found on one nameserver.
+type ZoneServerDrift struct {
+	Nameserver string
+	Server     string
+	Drift      []ZoneDrift
+	// Errs holds the queries that failed, other than for names that do not
+	// exist, whose records are reported as missing.
+	Errs []error
+	// Err is set when the nameserver's address could not be found.
+	Err error
+}
+
+// ZoneVerifyResult is the outcome of comparing a zone file with its
+// authoritative servers.
+type ZoneVerifyResult struct {
+	Zone string
+	// RRsets is the number of name and type pairs queried on each server.
+	RRsets  int
+	Servers []ZoneServerDrift
+}
+
+// InSync reports whether every server answered every query exactly as the
+// zone file says.
+func (r *ZoneVerifyResult) InSync() bool {
+	for _, s := range r.Servers {
+		if s.Err != nil || len(s.Errs) > 0 || len(s.Drift) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// zoneRRset is the records of one name and type in a zone file.
+type zoneRRset struct {
+	Name    string
+	Type    Type
+	Records []RR
+}
+
+// VerifyZone queries every authoritative nameserver of the zone for each
+// RRset in the zone file and reports the records that are missing, extra
+// or served with a different TTL. Only the names and types present in the
+// file are queried, so records at other names are not found.
+func (c *ConsistencyChecker) VerifyZone(ctx context.Context, zone *ZoneFile) (*ZoneVerifyResult, error) {
+	nsHosts, err := lookupNSRecords(c.Lookup, zone.Origin)
+	if err != nil {
+		return nil, err
+	}
+	rrsets := groupRRsets(zone.Records)
+	result := &ZoneVerifyResult{Zone: zone.Origin, RRsets: len(rrsets)}
+	for _, sa := range c.nameservers(nsHosts) {
+		result.Servers = append(result.Servers, ZoneServerDrift{Nameserver: sa.Nameserver, Server: sa.Server, Err: sa.Err})
+	}
+
+	var wg sync.WaitGroup
+	for i := range result.Servers {
+		if result.Servers[i].Err != nil {
+			continue
+		}
+		wg.Add(1)
+		go func(sd *ZoneServerDrift) {
+			defer wg.Done()
+			client := &DNSClient{Server: sd.Server, Timeout: c.Timeout}
+			for _, set := range rrsets {
+				live, err := servedRecords(ctx, client, set.Name, set.Type)
+				if err != nil && !isNotFound(err) {
+					sd.Errs = append(sd.Errs, err)
+					continue
+				}
+				sd.Drift = append(sd.Drift, compareRRset(set.Records, live)...)
+			}
+		}(&result.Servers[i])
+	}
+	wg.Wait()
+	return result, nil
+}
+
+// groupRRsets groups records by name and type in order of first
+// appearance.
+func groupRRsets(rrs []RR) []*zoneRRset {
+	var sets []*zoneRRset
+	index := make(map[string]*zoneRRset)
+	for _, rr := range rrs {
+		key := strings.ToLower(rr.Name) + " " + rr.Type.String()
+		set, ok := index[key]
+		if !ok {
+			set = &zoneRRset{Name: rr.Name, Type: rr.Type}
+			index[key] = set
+			sets = append(sets, set)
+		}
+		set.Records = append(set.Records, rr)
+	}
+	return sets
+}
+
+// servedRecords sends a non-recursive query and returns the records of
+// name and type in the answer. Delegation NS records and glue come back in
+// a referral rather than the answer, so the authority and additional
+// sections are searched when the answer has none.
+func servedRecords(ctx context.Context, client *DNSClient, name string, qtype Type) ([]RR, error) {
+	query := NewQuery(name, qtype)
+	query.RecursionDesired = false
+	resp, err := client.Exchange(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	if err := rcodeError(resp, name, qtype); err != nil {
+		return nil, err
+	}
+	for _, section := range [][]RR{resp.Answer, append(resp.Authority, resp.Additional...)} {
+		var rrs []RR
+		for _, rr := range section {
+			if rr.Type == qtype && strings.EqualFold(rr.Name, name) {
+				rrs = append(rrs, rr)
+			}
+		}
+		if len(rrs) > 0 {
+			return rrs, nil
+		}
+	}
+	return nil, nil
+}
+
+// compareRRset reports the differences between the records of one RRset
+// in the zone file and those served.
+func compareRRset(want, live []RR) []ZoneDrift {
+	var drift []ZoneDrift
+	served := make(map[string]RR)
+	for _, rr := range live {
+		served[rdataKey(rr)] = rr
+	}
+	for _, rr := range want {
+		key := rdataKey(rr)
+		got, ok := served[key]
+		switch {
+		case !ok:
+			drift = append(drift, ZoneDrift{Kind: DriftMissing, Record: rr})
+		case got.TTL != rr.TTL:
+			drift = append(drift, ZoneDrift{Kind: DriftTTL, Record: rr, LiveTTL: got.TTL})
+		}
+		delete(served, key)
+	}
+	for _, rr := range live {
+		if _, ok := served[rdataKey(rr)]; ok {
+			drift = append(drift, ZoneDrift{Kind: DriftExtra, Record: rr})
+			delete(served, rdataKey(rr))
+		}
+	}
+	return drift
+}
+
+// rdataKey returns the record data for comparison, with the names in it
+// lowercased.
+func rdataKey(rr RR) string {
+	switch rr.Type {
+	case TypeNS, TypeCNAME, TypePTR, TypeMX, TypeSOA, TypeSRV:
+		return strings.ToLower(rr.Data.String())
+	}
+	return rr.Data.String()
+}
@@ -0,0 +1,113 @@
+package network
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const verifyZoneFile = `$ORIGIN example.com.
+$TTL 300
+@       SOA  ns1 admin 7 3600 600 86400 300
+        NS   ns1
+ns1     A    192.0.2.53
+www     A    192.0.2.1
+        A    192.0.2.2
+mail    A    192.0.2.25
+api 60  A    192.0.2.30
+sub     NS   ns.sub
+ns.sub  A    192.0.2.99
+`
+
+func TestVerifyZone(t *testing.T) {
+	zone, err := ParseZoneFile(strings.NewReader(verifyZoneFile), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := func(name string, typ Type, ttl uint32, data RData) RR {
+		return RR{Name: name, Type: typ, Class: ClassINET, TTL: ttl, Data: data}
+	}
+	a := func(ip string) *A { return &A{IP: net.ParseIP(ip).To4()} }
+	live := testZone{
+		rr("example.com.", TypeSOA, 300, &SOA{MName: "NS1.example.com.", RName: "admin.example.com.", Serial: 7, Refresh: 3600, Retry: 600, Expire: 86400, Minimum: 300}),
+		rr("example.com.", TypeNS, 300, &NS{Host: "ns1.example.com."}),
+		rr("ns1.example.com.", TypeA, 300, a("192.0.2.53")),
+		rr("www.example.com.", TypeA, 300, a("192.0.2.1")),
+		rr("www.example.com.", TypeA, 300, a("192.0.2.3")),
+		rr("api.example.com.", TypeA, 300, a("192.0.2.30")),
+	}
+	referral := []RR{rr("sub.example.com.", TypeNS, 300, &NS{Host: "ns.sub.example.com."})}
+	glue := []RR{rr("ns.sub.example.com.", TypeA, 300, a("192.0.2.99"))}
+	addr := startTestDNSServer(t, func(req *Message) *Message {
+		if strings.HasSuffix(req.Question[0].Name, "sub.example.com.") {
+			resp := replyTo(req)
+			resp.Authority, resp.Additional = referral, glue
+			return resp
+		}
+		return live.handle(req)
+	})
+	_, port, _ := net.SplitHostPort(addr)
+
+	lookup := MockHostLookup{
+		LookupNSFunc: func(domain string) ([]*net.NS, error) {
+			assert.Equal(t, "example.com.", domain)
+			return []*net.NS{{Host: "ns1.example.com."}, {Host: "ns2.example.com."}}, nil
+		},
+		LookupHostFunc: func(domain string) ([]string, error) {
+			if domain == "ns1.example.com." {
+				return []string{"127.0.0.1"}, nil
+			}
+			return nil, fmt.Errorf("no such host")
+		},
+	}
+	checker := &ConsistencyChecker{Lookup: lookup, Port: port, Timeout: time.Second}
+	result, err := checker.VerifyZone(context.Background(), zone)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "example.com.", result.Zone)
+	assert.Equal(t, 8, result.RRsets)
+	assert.False(t, result.InSync())
+	if !assert.Len(t, result.Servers, 2) {
+		return
+	}
+	assert.Error(t, result.Servers[1].Err)
+
+	server := result.Servers[0]
+	assert.Empty(t, server.Errs)
+	var got []string
+	for _, d := range server.Drift {
+		got = append(got, fmt.Sprintf("%s %s %s %d", d.Kind, d.Record.Name, d.Record.Data, d.LiveTTL))
+	}
+	assert.Equal(t, []string{
+		"missing www.example.com. 192.0.2.2 0",
+		"extra www.example.com. 192.0.2.3 0",
+		"missing mail.example.com. 192.0.2.25 0",
+		"ttl api.example.com. 192.0.2.30 300",
+	}, got)
+}
+
+func TestVerifyZoneInSync(t *testing.T) {
+	zone, err := ParseZoneFile(strings.NewReader("$TTL 300\nwww.example.com. A 192.0.2.1\n"), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := startTestDNSServer(t, testZone(zone.Records).handle)
+	_, port, _ := net.SplitHostPort(addr)
+	lookup := MockHostLookup{
+		LookupNSFunc: func(domain string) ([]*net.NS, error) {
+			return []*net.NS{{Host: "ns1.example.com."}}, nil
+		},
+		LookupHostFunc: func(domain string) ([]string, error) { return []string{"127.0.0.1"}, nil },
+	}
+	checker := &ConsistencyChecker{Lookup: lookup, Port: port, Timeout: time.Second}
+	result, err := checker.VerifyZone(context.Background(), zone)
+	assert.NoError(t, err)
+	assert.True(t, result.InSync())
+}